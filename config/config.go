@@ -21,22 +21,54 @@ type ServerProperties struct {
 	Databases   int      `cfg:"databases"`   // 数据库数
 	Peers       []string `cfg:"peers"`       // 集群节点
 	Self        string   `cfg:"self"`        // 本节点
+
+	HashMaxListpackEntries int  `cfg:"hash-max-listpack-entries"` // 哈希表使用 listpack 编码的最大元素个数
+	HashMaxListpackValue   int  `cfg:"hash-max-listpack-value"`   // 哈希表使用 listpack 编码的最大元素长度
+	SetMaxIntsetEntries    int  `cfg:"set-max-intset-entries"`    // 集合使用 intset 编码的最大元素个数
+	HllSparseMaxBytes      int  `cfg:"hll-sparse-max-bytes"`      // HyperLogLog 使用稀疏编码的最大字节数
+	Hz                     int  `cfg:"hz"`                        // 后台任务每秒执行的次数
+	ActiveRehashing        bool `cfg:"activerehashing"`           // 是否在后台任务中对正在 rehash 的键空间执行渐进式 rehash
+
+	Maxmemory        int    `cfg:"maxmemory"`         // 已用内存的上限, 单位为字节, 0 表示没有限制
+	MaxmemoryPolicy  string `cfg:"maxmemory-policy"`  // 内存淘汰策略, 决定记录 key 的访问时间还是访问频率
+	MaxmemorySamples int    `cfg:"maxmemory-samples"` // 每次淘汰时在每个 DB 中抽样的 key 的个数
+	LfuLogFactor     int    `cfg:"lfu-log-factor"`    // LFU 计数器的对数因子, 越大计数器增长越慢
+	LfuDecayTime     int    `cfg:"lfu-decay-time"`    // LFU 计数器每经过多少分钟衰减 1
+
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"` // 发布的键空间通知的类型, 空字符串表示不发布通知
 }
 
 // Properties 保存全局配置属性
 var Properties *ServerProperties
 
+// DefaultProperties 返回使用 redis 默认值的配置, 不包含监听地址和端口
+// 所有创建配置的地方都从这里开始, 新增配置项时只需要在这里添加默认值
+func DefaultProperties() *ServerProperties {
+	return &ServerProperties{
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		SetMaxIntsetEntries:    512,
+		HllSparseMaxBytes:      3000,
+		Hz:                     10,
+		ActiveRehashing:        true,
+
+		MaxmemoryPolicy:  "noeviction",
+		MaxmemorySamples: 5,
+		LfuLogFactor:     10,
+		LfuDecayTime:     1,
+	}
+}
+
 func init() {
 	// 默认配置
-	Properties = &ServerProperties{
-		Bind:       "127.0.0.1",
-		Port:       6379,
-		AppendOnly: false,
-	}
+	Properties = DefaultProperties()
+	Properties.Bind = "127.0.0.1"
+	Properties.Port = 6379
 }
 
 func parse(src io.Reader) *ServerProperties {
-	config := &ServerProperties{}
+	// 配置文件中没有出现的参数使用 redis 的默认值
+	config := DefaultProperties()
 
 	// 读取配置文件
 	rawMap := make(map[string]string)
@@ -91,11 +123,15 @@ func parse(src io.Reader) *ServerProperties {
 			// 根据fieldVal的类型，将value转换为对应的类型
 			switch fieldVal.Kind() {
 			case reflect.String:
+				// 与 redis 相同, 值可以用双引号包围, 例如 notify-keyspace-events ""
+				if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+					value = value[1 : len(value)-1]
+				}
 				fieldVal.SetString(value)
 			case reflect.Int:
-				// 将value转换为int64
-				intValue, err := strconv.ParseInt(value, 10, 64)
-				if err != nil {
+				// 将value转换为int64, 可以使用内存单位, 例如 maxmemory 100mb
+				intValue, err := parseMemory(value)
+				if err == nil {
 					fieldVal.SetInt(intValue)
 				}
 			case reflect.Bool:
@@ -115,6 +151,32 @@ func parse(src io.Reader) *ServerProperties {
 	return config
 }
 
+// memoryUnits 是 redis 配置中的内存单位, k、m、g 以 1000 为基数, kb、mb、gb 以 1024 为基数
+var memoryUnits = []struct {
+	suffix string
+	unit   int64
+}{
+	{"kb", 1 << 10},
+	{"mb", 1 << 20},
+	{"gb", 1 << 30},
+	{"k", 1000},
+	{"m", 1000 * 1000},
+	{"g", 1000 * 1000 * 1000},
+	{"b", 1},
+}
+
+// parseMemory 解析可以带有内存单位的整数, 单位不区分大小写
+func parseMemory(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	for _, u := range memoryUnits {
+		if strings.HasSuffix(lower, u.suffix) {
+			n, err := strconv.ParseInt(lower[:len(lower)-len(u.suffix)], 10, 64)
+			return n * u.unit, err
+		}
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
 // SetupConfig 读取配置文件并且初始化配置
 func SetupConfig(configFilename string) {
 	file, err := os.Open(configFilename)
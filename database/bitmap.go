@@ -0,0 +1,539 @@
+package database
+
+import (
+	"strconv"
+
+	"github.com/LynchQ/my-go-redis/datastruct/bitmap"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+const errBitOffset = "ERR bit offset is not an integer or out of range"
+
+// parseBitOffset 解析位偏移量, 偏移量不能超过字符串的最大长度
+func parseBitOffset(arg []byte) (int64, resp.ErrorReply) {
+	offset, ok := strictParseInt64(arg)
+	if !ok || offset < 0 || offset>>3 >= maxStringLen {
+		return 0, reply.MakeErrReply(errBitOffset)
+	}
+	return offset, nil
+}
+
+// execSetBit 设置 offset 处的位, 返回原来的值
+// SETBIT key offset value
+func execSetBit(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if len(args[2]) != 1 || (args[2][0] != '0' && args[2][0] != '1') {
+		return reply.MakeErrReply("ERR bit is not an integer or out of range")
+	}
+	val := args[2][0] - '0'
+
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	value := bitmap.Grow(old, offset)
+	prev := bitmap.GetBit(value, offset)
+	bitmap.SetBit(value, offset, val)
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "setbit", key)
+	return reply.MakeIntReply(int64(prev))
+}
+
+// execGetBit 返回 offset 处的位
+// GETBIT key offset
+func execGetBit(db *DB, args [][]byte) resp.Reply {
+	offset, errReply := parseBitOffset(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(bitmap.GetBit(value, offset)))
+}
+
+// parseBitRange 解析 start end [BYTE|BIT] 并转换为以位为单位的闭区间, 区间为空时 ok 为 false
+func parseBitRange(args [][]byte, strLen int64) (start int64, end int64, ok bool, errReply resp.ErrorReply) {
+	start, errReply = parseInt64(args[0])
+	if errReply != nil {
+		return
+	}
+	end, errReply = parseInt64(args[1])
+	if errReply != nil {
+		return
+	}
+	isBit := false
+	if len(args) == 3 {
+		switch toUpper(args[2]) {
+		case "BIT":
+			isBit = true
+		case "BYTE":
+		default:
+			errReply = reply.MakeSyntaxErrReply()
+			return
+		}
+	}
+
+	total := strLen
+	if isBit {
+		total = strLen << 3
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end {
+		return 0, 0, false, nil
+	}
+	if !isBit {
+		start, end = start<<3, end<<3+7
+	}
+	return start, end, true, nil
+}
+
+// execBitCount 统计 1 的个数
+// BITCOUNT key [start end [BYTE | BIT]]
+func execBitCount(db *DB, args [][]byte) resp.Reply {
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		return reply.MakeSyntaxErrReply()
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	start, end := int64(0), int64(len(value))<<3-1
+	if len(args) > 1 {
+		var ok bool
+		start, end, ok, errReply = parseBitRange(args[1:], int64(len(value)))
+		if errReply != nil {
+			return errReply
+		}
+		if !ok {
+			return reply.MakeIntReply(0)
+		}
+	}
+	return reply.MakeIntReply(bitmap.CountBits(value, start, end))
+}
+
+// execBitPos 返回第一个值为 bit 的位置
+// BITPOS key bit [start [end [BYTE | BIT]]]
+func execBitPos(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 5 {
+		return reply.MakeSyntaxErrReply()
+	}
+	if len(args[1]) != 1 || (args[1][0] != '0' && args[1][0] != '1') {
+		return reply.MakeErrReply("ERR The bit argument must be 1 or 0.")
+	}
+	bit := args[1][0] - '0'
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	// 与 redis 相同, 不存在的 key 视为无限个 0, 不检查范围参数
+	if value == nil {
+		if bit == 1 {
+			return reply.MakeIntReply(-1)
+		}
+		return reply.MakeIntReply(0)
+	}
+
+	strLen := int64(len(value))
+	start, end := int64(0), strLen<<3-1
+	endGiven := len(args) >= 4
+	if len(args) >= 3 {
+		rangeArgs := args[2:]
+		if !endGiven {
+			// 未指定 end 时 end 为字符串末尾
+			rangeArgs = [][]byte{args[2], []byte("-1")}
+		}
+		var ok bool
+		start, end, ok, errReply = parseBitRange(rangeArgs, strLen)
+		if errReply != nil {
+			return errReply
+		}
+		if !ok {
+			return reply.MakeIntReply(-1)
+		}
+	}
+
+	// 空字符串没有可以查找的范围, 与 redis 相同返回 -1, 不视为右侧有无限个 0
+	if strLen == 0 {
+		return reply.MakeIntReply(-1)
+	}
+	pos := bitmap.FindBit(value, bit, start, end)
+	if pos == -1 && bit == 0 && !endGiven {
+		// 没有指定 end 时, 字符串右侧视为无限个 0
+		return reply.MakeIntReply(end + 1)
+	}
+	return reply.MakeIntReply(pos)
+}
+
+// execBitOp 对多个字符串执行按位运算, 结果保存到 destkey, 返回结果的长度
+// BITOP AND | OR | XOR | NOT destkey key [key ...]
+func execBitOp(db *DB, args [][]byte) resp.Reply {
+	op := toUpper(args[0])
+	destKey := string(args[1])
+	srcKeys := args[2:]
+	switch op {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(srcKeys) != 1 {
+			return reply.MakeErrReply("ERR BITOP NOT must be called with a single source key.")
+		}
+	default:
+		return reply.MakeSyntaxErrReply()
+	}
+
+	values := make([][]byte, len(srcKeys))
+	maxLen := 0
+	for i, key := range srcKeys {
+		value, errReply := db.getAsString(string(key))
+		if errReply != nil {
+			return errReply
+		}
+		values[i] = value
+		if len(value) > maxLen {
+			maxLen = len(value)
+		}
+	}
+	if maxLen == 0 {
+		db.Removes(destKey)
+		return reply.MakeIntReply(0)
+	}
+
+	result := make([]byte, maxLen)
+	if op == "NOT" {
+		for i := range result {
+			result[i] = ^values[0][i]
+		}
+	} else {
+		copy(result, values[0])
+		for _, value := range values[1:] {
+			for i := range result {
+				var b byte
+				if i < len(value) {
+					b = value[i]
+				}
+				switch op {
+				case "AND":
+					result[i] &= b
+				case "OR":
+					result[i] |= b
+				case "XOR":
+					result[i] ^= b
+				}
+			}
+		}
+	}
+	db.PutEntity(destKey, &database.DataEntity{Data: result})
+	db.Persist(destKey)
+	db.notify(notifyString, "set", destKey)
+	return reply.MakeIntReply(int64(maxLen))
+}
+
+/* ---- BITFIELD ---- */
+
+// BITFIELD 的溢出处理策略
+const (
+	overflowWrap = iota
+	overflowSat
+	overflowFail
+)
+
+// BITFIELD 的操作类型
+const (
+	bitFieldGet = iota
+	bitFieldSet
+	bitFieldIncrBy
+)
+
+type bitFieldOp struct {
+	opcode   int
+	signed   bool
+	width    uint
+	offset   int64
+	value    int64 // SET 的值或 INCRBY 的增量
+	overflow int
+}
+
+// parseBitFieldType 解析形如 i16、u8 的类型, 无符号整数最多 63 位
+func parseBitFieldType(arg []byte) (signed bool, width uint, ok bool) {
+	if len(arg) < 2 || (arg[0] != 'i' && arg[0] != 'u') {
+		return false, 0, false
+	}
+	signed = arg[0] == 'i'
+	w, err := strconv.Atoi(string(arg[1:]))
+	if err != nil || w < 1 || (signed && w > 64) || (!signed && w > 63) {
+		return false, 0, false
+	}
+	return signed, uint(w), true
+}
+
+// parseBitFieldOffset 解析偏移量, #N 表示第 N 个 width 位的整数
+func parseBitFieldOffset(arg []byte, width uint) (int64, resp.ErrorReply) {
+	byWidth := len(arg) > 0 && arg[0] == '#'
+	if byWidth {
+		arg = arg[1:]
+	}
+	offset, ok := strictParseInt64(arg)
+	if !ok || offset < 0 {
+		return 0, reply.MakeErrReply(errBitOffset)
+	}
+	if byWidth {
+		offset *= int64(width)
+	}
+	if offset < 0 || (offset+int64(width)-1)>>3 >= maxStringLen {
+		return 0, reply.MakeErrReply(errBitOffset)
+	}
+	return offset, nil
+}
+
+// parseBitFieldOps 解析 BITFIELD 的子命令
+func parseBitFieldOps(args [][]byte, readOnly bool) ([]*bitFieldOp, resp.ErrorReply) {
+	ops := make([]*bitFieldOp, 0, len(args)/3)
+	overflow := overflowWrap
+	for i := 0; i < len(args); {
+		subCmd := toUpper(args[i])
+		remaining := len(args) - i - 1
+		if subCmd == "OVERFLOW" && remaining >= 1 {
+			switch toUpper(args[i+1]) {
+			case "WRAP":
+				overflow = overflowWrap
+			case "SAT":
+				overflow = overflowSat
+			case "FAIL":
+				overflow = overflowFail
+			default:
+				return nil, reply.MakeErrReply("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		}
+
+		op := &bitFieldOp{overflow: overflow}
+		switch {
+		case subCmd == "GET" && remaining >= 2:
+			op.opcode = bitFieldGet
+		case subCmd == "SET" && remaining >= 3:
+			op.opcode = bitFieldSet
+		case subCmd == "INCRBY" && remaining >= 3:
+			op.opcode = bitFieldIncrBy
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+		if readOnly && op.opcode != bitFieldGet {
+			return nil, reply.MakeErrReply("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+
+		var ok bool
+		op.signed, op.width, ok = parseBitFieldType(args[i+1])
+		if !ok {
+			return nil, reply.MakeErrReply("ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+		}
+		var errReply resp.ErrorReply
+		op.offset, errReply = parseBitFieldOffset(args[i+2], op.width)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if op.opcode == bitFieldGet {
+			i += 3
+		} else {
+			op.value, errReply = parseInt64(args[i+3])
+			if errReply != nil {
+				return nil, errReply
+			}
+			i += 4
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// checkUnsignedOverflow 检查 value+incr 是否超出 width 位无符号整数的范围, 与 redis 的实现一致
+// 返回值非 0 表示溢出, limit 为按照策略处理后的值
+func checkUnsignedOverflow(value uint64, incr int64, width uint, overflow int) (int, uint64) {
+	max := uint64(1)<<width - 1
+	maxIncr := int64(max - value)
+	minIncr := -int64(value)
+	wrap := (value + uint64(incr)) & max
+	if value > max || (incr > 0 && incr > maxIncr) {
+		if overflow == overflowSat {
+			return 1, max
+		}
+		return 1, wrap
+	} else if incr < 0 && incr < minIncr {
+		if overflow == overflowSat {
+			return -1, 0
+		}
+		return -1, wrap
+	}
+	return 0, 0
+}
+
+// checkSignedOverflow 检查 value+incr 是否超出 width 位有符号整数的范围, 与 redis 的实现一致
+func checkSignedOverflow(value int64, incr int64, width uint, overflow int) (int, int64) {
+	max := int64(uint64(1)<<(width-1) - 1)
+	min := -max - 1
+	maxIncr := int64(uint64(max) - uint64(value))
+	minIncr := min - value
+
+	// 按照无符号整数相加, 然后根据符号位扩展
+	wrap := uint64(value) + uint64(incr)
+	if width < 64 {
+		mask := ^uint64(0) << width
+		if wrap&(1<<(width-1)) != 0 {
+			wrap |= mask
+		} else {
+			wrap &^= mask
+		}
+	}
+
+	if value > max || (width != 64 && incr > maxIncr) || (value >= 0 && incr > 0 && incr > maxIncr) {
+		if overflow == overflowSat {
+			return 1, max
+		}
+		return 1, int64(wrap)
+	} else if value < min || (width != 64 && incr < minIncr) || (value < 0 && incr < 0 && incr < minIncr) {
+		if overflow == overflowSat {
+			return -1, min
+		}
+		return -1, int64(wrap)
+	}
+	return 0, 0
+}
+
+// execBitFieldOps 在 value 上依次执行 ops, 返回每个操作的结果
+func execBitFieldOps(value []byte, ops []*bitFieldOp) []resp.Reply {
+	results := make([]resp.Reply, len(ops))
+	for i, op := range ops {
+		if op.signed {
+			old := bitmap.GetSigned(value, op.offset, op.width)
+			if op.opcode == bitFieldGet {
+				results[i] = reply.MakeIntReply(old)
+				continue
+			}
+			var newVal, limit int64
+			var overflowed int
+			if op.opcode == bitFieldIncrBy {
+				newVal = old + op.value
+				overflowed, limit = checkSignedOverflow(old, op.value, op.width, op.overflow)
+			} else {
+				newVal = op.value
+				overflowed, limit = checkSignedOverflow(newVal, 0, op.width, op.overflow)
+			}
+			if overflowed != 0 {
+				if op.overflow == overflowFail {
+					results[i] = reply.MakeNullBulkReply()
+					continue
+				}
+				newVal = limit
+			}
+			ret := old
+			if op.opcode == bitFieldIncrBy {
+				ret = newVal
+			}
+			bitmap.SetUnsigned(value, op.offset, op.width, uint64(newVal))
+			results[i] = reply.MakeIntReply(ret)
+		} else {
+			old := bitmap.GetUnsigned(value, op.offset, op.width)
+			if op.opcode == bitFieldGet {
+				results[i] = reply.MakeIntReply(int64(old))
+				continue
+			}
+			var newVal, limit uint64
+			var overflowed int
+			if op.opcode == bitFieldIncrBy {
+				newVal = old + uint64(op.value)
+				overflowed, limit = checkUnsignedOverflow(old, op.value, op.width, op.overflow)
+			} else {
+				newVal = uint64(op.value)
+				overflowed, limit = checkUnsignedOverflow(newVal, 0, op.width, op.overflow)
+			}
+			if overflowed != 0 {
+				if op.overflow == overflowFail {
+					results[i] = reply.MakeNullBulkReply()
+					continue
+				}
+				newVal = limit
+			}
+			ret := old
+			if op.opcode == bitFieldIncrBy {
+				ret = newVal
+			}
+			bitmap.SetUnsigned(value, op.offset, op.width, newVal)
+			results[i] = reply.MakeIntReply(int64(ret))
+		}
+	}
+	return results
+}
+
+// bitField 是 BITFIELD 和 BITFIELD_RO 的公共实现
+func bitField(db *DB, args [][]byte, readOnly bool) resp.Reply {
+	key := string(args[0])
+	ops, errReply := parseBitFieldOps(args[1:], readOnly)
+	if errReply != nil {
+		return errReply
+	}
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	// 有写操作时, 先将字符串扩展到所有操作需要的长度
+	maxOffset := int64(-1)
+	for _, op := range ops {
+		if op.opcode != bitFieldGet {
+			if end := op.offset + int64(op.width) - 1; end > maxOffset {
+				maxOffset = end
+			}
+		}
+	}
+	if maxOffset < 0 {
+		return reply.MakeMultiRawReply(execBitFieldOps(old, ops))
+	}
+	value := bitmap.Grow(old, maxOffset)
+	results := execBitFieldOps(value, ops)
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "setbit", key)
+	return reply.MakeMultiRawReply(results)
+}
+
+// execBitField 将字符串视为整数数组进行读写
+// BITFIELD key [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value | INCRBY encoding offset increment ...]
+func execBitField(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, false)
+}
+
+// execBitFieldRO 是只读版本的 BITFIELD
+// BITFIELD_RO key [GET encoding offset ...]
+func execBitFieldRO(db *DB, args [][]byte) resp.Reply {
+	return bitField(db, args, true)
+}
+
+func init() {
+	registerCommand("SetBit", execSetBit, 4, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("GetBit", execGetBit, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("BitCount", execBitCount, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("BitPos", execBitPos, -3, FlagReadOnly, 1, 1, 1)
+	registerCommand("BitOp", execBitOp, -4, FlagWrite|FlagDenyOOM, 2, -1, 1)
+	registerCommand("BitField", execBitField, -2, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("BitField_RO", execBitFieldRO, -2, FlagReadOnly|FlagFast, 1, 1, 1)
+}
@@ -0,0 +1,334 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 阻塞命令(BLPOP 等)的实现
+ * 命令无法立即执行时, 客户端会在它等待的每个 key 上排队, 然后释放数据库锁并等待
+ * 写命令使 key 变为可用时记录这个 key, 在释放 key 锁之后按照 FIFO 的顺序替排队的客户端重新执行命令, 并将结果交给客户端
+ * 替客户端执行命令时只锁定它的命令涉及的 key, 不需要数据库的排它锁, 存在被阻塞的客户端时其它命令仍然可以并发执行
+ */
+
+// blockingKey 是阻塞的 key, 由数据库编号和 key 组成
+type blockingKey struct {
+	dbIndex int
+	key     string
+}
+
+// blockRequest 描述一个无法立即执行的阻塞命令
+type blockRequest struct {
+	keys    []string
+	timeout time.Duration // 0 表示一直等待
+	// try 尝试在 db 上执行命令, 返回 nil 表示仍然无法执行
+	// key 被写入、删除或者数据库被清空时都会重新调用 try
+	try func(db *DB) resp.Reply
+	// timeoutReply 超时时返回的回复
+	timeoutReply resp.Reply
+	// writeKeys 是 try 除 keys 之外还会写入的 key, 例如 BLMOVE 的 destination, 重新执行命令时需要一起锁定
+	writeKeys []string
+}
+
+// lockKeys 返回重新执行命令时需要锁定的所有 key
+func (req *blockRequest) lockKeys() []string {
+	keys := make([]string, 0, len(req.keys)+len(req.writeKeys))
+	keys = append(keys, req.keys...)
+	return append(keys, req.writeKeys...)
+}
+
+// blockedClient 是被阻塞的客户端
+type blockedClient struct {
+	conn    resp.Connection
+	dbIndex int
+	req     *blockRequest
+	result  chan resp.Reply // 缓冲为 1, 由替客户端执行命令的一方写入
+	cancel  chan struct{}   // 客户端断开连接或数据库关闭时关闭
+}
+
+// blockingState 记录所有被阻塞的客户端
+// 持有共享锁的命令会并发地记录可用的 key 和替客户端执行命令, 因此由自己的 mu 保护
+// 客户端只会在持有 StandaloneDatabase.mu 的排它锁时进入阻塞状态
+type blockingState struct {
+	mu       sync.Mutex
+	queues   map[blockingKey][]*blockedClient // 在每个 key 上排队的客户端
+	clients  map[resp.Connection]*blockedClient
+	ready    []blockingKey // 有数据写入的 key, 在命令释放 key 锁之后处理
+	readySet map[blockingKey]struct{}
+	closed   bool
+
+	// 以下计数在持有 mu 时修改, 可以不加锁读取, 没有被阻塞的客户端时命令不需要获取 mu
+	numBlocked int64 // 被阻塞的客户端个数
+	numReady   int64 // ready 的长度
+}
+
+func makeBlockingState() *blockingState {
+	return &blockingState{
+		queues:   make(map[blockingKey][]*blockedClient),
+		clients:  make(map[resp.Connection]*blockedClient),
+		readySet: make(map[blockingKey]struct{}),
+	}
+}
+
+// signalKeyAsReady 标记 key 有数据写入, 只有存在等待该 key 的客户端时才需要记录
+// 客户端只会在持有排它锁时进入阻塞状态, 所以持有共享锁的命令读取到的 numBlocked 不会小于实际值
+func (state *blockingState) signalKeyAsReady(dbIndex int, key string) {
+	if atomic.LoadInt64(&state.numBlocked) == 0 {
+		return
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.markReady(blockingKey{dbIndex: dbIndex, key: key})
+}
+
+// markReady 记录可用的 key, 调用者必须持有 state.mu
+func (state *blockingState) markReady(bk blockingKey) {
+	if _, ok := state.queues[bk]; !ok {
+		return
+	}
+	if _, ok := state.readySet[bk]; ok {
+		return
+	}
+	state.readySet[bk] = struct{}{}
+	state.ready = append(state.ready, bk)
+	atomic.AddInt64(&state.numReady, 1)
+}
+
+// nextReadyKey 取出下一个可用的 key 以及在它上面排队的客户端, 没有可用的 key 时 ok 为 false
+func (state *blockingState) nextReadyKey() (bk blockingKey, queue []*blockedClient, ok bool) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if len(state.ready) == 0 {
+		state.ready = nil
+		return bk, nil, false
+	}
+	bk = state.ready[0]
+	state.ready = state.ready[1:]
+	delete(state.readySet, bk)
+	atomic.AddInt64(&state.numReady, -1)
+	return bk, append([]*blockedClient(nil), state.queues[bk]...), true
+}
+
+// isBlocked 判断客户端是否仍然在等待
+func (state *blockingState) isBlocked(client *blockedClient) bool {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.clients[client.conn] == client
+}
+
+// block 将客户端加入等待队列, 调用者必须持有 state.mu
+func (state *blockingState) block(client *blockedClient) {
+	state.clients[client.conn] = client
+	atomic.AddInt64(&state.numBlocked, 1)
+	for _, key := range client.req.keys {
+		bk := blockingKey{dbIndex: client.dbIndex, key: key}
+		queue := state.queues[bk]
+		// 同一个 key 可能在命令中出现多次, 只需要排队一次
+		duplicated := false
+		for _, c := range queue {
+			if c == client {
+				duplicated = true
+				break
+			}
+		}
+		if !duplicated {
+			state.queues[bk] = append(queue, client)
+		}
+	}
+}
+
+// unblock 将客户端从所有等待队列中移除, 调用者必须持有 state.mu
+func (state *blockingState) unblock(client *blockedClient) {
+	if state.clients[client.conn] != client {
+		return
+	}
+	delete(state.clients, client.conn)
+	atomic.AddInt64(&state.numBlocked, -1)
+	for _, key := range client.req.keys {
+		bk := blockingKey{dbIndex: client.dbIndex, key: key}
+		queue := state.queues[bk]
+		for i, c := range queue {
+			if c == client {
+				queue = append(queue[:i:i], queue[i+1:]...)
+				break
+			}
+		}
+		if len(queue) == 0 {
+			delete(state.queues, bk)
+		} else {
+			state.queues[bk] = queue
+		}
+	}
+}
+
+// closeChecker 是可以判断客户端是否已经断开连接的连接
+type closeChecker interface {
+	IsClosed() bool
+}
+
+// blockClient 在 key 可用、超时、客户端断开连接或数据库关闭之前阻塞当前客户端
+// 调用者必须持有 mdb.mu 的排它锁, 等待期间会释放锁, 返回前重新获取锁
+func (mdb *StandaloneDatabase) blockClient(c resp.Connection, req *blockRequest) resp.Reply {
+	state := mdb.blocking
+	// 客户端可能在这条命令执行之前就已经断开连接, 此时 AfterClientClose 已经执行过, 不会再释放阻塞状态
+	// 断开连接在 AfterClientClose 获取排它锁之前记录, 所以持有排它锁时读取到的状态是准确的
+	if cc, ok := c.(closeChecker); ok && cc.IsClosed() {
+		return req.timeoutReply
+	}
+	client := &blockedClient{
+		conn:    c,
+		dbIndex: c.GetDBIndex(),
+		req:     req,
+		result:  make(chan resp.Reply, 1),
+		cancel:  make(chan struct{}),
+	}
+	state.mu.Lock()
+	if state.closed {
+		state.mu.Unlock()
+		return req.timeoutReply
+	}
+	state.block(client)
+	state.mu.Unlock()
+
+	var timeout <-chan time.Time
+	if req.timeout > 0 {
+		timer := time.NewTimer(req.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	mdb.mu.Unlock()
+	select {
+	case result := <-client.result:
+		mdb.mu.Lock()
+		return result
+	case <-timeout:
+	case <-client.cancel:
+	}
+	mdb.mu.Lock()
+
+	// 超时的同时可能已经有其它客户端替它执行了命令, 此时必须返回执行结果, 否则数据会丢失
+	// 替客户端执行命令的一方持有共享锁或排它锁, 所以持有排它锁之后不会再有新的结果
+	select {
+	case result := <-client.result:
+		return result
+	default:
+	}
+	state.mu.Lock()
+	state.unblock(client)
+	state.mu.Unlock()
+	return req.timeoutReply
+}
+
+// handleClientsBlockedOnKeys 替等待可用 key 的客户端执行命令
+// 调用者必须持有 mdb.mu 的共享锁或排它锁, 并且不能持有任何 key 锁
+func (mdb *StandaloneDatabase) handleClientsBlockedOnKeys() {
+	state := mdb.blocking
+	if atomic.LoadInt64(&state.numReady) == 0 {
+		return
+	}
+	// 替客户端执行命令可能使更多的 key 变为可用, 例如 BLMOVE
+	for {
+		bk, queue, ok := state.nextReadyKey()
+		if !ok {
+			return
+		}
+		db := mdb.dbSet[bk.dbIndex]
+		// 按照排队的顺序逐个尝试, 不能在第一个无法执行的客户端处停止:
+		// XREAD 等只读命令等待的 ID 各不相同, 排在后面的客户端可能已经可以执行
+		for _, client := range queue {
+			mdb.serveBlockedClient(db, client)
+		}
+	}
+}
+
+// serveBlockedClient 锁定客户端的命令涉及的 key 之后替它重新执行命令, 执行成功时解除阻塞并将结果交给客户端
+// 多个协程可能同时处理同一个客户端等待的不同 key, 它们在相同的 key 锁上串行执行, 只有第一个执行成功的协程会交付结果
+func (mdb *StandaloneDatabase) serveBlockedClient(db *DB, client *blockedClient) {
+	state := mdb.blocking
+	keys := client.req.lockKeys()
+	db.locker.Locks(keys...)
+	defer db.locker.Unlocks(keys...)
+	if !state.isBlocked(client) {
+		return
+	}
+	result := client.req.try(db)
+	if result == nil {
+		return
+	}
+	state.mu.Lock()
+	state.unblock(client)
+	state.mu.Unlock()
+	db.updateMemory(keys...)
+	client.result <- result
+}
+
+// signalAllKeysAsReady 将数据库中所有被等待的 key 标记为可用, 用于 FLUSHDB、FLUSHALL 和 SWAPDB
+func (state *blockingState) signalAllKeysAsReady(dbIndex int) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for bk := range state.queues {
+		if bk.dbIndex == dbIndex {
+			state.markReady(bk)
+		}
+	}
+}
+
+// unblockClient 在客户端断开连接后释放它的阻塞状态, 调用者必须持有 mdb.mu 的排它锁
+func (mdb *StandaloneDatabase) unblockClient(c resp.Connection) {
+	state := mdb.blocking
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	client, ok := state.clients[c]
+	if !ok {
+		return
+	}
+	state.unblock(client)
+	close(client.cancel)
+}
+
+// unblockAll 在数据库关闭时释放所有被阻塞的客户端, 调用者必须持有 mdb.mu 的排它锁
+func (mdb *StandaloneDatabase) unblockAll() {
+	state := mdb.blocking
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.closed = true
+	for _, client := range state.clients {
+		state.unblock(client)
+		close(client.cancel)
+	}
+}
+
+// parseTimeout 解析以秒为单位的超时时间, 可以是小数, 0 表示一直等待
+func parseTimeout(arg []byte) (time.Duration, resp.ErrorReply) {
+	seconds, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+		return 0, reply.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if seconds < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	if seconds > float64(math.MaxInt64/int64(time.Second)) {
+		return 0, reply.MakeErrReply("ERR timeout is out of range")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// execBlocking 先尝试立即执行阻塞命令, 无法执行时阻塞客户端
+func execBlocking(mdb *StandaloneDatabase, c resp.Connection, req *blockRequest) resp.Reply {
+	db, errReply := mdb.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	if result := req.try(db); result != nil {
+		return result
+	}
+	return mdb.blockClient(c, req)
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+)
+
+// ExecFunc 是在单个 DB 上执行的命令的执行函数
+// args 不包含命令名
+type ExecFunc func(db *DB, args [][]byte) resp.Reply
+
+// SysExecFunc 是需要访问客户端连接或多个 DB 的命令的执行函数, 例如 SELECT、SWAPDB
+// args 不包含命令名
+type SysExecFunc func(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply
+
+// 命令标志, 可以组合使用
+const (
+	FlagWrite    = 1 << iota // 可能修改数据
+	FlagReadOnly             // 只读取数据
+	FlagAdmin                // 管理命令
+	FlagPubSub               // 发布订阅相关命令
+	FlagNoScript             // 不允许在脚本中执行
+	FlagFast                 // 时间复杂度为 O(1) 或 O(log(N))
+	FlagBlocking             // 可能阻塞客户端
+	FlagDenyOOM              // 可能增加内存, 已用内存超过 maxmemory 并且无法淘汰时拒绝执行
+)
+
+// flagNames 是命令标志在 COMMAND 命令中的名称
+var flagNames = []struct {
+	flag int
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadOnly, "readonly"},
+	{FlagAdmin, "admin"},
+	{FlagPubSub, "pubsub"},
+	{FlagNoScript, "noscript"},
+	{FlagFast, "fast"},
+	{FlagBlocking, "blocking"},
+	{FlagDenyOOM, "denyoom"},
+}
+
+// Command 记录命令的执行函数以及元数据
+// 元数据可以被 ACL、集群路由和 AOF 过滤复用
+type Command struct {
+	name        string
+	executor    ExecFunc
+	sysExecutor SysExecFunc
+	arity       int // arity > 0 表示参数个数(包含命令名)必须等于 arity, arity < 0 表示参数个数至少为 -arity
+	flags       int
+	firstKey    int // 第一个 key 的位置, 0 表示命令没有 key
+	lastKey     int // 最后一个 key 的位置, 负数表示从末尾倒数, -1 表示最后一个参数
+	keyStep     int // 相邻 key 之间的间隔
+	// keysFunc 用于 key 的位置不固定的命令, 例如 LMPOP numkeys key [key ...]
+	keysFunc func(cmdLine [][]byte) []string
+}
+
+// cmdTable 命令表, 命令名均为小写
+var cmdTable = make(map[string]*Command)
+
+// registerCommand 注册在单个 DB 上执行的命令
+func registerCommand(name string, executor ExecFunc, arity int, flags int, firstKey int, lastKey int, keyStep int) *Command {
+	cmd := newCommand(name, arity, flags, firstKey, lastKey, keyStep)
+	cmd.executor = executor
+	return cmd
+}
+
+// registerSysCommand 注册需要访问客户端连接或多个 DB 的命令
+func registerSysCommand(name string, executor SysExecFunc, arity int, flags int, firstKey int, lastKey int, keyStep int) *Command {
+	cmd := newCommand(name, arity, flags, firstKey, lastKey, keyStep)
+	cmd.sysExecutor = executor
+	return cmd
+}
+
+func newCommand(name string, arity int, flags int, firstKey int, lastKey int, keyStep int) *Command {
+	name = strings.ToLower(name)
+	cmd := &Command{
+		name:     name,
+		arity:    arity,
+		flags:    flags,
+		firstKey: firstKey,
+		lastKey:  lastKey,
+		keyStep:  keyStep,
+	}
+	cmdTable[name] = cmd
+	return cmd
+}
+
+// setKeysFunc 设置提取 key 的函数, 用于 key 的位置不固定的命令
+func (cmd *Command) setKeysFunc(keysFunc func(cmdLine [][]byte) []string) *Command {
+	cmd.keysFunc = keysFunc
+	return cmd
+}
+
+// LookupCommand 根据命令名(不区分大小写)查找命令
+func LookupCommand(name string) (*Command, bool) {
+	cmd, ok := cmdTable[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// Name 返回小写的命令名
+func (cmd *Command) Name() string {
+	return cmd.name
+}
+
+// Arity 返回命令的参数个数约束
+func (cmd *Command) Arity() int {
+	return cmd.arity
+}
+
+// Flags 返回命令标志
+func (cmd *Command) Flags() int {
+	return cmd.flags
+}
+
+// HasFlag 判断命令是否包含指定的标志
+func (cmd *Command) HasFlag(flag int) bool {
+	return cmd.flags&flag != 0
+}
+
+// KeyPositions 返回第一个 key 的位置、最后一个 key 的位置以及 key 之间的间隔
+func (cmd *Command) KeyPositions() (firstKey int, lastKey int, keyStep int) {
+	return cmd.firstKey, cmd.lastKey, cmd.keyStep
+}
+
+// FlagNames 返回命令标志的名称
+func (cmd *Command) FlagNames() []string {
+	names := make([]string, 0, len(flagNames)+1)
+	for _, f := range flagNames {
+		if cmd.HasFlag(f.flag) {
+			names = append(names, f.name)
+		}
+	}
+	if cmd.keysFunc != nil {
+		names = append(names, "movablekeys")
+	}
+	return names
+}
+
+// ValidateArity 校验参数个数, cmdLine 包含命令名
+func (cmd *Command) ValidateArity(cmdLine [][]byte) bool {
+	argNum := len(cmdLine)
+	if cmd.arity >= 0 {
+		return argNum == cmd.arity
+	}
+	return argNum >= -cmd.arity
+}
+
+// GetKeys 根据 key 的位置信息从命令行中取出所有的 key, cmdLine 包含命令名
+func (cmd *Command) GetKeys(cmdLine [][]byte) []string {
+	if cmd.keysFunc != nil {
+		return cmd.keysFunc(cmdLine)
+	}
+	if cmd.firstKey <= 0 || len(cmdLine) <= cmd.firstKey {
+		return nil
+	}
+	last := cmd.lastKey
+	if last < 0 {
+		last = len(cmdLine) + last
+	}
+	if last >= len(cmdLine) {
+		last = len(cmdLine) - 1
+	}
+	step := cmd.keyStep
+	if step <= 0 {
+		step = 1
+	}
+	keys := make([]string, 0, (last-cmd.firstKey)/step+1)
+	for i := cmd.firstKey; i <= last; i += step {
+		keys = append(keys, string(cmdLine[i]))
+	}
+	return keys
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"runtime/debug"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/lib/logger"
+)
+
+// hz 的取值范围, 与 redis 一致
+const (
+	minHz = 1
+	maxHz = 500
+)
+
+// activeExpireTimePercent 主动过期每轮最多占用定时任务间隔的百分比
+const activeExpireTimePercent = 25
+
+// activeRehashTime 每轮定时任务中渐进式 rehash 最多占用的时间, 与 redis 相同为 1 毫秒
+const activeRehashTime = time.Millisecond
+
+// cronInterval 返回定时任务的执行间隔, 每秒执行 config.Properties.Hz 次
+func cronInterval() time.Duration {
+	hz := config.Properties.Hz
+	if hz < minHz {
+		hz = minHz
+	}
+	if hz > maxHz {
+		hz = maxHz
+	}
+	return time.Second / time.Duration(hz)
+}
+
+// cron 定时执行后台任务, 直到数据库关闭
+func (mdb *StandaloneDatabase) cron() {
+	interval := cronInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			mdb.runCron(interval)
+		case <-mdb.stopCron:
+			return
+		}
+	}
+}
+
+// runCron 在持有排它锁的情况下执行一次后台任务, interval 为执行间隔
+func (mdb *StandaloneDatabase) runCron(interval time.Duration) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn("error occurs in cron: " + string(debug.Stack()))
+		}
+	}()
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	defer mdb.handleClientsBlockedOnKeys()
+	// 主动过期的时间限制, 避免长时间持有锁
+	deadline := time.Now().Add(interval * activeExpireTimePercent / 100)
+	for _, db := range mdb.dbSet {
+		db.activeExpireKeys(deadline)
+		db.activeExpireHashFields()
+	}
+	if config.Properties.ActiveRehashing {
+		mdb.activeRehash()
+	}
+	mdb.updatePeakMemory(mdb.allocatedMemory())
+}
+
+// activeRehash 为正在 rehash 的键空间迁移元素, 使没有写操作的键空间也能完成 rehash 并释放旧的哈希表
+// 与 redis 相同, 每轮只处理第一个需要迁移的 DB
+func (mdb *StandaloneDatabase) activeRehash() {
+	for _, db := range mdb.dbSet {
+		deadline := time.Now().Add(activeRehashTime)
+		if db.data.Rehash(deadline) || db.ttlMap.Rehash(deadline) {
+			return
+		}
+	}
+}
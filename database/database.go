@@ -0,0 +1,347 @@
+package database
+
+import (
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/lib/logger"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// 默认数据库数量, 与 redis 保持一致
+const defaultDatabases = 16
+
+// StandaloneDatabase 是单机模式下的数据库, 包含多个相互独立的 DB
+type StandaloneDatabase struct {
+	dbSet    []*DB          // 所有的 DB, 下标即数据库编号
+	blocking *blockingState // 被阻塞的客户端
+	pubsub   *pubsubHub     // 发布订阅的订阅关系
+	eviction evictionState  // maxmemory 淘汰池
+
+	// 执行过命令并且尚未关闭的客户端, 用于 MEMORY STATS 估算客户端占用的内存
+	clients    sync.Map // resp.Connection -> struct{}
+	numClients int64    // clients 中的客户端个数, 原子读写
+	// 启动时 Go 堆上已分配的内存以及已用内存的峰值, 由 mu 的排它锁保护
+	startupMemory int64
+	peakMemory    int64
+
+	// 访问固定 key 的命令持有共享锁, 再通过 DB.locker 锁定涉及的 key;
+	// 访问整个数据库、多个 DB 或者阻塞状态的命令以及定时任务持有排它锁
+	mu sync.RWMutex
+
+	stopCron  chan struct{} // 关闭后定时任务退出
+	closeOnce sync.Once
+}
+
+// NewStandaloneDatabase 按照 config.Properties.Databases 创建 StandaloneDatabase
+func NewStandaloneDatabase() *StandaloneDatabase {
+	if config.Properties.Databases <= 0 {
+		config.Properties.Databases = defaultDatabases
+	}
+	if _, ok := parseKeyspaceEvents(config.Properties.NotifyKeyspaceEvents); !ok {
+		logger.Warn("invalid notify-keyspace-events: " + config.Properties.NotifyKeyspaceEvents)
+	}
+	mdb := &StandaloneDatabase{
+		blocking: makeBlockingState(),
+		pubsub:   makePubsubHub(),
+		stopCron: make(chan struct{}),
+	}
+	mdb.dbSet = make([]*DB, config.Properties.Databases)
+	for i := range mdb.dbSet {
+		db := makeDB()
+		db.index = i
+		db.blocking = mdb.blocking
+		db.pubsub = mdb.pubsub
+		mdb.dbSet[i] = db
+	}
+	mdb.startupMemory = int64(readMemStats().HeapAlloc)
+	go mdb.cron()
+	return mdb
+}
+
+// Exec 执行命令
+// 参数 cmdLine 包含命令名和参数, 例如: set key value
+func (mdb *StandaloneDatabase) Exec(c resp.Connection, cmdLine [][]byte) (result resp.Reply) {
+	// 订阅确认等消息经过发送队列写入, 返回回复之前等待它们写完, 保证客户端按照命令的顺序收到回复
+	// 最先注册的 defer 最后执行, 此时已经释放了数据库的锁
+	defer mdb.pubsub.flush(c)
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn("error occurs: " + string(debug.Stack()))
+			result = &reply.UnknownErrReply{}
+		}
+	}()
+	if len(cmdLine) == 0 {
+		return reply.MakeErrReply("ERR empty command")
+	}
+
+	if _, ok := mdb.clients.Load(c); !ok {
+		if _, loaded := mdb.clients.LoadOrStore(c, struct{}{}); !loaded {
+			atomic.AddInt64(&mdb.numClients, 1)
+		}
+	}
+
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return reply.MakeErrReply(unknownCommandErr(cmdName, cmdLine[1:]))
+	}
+	if !cmd.ValidateArity(cmdLine) {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	if _, ok := subscribeContextCommands[cmdName]; !ok && mdb.pubsub.subscriptionCount(c) > 0 {
+		return reply.MakeErrReply("ERR Can't execute '" + cmdName + "': only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT are allowed in this context")
+	}
+	// 与 redis 相同, 所有命令执行之前都会尝试淘汰, 但是只拒绝可能增加内存的命令
+	if !mdb.evictIfNeeded() && cmd.HasFlag(FlagDenyOOM) {
+		return reply.MakeErrReply(errOOM)
+	}
+
+	if cmd.sysExecutor == nil && (cmd.firstKey > 0 || cmd.keysFunc != nil) {
+		return mdb.execWithKeyLocks(c, cmd, cmdLine)
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	// 命令执行后, 替等待可用 key 的客户端执行命令
+	defer mdb.handleClientsBlockedOnKeys()
+	if cmd.sysExecutor != nil {
+		result = cmd.sysExecutor(mdb, c, cmdLine[1:])
+	} else {
+		db, errReply := mdb.selectDB(c.GetDBIndex())
+		if errReply != nil {
+			return errReply
+		}
+		result = cmd.executor(db, cmdLine[1:])
+	}
+	if db, errReply := mdb.selectDB(c.GetDBIndex()); errReply == nil {
+		if cmd.HasFlag(FlagWrite) {
+			db.updateMemory(cmd.GetKeys(cmdLine)...)
+		} else if cmd.HasFlag(FlagReadOnly) {
+			db.notifyKeyMiss(cmd.GetKeys(cmdLine))
+		}
+	}
+	return result
+}
+
+// execWithKeyLocks 持有共享锁并锁定命令涉及的所有 key 后执行命令, 访问不同 key 的命令可以并发执行
+// 写命令使被等待的 key 变为可用时, 在释放 key 锁之后替被阻塞的客户端重新执行命令
+func (mdb *StandaloneDatabase) execWithKeyLocks(c resp.Connection, cmd *Command, cmdLine [][]byte) resp.Reply {
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+	db, errReply := mdb.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	// defer 按照相反的顺序执行, 先释放 key 锁再处理被阻塞的客户端
+	defer mdb.handleClientsBlockedOnKeys()
+	// 只读命令也可能删除已过期的 key 或 field, 所以不区分读写, 所有的 key 都加排它锁
+	keys := cmd.GetKeys(cmdLine)
+	db.locker.Locks(keys...)
+	defer db.locker.Unlocks(keys...)
+	result := cmd.executor(db, cmdLine[1:])
+	if cmd.HasFlag(FlagWrite) {
+		db.updateMemory(keys...)
+	} else if cmd.HasFlag(FlagReadOnly) {
+		db.notifyKeyMiss(keys)
+	}
+	return result
+}
+
+// unknownCommandErr 生成与 redis 一致的未知命令错误信息
+func unknownCommandErr(cmdName string, args [][]byte) string {
+	var sb strings.Builder
+	sb.WriteString("ERR unknown command '" + cmdName + "', with args beginning with: ")
+	for _, arg := range args {
+		// 与 redis 一样, 只展示前 128 字节的参数
+		if sb.Len()+len(arg) > 128 {
+			break
+		}
+		sb.WriteString("'" + string(arg) + "' ")
+	}
+	return sb.String()
+}
+
+// AfterClientClose 在客户端关闭后调用, 用于清理客户端相关的资源
+// 可能被调用多次, 例如客户端在阻塞期间断开连接
+func (mdb *StandaloneDatabase) AfterClientClose(c resp.Connection) {
+	if _, loaded := mdb.clients.LoadAndDelete(c); loaded {
+		atomic.AddInt64(&mdb.numClients, -1)
+	}
+	mdb.pubsub.unsubscribeAll(c)
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.unblockClient(c)
+}
+
+// Close 关闭数据库
+func (mdb *StandaloneDatabase) Close() {
+	mdb.closeOnce.Do(func() {
+		close(mdb.stopCron)
+	})
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	mdb.unblockAll()
+}
+
+// selectDB 返回编号为 dbIndex 的 DB
+func (mdb *StandaloneDatabase) selectDB(dbIndex int) (*DB, *reply.StandardErrReply) {
+	if dbIndex < 0 || dbIndex >= len(mdb.dbSet) {
+		return nil, reply.MakeErrReply("ERR DB index is out of range")
+	}
+	return mdb.dbSet[dbIndex], nil
+}
+
+// parseDBIndex 解析并校验数据库编号
+func (mdb *StandaloneDatabase) parseDBIndex(arg []byte) (int, *reply.StandardErrReply) {
+	dbIndex, err := strconv.Atoi(string(arg))
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if dbIndex < 0 || dbIndex >= len(mdb.dbSet) {
+		return 0, reply.MakeErrReply("ERR DB index is out of range")
+	}
+	return dbIndex, nil
+}
+
+// execSelect 切换客户端当前使用的数据库
+// SELECT index
+func execSelect(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	dbIndex, errReply := mdb.parseDBIndex(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	c.SelectDB(dbIndex)
+	return reply.MakeOkReply()
+}
+
+// execPing 检查连接是否可用
+// PING [message]
+func execPing(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	if len(args) > 1 {
+		return reply.MakeArgNumErrReply("ping")
+	}
+	// 与 redis 相同, 订阅状态下回复 [pong, message], 并且与订阅的消息一起按顺序发送
+	if mdb.pubsub.subscriptionCount(c) > 0 {
+		message := []byte{}
+		if len(args) == 1 {
+			message = args[0]
+		}
+		mdb.pubsub.send(c, reply.MakeMultiBulkReply([][]byte{[]byte("pong"), message}).ToBytes())
+		return reply.MakeNoReply()
+	}
+	if len(args) == 1 {
+		return reply.MakeBulkReply(args[0])
+	}
+	return reply.MakeStatusReply("PONG")
+}
+
+// execEcho 返回参数
+// ECHO message
+func execEcho(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return reply.MakeBulkReply(args[0])
+}
+
+// execQuit 回复 OK, 连接由处理程序在发送回复之后关闭
+// QUIT
+func execQuit(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return reply.MakeOkReply()
+}
+
+// execFlushAll 清空所有数据库
+// FLUSHALL [ASYNC|SYNC]
+func execFlushAll(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	if !validFlushArgs(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	for _, db := range mdb.dbSet {
+		db.Flush()
+	}
+	return reply.MakeOkReply()
+}
+
+// execSwapDB 交换两个数据库, 所有连接到这两个数据库的客户端会立即看到对方的数据
+// SWAPDB index1 index2
+func execSwapDB(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	first, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return reply.MakeErrReply("ERR invalid first DB index")
+	}
+	second, err := strconv.Atoi(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply("ERR invalid second DB index")
+	}
+	if first < 0 || first >= len(mdb.dbSet) || second < 0 || second >= len(mdb.dbSet) {
+		return reply.MakeErrReply("ERR DB index is out of range")
+	}
+	db1, db2 := mdb.dbSet[first], mdb.dbSet[second]
+	db1.index, db2.index = second, first
+	mdb.dbSet[first], mdb.dbSet[second] = db2, db1
+	// 被阻塞的客户端仍然等待原来编号的数据库, 交换后数据可能已经可用
+	mdb.blocking.signalAllKeysAsReady(first)
+	mdb.blocking.signalAllKeysAsReady(second)
+	return reply.MakeOkReply()
+}
+
+// execMove 将当前数据库中的 key 移动到目标数据库
+// MOVE key db
+func execMove(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	key := string(args[0])
+	srcDB, errReply := mdb.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	dstIndex, errReply := mdb.parseDBIndex(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if dstIndex == srcDB.index {
+		return reply.MakeErrReply("ERR source and destination objects are the same")
+	}
+	dstDB := mdb.dbSet[dstIndex]
+
+	entity, exists := srcDB.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists = dstDB.GetEntity(key); exists {
+		return reply.MakeIntReply(0)
+	}
+	dstDB.PutEntity(key, entity)
+	if expireTime, ok := srcDB.GetExpireTime(key); ok {
+		dstDB.Expire(key, expireTime)
+	}
+	srcDB.Remove(key)
+	srcDB.notify(notifyGeneric, "move_from", key)
+	dstDB.notify(notifyGeneric, "move_to", key)
+	return reply.MakeIntReply(1)
+}
+
+func init() {
+	registerSysCommand("Ping", execPing, -1, FlagFast, 0, 0, 0)
+	registerSysCommand("Echo", execEcho, 2, FlagFast, 0, 0, 0)
+	registerSysCommand("Quit", execQuit, -1, FlagFast, 0, 0, 0)
+	registerSysCommand("Select", execSelect, 2, FlagFast, 0, 0, 0)
+	registerSysCommand("FlushAll", execFlushAll, -1, FlagWrite, 0, 0, 0)
+	registerSysCommand("SwapDB", execSwapDB, 3, FlagWrite|FlagFast, 0, 0, 0)
+	registerSysCommand("Move", execMove, 3, FlagWrite|FlagFast, 1, 1, 1)
+}
+
+// validFlushArgs 判断 FLUSHDB/FLUSHALL 的参数是否合法, 最多只能有一个 ASYNC|SYNC 选项
+// 清空数据库是破坏性的操作, 参数不合法时必须拒绝执行
+func validFlushArgs(args [][]byte) bool {
+	if len(args) == 0 {
+		return true
+	}
+	if len(args) > 1 {
+		return false
+	}
+	mode := strings.ToUpper(string(args[0]))
+	return mode == "ASYNC" || mode == "SYNC"
+}
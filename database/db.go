@@ -0,0 +1,196 @@
+package database
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/datastruct/dict"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/lib/sync/lock"
+)
+
+// 分段字典和 key 锁表的大小
+const (
+	dataDictSize        = 1 << 10
+	ttlDictSize         = 1 << 10
+	hashFieldExpireSize = 1 << 4 // 设置了 field 过期时间的哈希表通常很少
+	lockerSize          = 1 << 10
+)
+
+// DB 存储数据并执行用户命令, 每个 DB 是一个独立的键空间
+type DB struct {
+	index    int                  // 数据库编号
+	data     *dict.ConcurrentDict // key -> DataEntity
+	ttlMap   *dict.ConcurrentDict // key -> 过期时间 time.Time
+	blocking *blockingState       // 被阻塞的客户端, 所有 DB 共享
+	pubsub   *pubsubHub           // 发布键空间通知, 所有 DB 共享
+	locker   *lock.Locks          // 执行命令期间锁定命令涉及的 key
+
+	usedMemory int64 // 所有 key 估算占用的内存之和, 原子读写
+
+	// 有 field 设置了过期时间的哈希表的 key, 供定时任务主动删除过期的 field
+	// 只会在定时任务中移除, 因此其中的 key 可能已经被删除或者不再是哈希表
+	hashFieldExpires dict.Dict
+}
+
+// makeDB 创建 DB, 所有 DB 共享的 blocking 和 pubsub 由调用者设置
+func makeDB() *DB {
+	return &DB{
+		data:             dict.MakeConcurrent(dataDictSize),
+		ttlMap:           dict.MakeConcurrent(ttlDictSize),
+		locker:           lock.Make(lockerSize),
+		hashFieldExpires: dict.MakeConcurrent(hashFieldExpireSize),
+	}
+}
+
+/* ---- 数据访问 ---- */
+
+// GetEntity 返回 key 绑定的 DataEntity 并更新访问信息, 已过期的 key 会在访问时被删除
+func (db *DB) GetEntity(key string) (*database.DataEntity, bool) {
+	entity, ok := db.peekEntity(key)
+	if ok {
+		touchEntity(entity)
+	}
+	return entity, ok
+}
+
+// peekEntity 与 GetEntity 相同, 但是不更新访问信息, 用于 TYPE、EXISTS、OBJECT 等不算作访问的命令
+func (db *DB) peekEntity(key string) (*database.DataEntity, bool) {
+	raw, ok := db.data.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if db.IsExpired(key) {
+		return nil, false
+	}
+	entity, _ := raw.(*database.DataEntity)
+	return entity, true
+}
+
+// PutEntity 写入 DataEntity, 返回新插入的 key 的个数
+// 不会修改 key 的过期时间
+func (db *DB) PutEntity(key string, entity *database.DataEntity) int {
+	db.afterPut(key, entity)
+	old, _ := db.data.Get(key)
+	result := db.data.Put(key, entity)
+	db.replaceMemory(old, entity)
+	if result > 0 {
+		db.notify(notifyNew, "new", key)
+	}
+	return result
+}
+
+// PutIfExists 仅当 key 存在时写入 DataEntity
+func (db *DB) PutIfExists(key string, entity *database.DataEntity) int {
+	db.afterPut(key, entity)
+	old, _ := db.data.Get(key)
+	result := db.data.PutIfExists(key, entity)
+	if result > 0 {
+		db.replaceMemory(old, entity)
+	}
+	return result
+}
+
+// PutIfAbsent 仅当 key 不存在时写入 DataEntity
+func (db *DB) PutIfAbsent(key string, entity *database.DataEntity) int {
+	db.afterPut(key, entity)
+	result := db.data.PutIfAbsent(key, entity)
+	if result > 0 {
+		db.replaceMemory(nil, entity)
+		db.notify(notifyNew, "new", key)
+	}
+	return result
+}
+
+// afterPut 在写入 DataEntity 时调用
+func (db *DB) afterPut(key string, entity *database.DataEntity) {
+	db.initAccess(key, entity)
+	db.signalKeyAsReady(key)
+	// MOVE 等命令会将带有过期 field 的哈希表写入其它 key
+	db.trackHashFieldExpires(key, entity)
+}
+
+// Remove 删除 key 及其过期时间
+func (db *DB) Remove(key string) {
+	raw, exists := db.data.Get(key)
+	if exists && db.data.Remove(key) > 0 {
+		db.addMemory(-raw.(*database.DataEntity).Memory)
+		db.signalKeyAsReady(key)
+	}
+	db.ttlMap.Remove(key)
+}
+
+// Removes 删除多个 key 并发布 del 事件, 返回删除的 key 的个数
+func (db *DB) Removes(keys ...string) (deleted int) {
+	for _, key := range keys {
+		_, exists := db.GetEntity(key)
+		if exists {
+			db.Remove(key)
+			db.notify(notifyGeneric, "del", key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Flush 清空数据库
+func (db *DB) Flush() {
+	db.data.Clear()
+	db.ttlMap.Clear()
+	db.hashFieldExpires.Clear()
+	atomic.StoreInt64(&db.usedMemory, 0)
+	db.blocking.signalAllKeysAsReady(db.index)
+}
+
+// signalKeyAsReady 通知等待 key 的客户端 key 发生了变化, 在命令执行结束后重新尝试执行它们的命令
+func (db *DB) signalKeyAsReady(key string) {
+	db.blocking.signalKeyAsReady(db.index, key)
+}
+
+/* ---- TTL ---- */
+
+// Expire 设置 key 的过期时间
+func (db *DB) Expire(key string, expireTime time.Time) {
+	db.ttlMap.Put(key, expireTime)
+}
+
+// ExpireOrRemove 设置 key 的过期时间, 如果过期时间已经过去则直接删除 key
+// 发布 expire 或者 del 事件, 返回 key 是否被删除
+func (db *DB) ExpireOrRemove(key string, expireTime time.Time) (removed bool) {
+	if !expireTime.After(time.Now()) {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+		return true
+	}
+	db.Expire(key, expireTime)
+	db.notify(notifyGeneric, "expire", key)
+	return false
+}
+
+// Persist 取消 key 的过期时间
+func (db *DB) Persist(key string) {
+	db.ttlMap.Remove(key)
+}
+
+// GetExpireTime 返回 key 的过期时间, 如果 key 没有设置过期时间则 ok 为 false
+func (db *DB) GetExpireTime(key string) (expireTime time.Time, ok bool) {
+	raw, exists := db.ttlMap.Get(key)
+	if !exists {
+		return time.Time{}, false
+	}
+	return raw.(time.Time), true
+}
+
+// IsExpired 判断 key 是否已过期, 已过期的 key 会被删除
+func (db *DB) IsExpired(key string) bool {
+	expireTime, ok := db.GetExpireTime(key)
+	if !ok {
+		return false
+	}
+	expired := time.Now().After(expireTime)
+	if expired {
+		db.Remove(key)
+		db.notify(notifyExpired, "expired", key)
+	}
+	return expired
+}
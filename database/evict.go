@@ -0,0 +1,202 @@
+package database
+
+import (
+	"math"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/datastruct/dict"
+	"github.com/LynchQ/my-go-redis/interface/database"
+)
+
+/*
+ * maxmemory 淘汰
+ * 已用内存超过 maxmemory 时, 在执行命令之前按照 maxmemory-policy 删除 key, 直到已用内存回到限制之内
+ * 与 redis 相同, LRU、LFU 和 TTL 策略是近似的: 每轮从每个 DB 中抽样 maxmemory-samples 个 key 放入淘汰池,
+ * 淘汰池在多轮之间保留最适合淘汰的 key, 每轮淘汰池中得分最高的 key
+ * 无法释放足够的内存时, 带有 denyoom 标志的命令返回 OOM 错误, 其它命令(例如 DEL)仍然可以执行
+ */
+
+// 淘汰策略
+const (
+	policyNoEviction     = "noeviction"
+	policyAllKeysLRU     = "allkeys-lru"
+	policyVolatileLRU    = "volatile-lru"
+	policyAllKeysLFU     = "allkeys-lfu"
+	policyVolatileLFU    = "volatile-lfu"
+	policyAllKeysRandom  = "allkeys-random"
+	policyVolatileRandom = "volatile-random"
+	policyVolatileTTL    = "volatile-ttl"
+)
+
+// evictionPoolSize 是淘汰池的大小
+const evictionPoolSize = 16
+
+const errOOM = "OOM command not allowed when used memory > 'maxmemory'."
+
+// evictionCandidate 是淘汰池中的 key
+type evictionCandidate struct {
+	dbIndex int
+	key     string
+	score   int64 // 越大越适合淘汰: LRU 为空闲时间, LFU 为 255 减去访问计数, TTL 为过期时间取反
+}
+
+// evictionState 在多轮淘汰之间保留的状态, 由 StandaloneDatabase.mu 的排它锁保护
+type evictionState struct {
+	pool   []evictionCandidate // 按照 score 从小到大排序
+	nextDB int                 // random 策略下一次淘汰的 DB, 使各个 DB 轮流淘汰
+}
+
+// maxmemoryPolicy 返回小写的淘汰策略
+func maxmemoryPolicy() string {
+	return strings.ToLower(config.Properties.MaxmemoryPolicy)
+}
+
+// evictIfNeeded 在已用内存超过 maxmemory 时持有排它锁淘汰 key, 返回已用内存是否在限制之内
+func (mdb *StandaloneDatabase) evictIfNeeded() bool {
+	maxmemory := int64(config.Properties.Maxmemory)
+	if maxmemory <= 0 {
+		return true
+	}
+	mdb.mu.RLock()
+	used := mdb.usedMemory()
+	mdb.mu.RUnlock()
+	if used <= maxmemory {
+		return true
+	}
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+	defer mdb.handleClientsBlockedOnKeys()
+	for mdb.usedMemory() > maxmemory {
+		if !mdb.evictOne() {
+			return false
+		}
+	}
+	return true
+}
+
+// evictOne 按照淘汰策略删除一个 key, 没有可以淘汰的 key 时返回 false
+func (mdb *StandaloneDatabase) evictOne() bool {
+	policy := maxmemoryPolicy()
+	volatile := strings.HasPrefix(policy, "volatile-")
+	switch policy {
+	case policyAllKeysRandom, policyVolatileRandom:
+		return mdb.evictRandom(volatile)
+	case policyAllKeysLRU, policyVolatileLRU, policyAllKeysLFU, policyVolatileLFU, policyVolatileTTL:
+		return mdb.evictFromPool(policy, volatile)
+	}
+	return false
+}
+
+// evictionDict 返回淘汰时抽样的字典: allkeys 策略从所有 key 中抽样, volatile 策略只从设置了过期时间的 key 中抽样
+func (db *DB) evictionDict(volatile bool) *dict.ConcurrentDict {
+	if volatile {
+		return db.ttlMap
+	}
+	return db.data
+}
+
+// evictRandom 从各个 DB 中轮流随机淘汰一个 key
+func (mdb *StandaloneDatabase) evictRandom(volatile bool) bool {
+	state := &mdb.eviction
+	for i := 0; i < len(mdb.dbSet); i++ {
+		db := mdb.dbSet[state.nextDB]
+		state.nextDB = (state.nextDB + 1) % len(mdb.dbSet)
+		keys := db.evictionDict(volatile).RandomKeys(1)
+		if len(keys) > 0 {
+			db.Remove(keys[0])
+			db.notify(notifyEvicted, "evicted", keys[0])
+			return true
+		}
+	}
+	return false
+}
+
+// evictFromPool 抽样填充淘汰池, 然后淘汰池中得分最高并且仍然存在的 key
+func (mdb *StandaloneDatabase) evictFromPool(policy string, volatile bool) bool {
+	state := &mdb.eviction
+	for {
+		total := 0
+		for _, db := range mdb.dbSet {
+			n := db.evictionDict(volatile).Len()
+			if n > 0 {
+				total += n
+				mdb.populateEvictionPool(db, policy, volatile)
+			}
+		}
+		if total == 0 || len(state.pool) == 0 {
+			return false
+		}
+		for len(state.pool) > 0 {
+			best := state.pool[len(state.pool)-1]
+			state.pool = state.pool[:len(state.pool)-1]
+			db := mdb.dbSet[best.dbIndex]
+			// 淘汰池中的 key 可能已经被删除, 或者在 volatile 策略下不再有过期时间
+			if _, exists := db.evictionDict(volatile).Get(best.key); exists {
+				db.Remove(best.key)
+				db.notify(notifyEvicted, "evicted", best.key)
+				return true
+			}
+		}
+	}
+}
+
+// populateEvictionPool 从 db 中抽样 maxmemory-samples 个 key, 将得分较高的 key 放入淘汰池
+func (mdb *StandaloneDatabase) populateEvictionPool(db *DB, policy string, volatile bool) {
+	samples := config.Properties.MaxmemorySamples
+	if samples <= 0 {
+		samples = 1
+	}
+	now := nowMilli()
+	for _, key := range db.evictionDict(volatile).RandomDistinctKeys(samples) {
+		raw, exists := db.data.Get(key)
+		if !exists {
+			continue
+		}
+		entity := raw.(*database.DataEntity)
+		var score int64
+		switch policy {
+		case policyAllKeysLRU, policyVolatileLRU:
+			score = now - entity.AccessTime
+		case policyAllKeysLFU, policyVolatileLFU:
+			score = 255 - int64(lfuDecr(entity))
+		case policyVolatileTTL:
+			expireTime, ok := db.GetExpireTime(key)
+			if !ok {
+				continue
+			}
+			score = math.MaxInt64 - expireTime.UnixMilli()
+		}
+		mdb.eviction.insert(evictionCandidate{dbIndex: db.index, key: key, score: score})
+	}
+}
+
+// insert 将 key 按照 score 插入淘汰池, 淘汰池已满时丢弃 score 最小的 key
+func (state *evictionState) insert(candidate evictionCandidate) {
+	pool := state.pool
+	// 同一个 key 再次被抽样时更新它的 score
+	for i, c := range pool {
+		if c.dbIndex == candidate.dbIndex && c.key == candidate.key {
+			pool = append(pool[:i], pool[i+1:]...)
+			break
+		}
+	}
+	i := 0
+	for i < len(pool) && pool[i].score < candidate.score {
+		i++
+	}
+	if len(pool) >= evictionPoolSize {
+		if i == 0 {
+			state.pool = pool
+			return
+		}
+		// 丢弃 score 最小的 key, 插入位置随之前移
+		pool = pool[1:]
+		i--
+	}
+	pool = append(pool, evictionCandidate{})
+	copy(pool[i+1:], pool[i:])
+	pool[i] = candidate
+	state.pool = pool
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"math"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * key 的过期时间
+ * 过期的 key 在访问时被删除(见 GetEntity), 同时由定时任务抽样删除, 使没有被访问的 key 也能释放内存
+ */
+
+// activeExpireKeys 抽样检查设置了过期时间的 key 并删除已过期的 key
+// 如果抽样中有超过 1/4 的 key 已过期则继续下一轮, 直到 deadline
+func (db *DB) activeExpireKeys(deadline time.Time) {
+	for db.ttlMap.Len() > 0 {
+		keys := db.ttlMap.RandomDistinctKeys(activeExpireSamples)
+		expired := 0
+		for _, key := range keys {
+			if db.IsExpired(key) {
+				expired++
+			}
+		}
+		if expired*4 <= len(keys) || time.Now().After(deadline) {
+			return
+		}
+	}
+}
+
+// parseKeyExpireConditions 解析 EXPIRE 系列命令的 NX|XX|GT|LT 选项, 可以同时指定 XX 和 GT 或 LT
+func parseKeyExpireConditions(args [][]byte) ([]int, resp.ErrorReply) {
+	var nx, xx, gt, lt bool
+	conds := make([]int, 0, len(args))
+	for _, arg := range args {
+		cond, errReply := parseExpireCondition(arg)
+		if errReply != nil {
+			return nil, errReply
+		}
+		switch cond {
+		case expireNX:
+			nx = true
+		case expireXX:
+			xx = true
+		case expireGT:
+			gt = true
+		case expireLT:
+			lt = true
+		}
+		conds = append(conds, cond)
+	}
+	if nx && (xx || gt || lt) {
+		return nil, reply.MakeErrReply("ERR NX and XX, GT or LT options at the same time are not compatible")
+	}
+	if gt && lt {
+		return nil, reply.MakeErrReply("ERR GT and LT options at the same time are not compatible")
+	}
+	return conds, nil
+}
+
+// keyExpire 是 EXPIRE/PEXPIRE/EXPIREAT/PEXPIREAT 的公共实现
+// unit 为时间参数的单位(毫秒数), relative 表示时间参数是否是相对于当前时间的
+// 时间参数可以为负数, 过期时间已经过去时直接删除 key
+func keyExpire(db *DB, args [][]byte, unit int64, relative bool, cmdName string) resp.Reply {
+	key := string(args[0])
+	val, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	invalid := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	if val > math.MaxInt64/unit || val < math.MinInt64/unit {
+		return invalid
+	}
+	expireAt := val * unit
+	now := nowMilli()
+	if relative {
+		if expireAt > math.MaxInt64-now {
+			return invalid
+		}
+		expireAt += now
+	}
+	conds, errReply := parseKeyExpireConditions(args[2:])
+	if errReply != nil {
+		return errReply
+	}
+
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.MakeIntReply(0)
+	}
+	var current int64
+	expireTime, hasTTL := db.GetExpireTime(key)
+	if hasTTL {
+		current = expireTime.UnixMilli()
+	}
+	for _, cond := range conds {
+		if !expireConditionMet(cond, hasTTL, current, expireAt) {
+			return reply.MakeIntReply(0)
+		}
+	}
+	db.ExpireOrRemove(key, time.UnixMilli(expireAt))
+	return reply.MakeIntReply(1)
+}
+
+// execExpire 设置 key 的过期时间, 单位为秒, 设置成功返回 1, key 不存在或者不满足条件时返回 0
+// EXPIRE key seconds [NX | XX | GT | LT]
+func execExpire(db *DB, args [][]byte) resp.Reply {
+	return keyExpire(db, args, 1000, true, "expire")
+}
+
+// execPExpire 设置 key 的过期时间, 单位为毫秒
+// PEXPIRE key milliseconds [NX | XX | GT | LT]
+func execPExpire(db *DB, args [][]byte) resp.Reply {
+	return keyExpire(db, args, 1, true, "pexpire")
+}
+
+// execExpireAt 将 key 的过期时间设置为 unix 时间戳(秒)
+// EXPIREAT key unix-time-seconds [NX | XX | GT | LT]
+func execExpireAt(db *DB, args [][]byte) resp.Reply {
+	return keyExpire(db, args, 1000, false, "expireat")
+}
+
+// execPExpireAt 将 key 的过期时间设置为 unix 时间戳(毫秒)
+// PEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT]
+func execPExpireAt(db *DB, args [][]byte) resp.Reply {
+	return keyExpire(db, args, 1, false, "pexpireat")
+}
+
+// keyTTL 是 TTL/PTTL/EXPIRETIME/PEXPIRETIME 的公共实现
+// key 不存在时返回 -2, 没有过期时间时返回 -1, 否则返回 format 转换之后的值
+func keyTTL(db *DB, args [][]byte, format func(expireAt int64) int64) resp.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.MakeIntReply(-2)
+	}
+	expireTime, hasTTL := db.GetExpireTime(key)
+	if !hasTTL {
+		return reply.MakeIntReply(-1)
+	}
+	return reply.MakeIntReply(format(expireTime.UnixMilli()))
+}
+
+// remainingMilli 返回距离过期时间的毫秒数, 不小于 0
+func remainingMilli(expireAt int64) int64 {
+	ttl := expireAt - nowMilli()
+	if ttl < 0 {
+		return 0
+	}
+	return ttl
+}
+
+// milliToSeconds 将毫秒数四舍五入为秒数, TTL/EXPIRETIME/HEXPIRETIME 使用相同的换算
+func milliToSeconds(ms int64) int64 {
+	return (ms + 500) / 1000
+}
+
+// execTTL 返回 key 的剩余生存时间, 单位为秒
+// TTL key
+func execTTL(db *DB, args [][]byte) resp.Reply {
+	return keyTTL(db, args, func(expireAt int64) int64 {
+		return milliToSeconds(remainingMilli(expireAt))
+	})
+}
+
+// execPTTL 返回 key 的剩余生存时间, 单位为毫秒
+// PTTL key
+func execPTTL(db *DB, args [][]byte) resp.Reply {
+	return keyTTL(db, args, remainingMilli)
+}
+
+// execExpireTime 返回 key 过期时的 unix 时间戳, 单位为秒
+// EXPIRETIME key
+func execExpireTime(db *DB, args [][]byte) resp.Reply {
+	return keyTTL(db, args, func(expireAt int64) int64 {
+		return milliToSeconds(expireAt)
+	})
+}
+
+// execPExpireTime 返回 key 过期时的 unix 时间戳, 单位为毫秒
+// PEXPIRETIME key
+func execPExpireTime(db *DB, args [][]byte) resp.Reply {
+	return keyTTL(db, args, func(expireAt int64) int64 {
+		return expireAt
+	})
+}
+
+// execPersist 清除 key 的过期时间, 成功时返回 1, key 不存在或者没有过期时间时返回 0
+// PERSIST key
+func execPersist(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); !exists {
+		return reply.MakeIntReply(0)
+	}
+	if _, hasTTL := db.GetExpireTime(key); !hasTTL {
+		return reply.MakeIntReply(0)
+	}
+	db.Persist(key)
+	db.notify(notifyGeneric, "persist", key)
+	return reply.MakeIntReply(1)
+}
+
+func init() {
+	registerCommand("Expire", execExpire, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("PExpire", execPExpire, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("ExpireAt", execExpireAt, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("PExpireAt", execPExpireAt, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("TTL", execTTL, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("PTTL", execPTTL, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ExpireTime", execExpireTime, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("PExpireTime", execPExpireTime, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("Persist", execPersist, 2, FlagWrite|FlagFast, 1, 1, 1)
+}
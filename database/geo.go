@@ -0,0 +1,591 @@
+package database
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/datastruct/geo"
+	"github.com/LynchQ/my-go-redis/datastruct/sortedset"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 地理位置: 经纬度被编码为 52 位的 geohash, 作为有序集合的分值存储
+ */
+
+// parseDistanceUnit 解析距离单位, 返回一个单位对应的米数
+func parseDistanceUnit(arg []byte) (float64, resp.ErrorReply) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	}
+	return 0, reply.MakeErrReply("ERR unsupported unit provided. please use M, KM, FT, MI")
+}
+
+// parseLongLat 解析经度和纬度, 超出 geohash 支持的范围时返回错误
+func parseLongLat(longArg []byte, latArg []byte) (longitude float64, latitude float64, errReply resp.ErrorReply) {
+	if longitude, errReply = parseFloat64(longArg); errReply != nil {
+		return
+	}
+	if latitude, errReply = parseFloat64(latArg); errReply != nil {
+		return
+	}
+	if longitude < geo.LongMin || longitude > geo.LongMax || latitude < geo.LatMin || latitude > geo.LatMax {
+		errReply = reply.MakeErrReply("ERR invalid longitude,latitude pair " +
+			strconv.FormatFloat(longitude, 'f', 6, 64) + "," + strconv.FormatFloat(latitude, 'f', 6, 64))
+	}
+	return
+}
+
+// parseNonNegativeFloat 解析非负的浮点数, 不是数字时返回 ERR need numeric <name>
+func parseNonNegativeFloat(arg []byte, name string) (float64, bool, resp.ErrorReply) {
+	val, errReply := parseFloat64(arg)
+	if errReply != nil {
+		return 0, false, reply.MakeErrReply("ERR need numeric " + name)
+	}
+	return val, val >= 0, nil
+}
+
+// formatDistance 按照 redis 的格式输出距离, 保留 4 位小数
+func formatDistance(distance float64) string {
+	return strconv.FormatFloat(distance, 'f', 4, 64)
+}
+
+// formatCoord 按照 redis 的格式输出经纬度, 保留 17 位小数并去掉末尾的 0
+func formatCoord(val float64) string {
+	s := strconv.FormatFloat(val, 'f', 17, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
+
+// coordReply 返回 [经度, 纬度]
+func coordReply(longitude float64, latitude float64) resp.Reply {
+	return reply.MakeMultiBulkReply([][]byte{
+		[]byte(formatCoord(longitude)),
+		[]byte(formatCoord(latitude)),
+	})
+}
+
+// geoMemberPosition 返回成员的经纬度, 成员不存在时 ok 为 false
+func geoMemberPosition(zset *sortedset.SortedSet, member string) (longitude float64, latitude float64, ok bool) {
+	element, exists := zset.Get(member)
+	if !exists {
+		return 0, 0, false
+	}
+	return geo.DecodeScore(element.Score)
+}
+
+// execGeoAdd 添加成员的位置, 返回值与 ZADD 相同
+// GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func execGeoAdd(db *DB, args [][]byte) resp.Reply {
+	nx, xx := false, false
+	longIdx := 1
+loop:
+	for ; longIdx < len(args); longIdx++ {
+		switch toUpper(args[longIdx]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "CH":
+		default:
+			break loop
+		}
+	}
+	if (len(args)-longIdx)%3 != 0 || (nx && xx) {
+		return reply.MakeSyntaxErrReply()
+	}
+
+	// 转换为 ZADD key [NX | XX] [CH] score member ...
+	zaddArgs := make([][]byte, 0, longIdx+(len(args)-longIdx)/3*2)
+	zaddArgs = append(zaddArgs, args[:longIdx]...)
+	for i := longIdx; i < len(args); i += 3 {
+		longitude, latitude, errReply := parseLongLat(args[i], args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		score, _ := geo.EncodeScore(longitude, latitude)
+		zaddArgs = append(zaddArgs, []byte(strconv.FormatFloat(score, 'f', -1, 64)), args[i+2])
+	}
+	return execZAdd(db, zaddArgs)
+}
+
+// execGeoPos 返回成员的经纬度, 不存在的成员返回 nil
+// GEOPOS key [member [member ...]]
+func execGeoPos(db *DB, args [][]byte) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		result[i] = reply.MakeNullMultiBulkReply()
+		if zset == nil {
+			continue
+		}
+		if longitude, latitude, ok := geoMemberPosition(zset, string(member)); ok {
+			result[i] = coordReply(longitude, latitude)
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execGeoDist 返回两个成员之间的距离, 默认单位为米, 任意一个成员不存在时返回 nil
+// GEODIST key member1 member2 [M | KM | FT | MI]
+func execGeoDist(db *DB, args [][]byte) resp.Reply {
+	toMeters := 1.0
+	if len(args) == 4 {
+		var errReply resp.ErrorReply
+		if toMeters, errReply = parseDistanceUnit(args[3]); errReply != nil {
+			return errReply
+		}
+	} else if len(args) > 4 {
+		return reply.MakeSyntaxErrReply()
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeNullBulkReply()
+	}
+	long1, lat1, ok1 := geoMemberPosition(zset, string(args[1]))
+	long2, lat2, ok2 := geoMemberPosition(zset, string(args[2]))
+	if !ok1 || !ok2 {
+		return reply.MakeNullBulkReply()
+	}
+	distance := geo.Distance(long1, lat1, long2, lat2) / toMeters
+	return reply.MakeBulkReply([]byte(formatDistance(distance)))
+}
+
+// execGeoHash 返回成员的 11 个字符的标准 geohash 字符串, 不存在的成员返回 nil
+// GEOHASH key [member [member ...]]
+func execGeoHash(db *DB, args [][]byte) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		result[i] = reply.MakeNullBulkReply()
+		if zset == nil {
+			continue
+		}
+		element, exists := zset.Get(string(member))
+		if !exists {
+			continue
+		}
+		if hash, ok := geo.StandardHash(element.Score); ok {
+			result[i] = reply.MakeBulkReply([]byte(hash))
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+/* ---- GEOSEARCH/GEORADIUS ---- */
+
+// 搜索命令的类型
+const (
+	geoRadiusCoords = 1 << iota // GEORADIUS, 以经纬度为中心
+	geoRadiusMember             // GEORADIUSBYMEMBER, 以成员为中心
+	geoSearch                   // GEOSEARCH, 通过 FROMMEMBER/FROMLONLAT 和 BYRADIUS/BYBOX 指定范围
+	geoSearchStore              // GEOSEARCHSTORE
+	geoNoStore                  // 只读命令, 不允许 STORE/STOREDIST
+)
+
+// 搜索结果的排序方式
+const (
+	geoSortNone = iota
+	geoSortAsc
+	geoSortDesc
+)
+
+// geoPoint 是搜索结果中的一个成员
+type geoPoint struct {
+	member    string
+	score     float64
+	longitude float64
+	latitude  float64
+	distance  float64 // 到中心的距离, 单位为米
+}
+
+// geoSearchSpec 是解析之后的搜索参数
+type geoSearchSpec struct {
+	shape      geo.Shape
+	toMeters   float64 // 一个距离单位对应的米数
+	withDist   bool
+	withHash   bool
+	withCoord  bool
+	sort       int
+	count      int64 // 最多返回的个数, 0 表示不限制
+	any        bool  // 找到 count 个成员之后立即停止, 不保证是最近的
+	storeKey   string
+	store      bool
+	storeDist  bool // 存储距离而不是 geohash
+	fromMember bool
+	fromLoc    bool
+	byRadius   bool
+	byBox      bool
+}
+
+// parseGeoRadius 解析 radius unit
+func parseGeoRadius(spec *geoSearchSpec, args [][]byte) resp.ErrorReply {
+	radius, ok, errReply := parseNonNegativeFloat(args[0], "radius")
+	if errReply != nil {
+		return errReply
+	}
+	if !ok {
+		return reply.MakeErrReply("ERR radius cannot be negative")
+	}
+	if spec.toMeters, errReply = parseDistanceUnit(args[1]); errReply != nil {
+		return errReply
+	}
+	spec.shape.IsBox = false
+	spec.shape.Radius = radius * spec.toMeters
+	return nil
+}
+
+// parseGeoBox 解析 width height unit
+func parseGeoBox(spec *geoSearchSpec, args [][]byte) resp.ErrorReply {
+	width, widthOK, errReply := parseNonNegativeFloat(args[0], "width")
+	if errReply != nil {
+		return errReply
+	}
+	height, heightOK, errReply := parseNonNegativeFloat(args[1], "height")
+	if errReply != nil {
+		return errReply
+	}
+	if !widthOK || !heightOK {
+		return reply.MakeErrReply("ERR height or width cannot be negative")
+	}
+	if spec.toMeters, errReply = parseDistanceUnit(args[2]); errReply != nil {
+		return errReply
+	}
+	spec.shape.IsBox = true
+	spec.shape.Width = width * spec.toMeters
+	spec.shape.Height = height * spec.toMeters
+	return nil
+}
+
+// setGeoCenterFromMember 以成员的位置作为搜索中心
+func setGeoCenterFromMember(spec *geoSearchSpec, zset *sortedset.SortedSet, member []byte) resp.ErrorReply {
+	longitude, latitude, ok := geoMemberPosition(zset, string(member))
+	if !ok {
+		return reply.MakeErrReply("ERR could not decode requested zset member")
+	}
+	spec.shape.Longitude, spec.shape.Latitude = longitude, latitude
+	return nil
+}
+
+// parseGeoSearchArgs 解析搜索命令的参数, args 不包括命令名, zset 为 nil 时不检查 FROMMEMBER 的成员是否存在
+func parseGeoSearchArgs(cmdName string, zset *sortedset.SortedSet, args [][]byte, flags int) (*geoSearchSpec, resp.ErrorReply) {
+	spec := &geoSearchSpec{toMeters: 1}
+	var baseArgs int
+	var errReply resp.ErrorReply
+	switch {
+	case flags&geoRadiusCoords != 0:
+		baseArgs = 5
+		if spec.shape.Longitude, spec.shape.Latitude, errReply = parseLongLat(args[1], args[2]); errReply != nil {
+			return nil, errReply
+		}
+		if errReply = parseGeoRadius(spec, args[3:5]); errReply != nil {
+			return nil, errReply
+		}
+	case flags&geoRadiusMember != 0:
+		baseArgs = 4
+		// key 不存在时仍然需要解析参数, 以便根据 STORE 选项返回不同的结果
+		if zset != nil {
+			if errReply = setGeoCenterFromMember(spec, zset, args[1]); errReply != nil {
+				return nil, errReply
+			}
+			if errReply = parseGeoRadius(spec, args[2:4]); errReply != nil {
+				return nil, errReply
+			}
+		}
+	case flags&geoSearchStore != 0:
+		baseArgs = 2
+		spec.store = true
+		spec.storeKey = string(args[0])
+	default:
+		baseArgs = 1
+	}
+
+	options := args[baseArgs:]
+	for i := 0; i < len(options); i++ {
+		remaining := len(options) - i - 1
+		switch option := toUpper(options[i]); {
+		case option == "WITHDIST":
+			spec.withDist = true
+		case option == "WITHHASH":
+			spec.withHash = true
+		case option == "WITHCOORD":
+			spec.withCoord = true
+		case option == "ANY":
+			spec.any = true
+		case option == "ASC":
+			spec.sort = geoSortAsc
+		case option == "DESC":
+			spec.sort = geoSortDesc
+		case option == "COUNT" && remaining >= 1:
+			if spec.count, errReply = parseInt64(options[i+1]); errReply != nil {
+				return nil, errReply
+			}
+			if spec.count <= 0 {
+				return nil, reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			i++
+		case (option == "STORE" || option == "STOREDIST") && remaining >= 1 &&
+			flags&(geoNoStore|geoSearch|geoSearchStore) == 0:
+			spec.store = true
+			spec.storeKey = string(options[i+1])
+			spec.storeDist = option == "STOREDIST"
+			i++
+		case option == "STOREDIST" && flags&geoSearchStore != 0:
+			spec.storeDist = true
+		case option == "FROMMEMBER" && remaining >= 1 && flags&(geoSearch|geoSearchStore) != 0 && !spec.fromLoc:
+			if zset != nil {
+				if errReply = setGeoCenterFromMember(spec, zset, options[i+1]); errReply != nil {
+					return nil, errReply
+				}
+			}
+			spec.fromMember = true
+			i++
+		case option == "FROMLONLAT" && remaining >= 2 && flags&(geoSearch|geoSearchStore) != 0 && !spec.fromMember:
+			if spec.shape.Longitude, spec.shape.Latitude, errReply = parseLongLat(options[i+1], options[i+2]); errReply != nil {
+				return nil, errReply
+			}
+			spec.fromLoc = true
+			i += 2
+		case option == "BYRADIUS" && remaining >= 2 && flags&(geoSearch|geoSearchStore) != 0 && !spec.byBox:
+			if errReply = parseGeoRadius(spec, options[i+1:i+3]); errReply != nil {
+				return nil, errReply
+			}
+			spec.byRadius = true
+			i += 2
+		case option == "BYBOX" && remaining >= 3 && flags&(geoSearch|geoSearchStore) != 0 && !spec.byRadius:
+			if errReply = parseGeoBox(spec, options[i+1:i+4]); errReply != nil {
+				return nil, errReply
+			}
+			spec.byBox = true
+			i += 3
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+
+	if spec.store && (spec.withDist || spec.withHash || spec.withCoord) {
+		if flags&geoSearchStore != 0 {
+			return nil, reply.MakeErrReply("ERR GEOSEARCHSTORE is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+		}
+		return nil, reply.MakeErrReply("ERR STORE option in GEORADIUS is not compatible with WITHDIST, WITHHASH and WITHCOORD options")
+	}
+	if flags&(geoSearch|geoSearchStore) != 0 {
+		if !spec.fromMember && !spec.fromLoc {
+			return nil, reply.MakeErrReply("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for " + cmdName)
+		}
+		if !spec.byRadius && !spec.byBox {
+			return nil, reply.MakeErrReply("ERR exactly one of BYRADIUS and BYBOX can be specified for " + cmdName)
+		}
+	}
+	if spec.any && spec.count == 0 {
+		return nil, reply.MakeErrReply("ERR the ANY argument requires COUNT argument")
+	}
+	// 不排序时 COUNT 没有意义, 除非指定了 ANY
+	if spec.count != 0 && spec.sort == geoSortNone && !spec.any {
+		spec.sort = geoSortAsc
+	}
+	return spec, nil
+}
+
+// geoSearchPoints 返回在搜索范围内的成员, limit 大于 0 时找到 limit 个成员之后停止
+func geoSearchPoints(zset *sortedset.SortedSet, shape *geo.Shape, limit int64) []*geoPoint {
+	var points []*geoPoint
+	full := func() bool {
+		return limit > 0 && int64(len(points)) >= limit
+	}
+	for _, area := range shape.SearchAreas() {
+		if full() {
+			break
+		}
+		min, max := area.ScoreRange()
+		minBorder := &sortedset.ScoreBorder{Value: min}
+		maxBorder := &sortedset.ScoreBorder{Value: max, Exclude: true}
+		zset.ForEachInRange(minBorder, maxBorder, 0, -1, false, func(element *sortedset.Element) bool {
+			longitude, latitude, ok := geo.DecodeScore(element.Score)
+			if !ok {
+				return true
+			}
+			distance, ok := shape.Contains(longitude, latitude)
+			if !ok {
+				return true
+			}
+			points = append(points, &geoPoint{
+				member:    element.Member,
+				score:     element.Score,
+				longitude: longitude,
+				latitude:  latitude,
+				distance:  distance,
+			})
+			return !full()
+		})
+	}
+	return points
+}
+
+// geoPointsReply 将搜索结果转换为回复, 指定了 WITHDIST、WITHHASH 或 WITHCOORD 时每个成员是一个数组
+func geoPointsReply(points []*geoPoint, spec *geoSearchSpec) resp.Reply {
+	result := make([]resp.Reply, len(points))
+	for i, point := range points {
+		member := reply.MakeBulkReply([]byte(point.member))
+		if !spec.withDist && !spec.withHash && !spec.withCoord {
+			result[i] = member
+			continue
+		}
+		item := []resp.Reply{member}
+		if spec.withDist {
+			item = append(item, reply.MakeBulkReply([]byte(formatDistance(point.distance/spec.toMeters))))
+		}
+		if spec.withHash {
+			item = append(item, reply.MakeIntReply(int64(point.score)))
+		}
+		if spec.withCoord {
+			item = append(item, coordReply(point.longitude, point.latitude))
+		}
+		result[i] = reply.MakeMultiRawReply(item)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// geoSearchCommand 实现 GEORADIUS、GEORADIUSBYMEMBER、GEOSEARCH 和 GEOSEARCHSTORE
+// srcKeyIdx 是有序集合的 key 在 args 中的位置
+func geoSearchCommand(db *DB, cmdName string, args [][]byte, srcKeyIdx int, flags int) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[srcKeyIdx]))
+	if errReply != nil {
+		return errReply
+	}
+	spec, errReply := parseGeoSearchArgs(cmdName, zset, args, flags)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		if spec.store {
+			return reply.MakeIntReply(int64(db.Removes(spec.storeKey)))
+		}
+		return reply.MakeEmptyMultiBulkReply()
+	}
+
+	var limit int64
+	if spec.any {
+		limit = spec.count
+	}
+	points := geoSearchPoints(zset, &spec.shape, limit)
+	switch spec.sort {
+	case geoSortAsc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].distance < points[j].distance
+		})
+	case geoSortDesc:
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].distance > points[j].distance
+		})
+	}
+	if spec.count > 0 && int64(len(points)) > spec.count {
+		points = points[:spec.count]
+	}
+
+	if !spec.store {
+		return geoPointsReply(points, spec)
+	}
+	result := sortedset.Make()
+	for _, point := range points {
+		score := point.score
+		if spec.storeDist {
+			score = point.distance / spec.toMeters
+		}
+		result.Add(point.member, score)
+	}
+	event := "georadiusstore"
+	if cmdName == "geosearchstore" {
+		event = "geosearchstore"
+	}
+	db.storeSortedSet(spec.storeKey, result, event)
+	return reply.MakeIntReply(int64(len(points)))
+}
+
+// execGeoRadius 返回与指定经纬度的距离不超过 radius 的成员
+// GEORADIUS key longitude latitude radius M | KM | FT | MI [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC | DESC] [STORE key | STOREDIST key]
+func execGeoRadius(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "georadius", args, 0, geoRadiusCoords)
+}
+
+// execGeoRadiusRO 是 GEORADIUS 的只读版本
+// GEORADIUS_RO key longitude latitude radius M | KM | FT | MI [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC | DESC]
+func execGeoRadiusRO(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "georadius_ro", args, 0, geoRadiusCoords|geoNoStore)
+}
+
+// execGeoRadiusByMember 返回与指定成员的距离不超过 radius 的成员
+// GEORADIUSBYMEMBER key member radius M | KM | FT | MI [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC | DESC] [STORE key | STOREDIST key]
+func execGeoRadiusByMember(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "georadiusbymember", args, 0, geoRadiusMember)
+}
+
+// execGeoRadiusByMemberRO 是 GEORADIUSBYMEMBER 的只读版本
+// GEORADIUSBYMEMBER_RO key member radius M | KM | FT | MI [WITHCOORD] [WITHDIST] [WITHHASH] [COUNT count [ANY]] [ASC | DESC]
+func execGeoRadiusByMemberRO(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "georadiusbymember_ro", args, 0, geoRadiusMember|geoNoStore)
+}
+
+// execGeoSearch 返回在圆形或者矩形范围内的成员
+// GEOSEARCH key FROMMEMBER member | FROMLONLAT longitude latitude BYRADIUS radius M | KM | FT | MI | BYBOX width height M | KM | FT | MI
+// [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func execGeoSearch(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "geosearch", args, 0, geoSearch)
+}
+
+// execGeoSearchStore 与 GEOSEARCH 相同, 但是将结果存储到 destination, 返回结果的个数
+// GEOSEARCHSTORE destination source FROMMEMBER member | FROMLONLAT longitude latitude BYRADIUS radius M | KM | FT | MI | BYBOX width height M | KM | FT | MI
+// [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+func execGeoSearchStore(db *DB, args [][]byte) resp.Reply {
+	return geoSearchCommand(db, "geosearchstore", args, 1, geoSearchStore)
+}
+
+// geoRadiusKeysGetter 提取 GEORADIUS/GEORADIUSBYMEMBER 的 key, 包括 STORE/STOREDIST 指定的 key
+func geoRadiusKeysGetter(baseArgs int) func(cmdLine [][]byte) []string {
+	return func(cmdLine [][]byte) []string {
+		keys := []string{string(cmdLine[1])}
+		for i := baseArgs; i < len(cmdLine)-1; i++ {
+			switch toUpper(cmdLine[i]) {
+			case "STORE", "STOREDIST":
+				keys = append(keys, string(cmdLine[i+1]))
+				i++
+			case "COUNT":
+				i++
+			}
+		}
+		return keys
+	}
+}
+
+func init() {
+	registerCommand("GeoAdd", execGeoAdd, -5, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("GeoPos", execGeoPos, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoDist", execGeoDist, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoHash", execGeoHash, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoRadius", execGeoRadius, -6, FlagWrite|FlagDenyOOM, 1, 1, 1).
+		setKeysFunc(geoRadiusKeysGetter(6))
+	registerCommand("GeoRadius_RO", execGeoRadiusRO, -6, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoRadiusByMember", execGeoRadiusByMember, -5, FlagWrite|FlagDenyOOM, 1, 1, 1).
+		setKeysFunc(geoRadiusKeysGetter(5))
+	registerCommand("GeoRadiusByMember_RO", execGeoRadiusByMemberRO, -5, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoSearch", execGeoSearch, -7, FlagReadOnly, 1, 1, 1)
+	registerCommand("GeoSearchStore", execGeoSearchStore, -8, FlagWrite|FlagDenyOOM, 1, 2, 1)
+}
@@ -0,0 +1,451 @@
+package database
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/datastruct/hash"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsHash 返回 key 对应的哈希表, key 不存在时返回 nil
+// 返回前会删除已经过期的 field, 所有的 field 都过期时视为 key 不存在
+func (db *DB) getAsHash(key string) (*hash.Hash, resp.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	h, ok := entity.Data.(*hash.Hash)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	if db.removeExpiredFields(key, h) {
+		return nil, nil
+	}
+	return h, nil
+}
+
+// getOrInitHash 返回 key 对应的哈希表, key 不存在时创建一个空哈希表
+func (db *DB) getOrInitHash(key string) (h *hash.Hash, isNew bool, errReply resp.ErrorReply) {
+	h, errReply = db.getAsHash(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if h == nil {
+		h = hash.Make()
+		db.PutEntity(key, &database.DataEntity{Data: h})
+		isNew = true
+	}
+	return h, isNew, nil
+}
+
+// removeIfEmptyHash 哈希表为空时删除 key
+func (db *DB) removeIfEmptyHash(key string, h *hash.Hash) {
+	if h.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// hashSet 写入 field, keepTTL 为 false 时清除 field 的过期时间
+// 写入前后按照 hash-max-listpack-* 配置检查是否需要转换编码
+func hashSet(h *hash.Hash, field string, value string, keepTTL bool) bool {
+	if h.IsCompact() {
+		maxValue := config.Properties.HashMaxListpackValue
+		if len(field) > maxValue || len(value) > maxValue {
+			h.Convert()
+		}
+	}
+	var inserted bool
+	if keepTTL {
+		inserted = h.SetKeepTTL(field, value)
+	} else {
+		inserted = h.Set(field, value)
+	}
+	if h.IsCompact() && h.Len() > config.Properties.HashMaxListpackEntries {
+		h.Convert()
+	}
+	return inserted
+}
+
+// execHSet 写入一个或多个 field, 返回新插入的 field 的个数
+// HSET key field value [field value ...]
+func execHSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 == 0 {
+		return reply.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+	h, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for i := 1; i < len(args); i += 2 {
+		if hashSet(h, string(args[i]), string(args[i+1]), false) {
+			added++
+		}
+	}
+	db.notify(notifyHash, "hset", key)
+	return reply.MakeIntReply(int64(added))
+}
+
+// execHMSet 与 HSET 相同, 但是返回 OK
+// HMSET key field value [field value ...]
+func execHMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 == 0 {
+		return reply.MakeArgNumErrReply("hmset")
+	}
+	result := execHSet(db, args)
+	if _, ok := result.(resp.ErrorReply); ok {
+		return result
+	}
+	return reply.MakeOkReply()
+}
+
+// execHSetNX 仅当 field 不存在时写入
+// HSETNX key field value
+func execHSetNX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	h, _, errReply := db.getOrInitHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	field := string(args[1])
+	if _, exists := h.Get(field); exists {
+		return reply.MakeIntReply(0)
+	}
+	hashSet(h, field, string(args[2]), false)
+	db.notify(notifyHash, "hset", key)
+	return reply.MakeIntReply(1)
+}
+
+// execHGet 返回 field 对应的 value
+// HGET key field
+func execHGet(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeNullBulkReply()
+	}
+	value, exists := h.Get(string(args[1]))
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply([]byte(value))
+}
+
+// execHMGet 返回多个 field 对应的 value, 不存在的 field 返回 nil
+// HMGET key field [field ...]
+func execHMGet(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if h == nil {
+		return reply.MakeMultiBulkReply(result)
+	}
+	for i, field := range args[1:] {
+		if value, exists := h.Get(string(field)); exists {
+			result[i] = []byte(value)
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// hashGet 返回 field 对应的 value, h 可以为 nil
+func hashGet(h *hash.Hash, field string) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	return h.Get(field)
+}
+
+// hashItems 返回哈希表中的 field 和(或) value
+func hashItems(h *hash.Hash, withFields bool, withValues bool) [][]byte {
+	if h == nil {
+		return [][]byte{}
+	}
+	result := make([][]byte, 0, h.Len()*2)
+	h.ForEach(func(field string, value string) bool {
+		if withFields {
+			result = append(result, []byte(field))
+		}
+		if withValues {
+			result = append(result, []byte(value))
+		}
+		return true
+	})
+	return result
+}
+
+// execHGetAll 返回所有的 field 和 value
+// HGETALL key
+func execHGetAll(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeMultiBulkReply(hashItems(h, true, true))
+}
+
+// execHKeys 返回所有的 field
+// HKEYS key
+func execHKeys(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeMultiBulkReply(hashItems(h, true, false))
+}
+
+// execHVals 返回所有的 value
+// HVALS key
+func execHVals(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeMultiBulkReply(hashItems(h, false, true))
+}
+
+// execHDel 删除一个或多个 field, 返回删除的 field 的个数
+// HDEL key field [field ...]
+func execHDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := 0
+	for _, field := range args[1:] {
+		if h.Remove(string(field)) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.notify(notifyHash, "hdel", key)
+	}
+	db.removeIfEmptyHash(key, h)
+	return reply.MakeIntReply(int64(deleted))
+}
+
+// execHExists 判断 field 是否存在
+// HEXISTS key field
+func execHExists(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists := h.Get(string(args[1])); exists {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execHLen 返回 field 的个数
+// HLEN key
+func execHLen(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(h.Len()))
+}
+
+// execHStrLen 返回 field 对应的 value 的长度
+// HSTRLEN key field
+func execHStrLen(db *DB, args [][]byte) resp.Reply {
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return reply.MakeIntReply(0)
+	}
+	value, _ := h.Get(string(args[1]))
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// execHIncrBy 将 field 的值增加 increment
+// HINCRBY key field increment
+func execHIncrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseInt64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	key := string(args[0])
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	field := string(args[1])
+	var current int64
+	if value, exists := hashGet(h, field); exists {
+		var ok bool
+		current, ok = strictParseInt64([]byte(value))
+		if !ok {
+			return reply.MakeErrReply("ERR hash value is not an integer")
+		}
+	}
+	if (delta < 0 && current < math.MinInt64-delta) || (delta > 0 && current > math.MaxInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	current += delta
+	if h == nil {
+		h, _, _ = db.getOrInitHash(key)
+	}
+	hashSet(h, field, strconv.FormatInt(current, 10), true)
+	db.notify(notifyHash, "hincrby", key)
+	return reply.MakeIntReply(current)
+}
+
+// execHIncrByFloat 将 field 的值增加浮点数 increment
+// HINCRBYFLOAT key field increment
+func execHIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseFloat64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	key := string(args[0])
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	field := string(args[1])
+	var current float64
+	var value []byte
+	if str, exists := hashGet(h, field); exists {
+		value = []byte(str)
+		current, errReply = parseFloat64(value)
+		if errReply != nil {
+			return reply.MakeErrReply("ERR hash value is not a float")
+		}
+	}
+	if math.IsInf(current, 0) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result, ok := incrLongDouble(value, args[2])
+	if !ok {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	if h == nil {
+		h, _, _ = db.getOrInitHash(key)
+	}
+	hashSet(h, field, result, true)
+	db.notify(notifyHash, "hincrbyfloat", key)
+	return reply.MakeBulkReply([]byte(result))
+}
+
+// execHRandField 随机返回 field
+// count 为正数时返回不重复的 field, 为负数时可能返回重复的 field
+// HRANDFIELD key [count [WITHVALUES]]
+func execHRandField(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 3 || (len(args) == 3 && toUpper(args[2]) != "WITHVALUES") {
+		return reply.MakeSyntaxErrReply()
+	}
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if h == nil {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeBulkReply([]byte(h.RandomField()))
+	}
+	count, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	withValues := len(args) == 3
+	// 带 WITHVALUES 时回复的长度是 count 的两倍, 不能溢出
+	if count < -math.MaxInt64 || (withValues && count < -math.MaxInt64/2) {
+		return reply.MakeErrReply("ERR value is out of range")
+	}
+	if h == nil || count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	var pairs []hash.Pair
+	if count > 0 {
+		pairs = h.RandomDistinctPairs(int(count))
+	} else {
+		pairs = h.RandomPairs(int(-count))
+	}
+	result := make([][]byte, 0, len(pairs)*2)
+	for _, pair := range pairs {
+		result = append(result, []byte(pair.Field))
+		if withValues {
+			result = append(result, []byte(pair.Value))
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execHScan 使用游标迭代哈希表中的元素, listpack 编码时一次返回所有元素
+// HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]
+func execHScan(db *DB, args [][]byte) resp.Reply {
+	cursor, errReply := parseScanCursor(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	opts, errReply := parseScanOptions(args[2:], true, false)
+	if errReply != nil {
+		return errReply
+	}
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if h == nil {
+		return makeScanReply(0, [][]byte{})
+	}
+	elements := make([][]byte, 0)
+	sampled := 0
+	cursor = scanLoop(cursor, opts.count, func(cursor uint64) uint64 {
+		return h.Scan(cursor, func(field string, value string) {
+			sampled++
+			if !opts.match(field) {
+				return
+			}
+			elements = append(elements, []byte(field))
+			if !opts.noValues {
+				elements = append(elements, []byte(value))
+			}
+		})
+	}, func() int { return sampled })
+	return makeScanReply(cursor, elements)
+}
+
+func init() {
+	registerCommand("HSet", execHSet, -4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("HMSet", execHMSet, -4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("HSetNX", execHSetNX, 4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("HGet", execHGet, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HMGet", execHMGet, -3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HGetAll", execHGetAll, 2, FlagReadOnly, 1, 1, 1)
+	registerCommand("HKeys", execHKeys, 2, FlagReadOnly, 1, 1, 1)
+	registerCommand("HVals", execHVals, 2, FlagReadOnly, 1, 1, 1)
+	registerCommand("HDel", execHDel, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HExists", execHExists, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HLen", execHLen, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HStrLen", execHStrLen, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HIncrBy", execHIncrBy, 4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("HIncrByFloat", execHIncrByFloat, 4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("HRandField", execHRandField, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("HScan", execHScan, -3, FlagReadOnly, 1, 1, 1)
+}
@@ -0,0 +1,521 @@
+package database
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/datastruct/hash"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 哈希表 field 的过期时间(HEXPIRE 系列命令)
+ * 过期的 field 在访问哈希表时被删除(见 getAsHash), 同时由定时任务抽样删除
+ */
+
+// maxFieldExpireTime field 过期时间的上限, 与 redis 一致为 2^48 - 1 毫秒
+const maxFieldExpireTime = 1<<48 - 1
+
+// HEXPIRE 系列命令对每个 field 的回复
+const (
+	fieldNotExists    = -2 // field 不存在
+	fieldNoTTL        = -1 // field 没有设置过期时间
+	fieldCondNotMet   = 0  // 不满足 NX/XX/GT/LT 条件
+	fieldTTLUpdated   = 1  // 设置成功
+	fieldExpiredByCmd = 2  // 过期时间已经过去, field 被删除
+)
+
+// activeExpireSamples 定时任务每轮抽样检查的 key 个数
+const activeExpireSamples = 20
+
+// nowMilli 返回当前的 unix 毫秒时间戳
+func nowMilli() int64 {
+	return time.Now().UnixMilli()
+}
+
+// removeExpiredFields 删除哈希表中过期的 field, 哈希表为空时删除 key, 返回 key 是否被删除
+func (db *DB) removeExpiredFields(key string, h *hash.Hash) bool {
+	if h.RemoveExpired(nowMilli()) == 0 {
+		return false
+	}
+	db.notify(notifyHash, "hexpired", key)
+	if h.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+		return true
+	}
+	return false
+}
+
+// trackHashFieldExpires 记录有 field 设置了过期时间的哈希表
+func (db *DB) trackHashFieldExpires(key string, entity *database.DataEntity) {
+	if h, ok := entity.Data.(*hash.Hash); ok && h.HasExpires() {
+		db.hashFieldExpires.Put(key, nil)
+	}
+}
+
+// activeExpireHashFields 抽样检查有过期 field 的哈希表并删除过期的 field
+// 与 redis 的主动过期类似, 如果抽样中有超过 1/4 的哈希表删除了 field 则继续下一轮
+func (db *DB) activeExpireHashFields() {
+	for round := 0; round < 16 && db.hashFieldExpires.Len() > 0; round++ {
+		keys := db.hashFieldExpires.RandomDistinctKeys(activeExpireSamples)
+		expired := 0
+		for _, key := range keys {
+			raw, exists := db.data.Get(key)
+			var h *hash.Hash
+			if exists {
+				h, _ = raw.(*database.DataEntity).Data.(*hash.Hash)
+			}
+			if h == nil {
+				db.hashFieldExpires.Remove(key)
+				continue
+			}
+			if h.RemoveExpired(nowMilli()) > 0 {
+				expired++
+				db.notify(notifyHash, "hexpired", key)
+				if h.Len() == 0 {
+					db.Remove(key)
+					db.notify(notifyGeneric, "del", key)
+				} else {
+					db.updateMemory(key)
+				}
+			}
+			if !h.HasExpires() || h.Len() == 0 {
+				db.hashFieldExpires.Remove(key)
+			}
+		}
+		if expired*4 <= len(keys) {
+			return
+		}
+	}
+}
+
+// parseHashFields 解析 FIELDS numfields field [field ...], args 从 FIELDS 开始且必须恰好包含所有 field
+// step 为每个 field 占用的参数个数, HSETEX 中为 2
+func parseHashFields(args [][]byte, step int) ([][]byte, resp.ErrorReply) {
+	if len(args) < 2 || toUpper(args[0]) != "FIELDS" {
+		return nil, reply.MakeErrReply("ERR Mandatory argument FIELDS is missing or not at the right position")
+	}
+	numFields, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil || numFields <= 0 {
+		return nil, reply.MakeErrReply("ERR Parameter `numFields` should be greater than 0")
+	}
+	fields := args[2:]
+	if int64(len(fields)) != numFields*int64(step) {
+		return nil, reply.MakeErrReply("ERR The `numfields` parameter must match the number of arguments")
+	}
+	return fields, nil
+}
+
+// 设置过期时间的条件
+const (
+	expireAlways = iota
+	expireNX     // 仅当没有过期时间时
+	expireXX     // 仅当已有过期时间时
+	expireGT     // 仅当新的过期时间更晚时, 没有过期时间视为永不过期
+	expireLT     // 仅当新的过期时间更早时
+)
+
+// parseExpireCondition 解析 NX|XX|GT|LT
+func parseExpireCondition(arg []byte) (int, resp.ErrorReply) {
+	switch toUpper(arg) {
+	case "NX":
+		return expireNX, nil
+	case "XX":
+		return expireXX, nil
+	case "GT":
+		return expireGT, nil
+	case "LT":
+		return expireLT, nil
+	}
+	return 0, reply.MakeErrReply("ERR Unsupported option " + string(arg))
+}
+
+// expireConditionMet 判断是否满足设置过期时间的条件
+func expireConditionMet(cond int, hasTTL bool, current int64, expireAt int64) bool {
+	switch cond {
+	case expireNX:
+		return !hasTTL
+	case expireXX:
+		return hasTTL
+	case expireGT:
+		return hasTTL && expireAt > current
+	case expireLT:
+		return !hasTTL || expireAt < current
+	}
+	return true
+}
+
+// hashExpire 是 HEXPIRE/HPEXPIRE/HEXPIREAT/HPEXPIREAT 的公共实现
+// unit 为时间参数的单位(毫秒数), relative 表示时间参数是否是相对于当前时间的
+func hashExpire(db *DB, args [][]byte, unit int64, relative bool, cmdName string) resp.Reply {
+	key := string(args[0])
+	val, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if val < 0 {
+		return reply.MakeErrReply("ERR invalid expire time, must be >= 0")
+	}
+	invalid := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	if val > maxFieldExpireTime/unit {
+		return invalid
+	}
+	expireAt := val * unit
+	now := nowMilli()
+	if relative {
+		expireAt += now
+	}
+	if expireAt > maxFieldExpireTime {
+		return invalid
+	}
+
+	rest := args[2:]
+	cond := expireAlways
+	if len(rest) > 0 && toUpper(rest[0]) != "FIELDS" {
+		cond, errReply = parseExpireCondition(rest[0])
+		if errReply != nil {
+			return errReply
+		}
+		rest = rest[1:]
+	}
+	fields, errReply := parseHashFields(rest, 1)
+	if errReply != nil {
+		return errReply
+	}
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := make([]resp.Reply, len(fields))
+	var deleted, updated bool
+	for i, arg := range fields {
+		field := string(arg)
+		if _, exists := hashGet(h, field); !exists {
+			result[i] = reply.MakeIntReply(fieldNotExists)
+			continue
+		}
+		current, hasTTL := h.GetExpire(field)
+		if !expireConditionMet(cond, hasTTL, current, expireAt) {
+			result[i] = reply.MakeIntReply(fieldCondNotMet)
+			continue
+		}
+		if expireAt <= now {
+			h.Remove(field)
+			deleted = true
+			result[i] = reply.MakeIntReply(fieldExpiredByCmd)
+			continue
+		}
+		h.SetExpire(field, expireAt)
+		updated = true
+		result[i] = reply.MakeIntReply(fieldTTLUpdated)
+	}
+	if deleted {
+		db.notify(notifyHash, "hdel", key)
+	}
+	if updated {
+		db.notify(notifyHash, "hexpire", key)
+	}
+	if h != nil {
+		db.removeIfEmptyHash(key, h)
+		db.trackHashFieldExpires(key, &database.DataEntity{Data: h})
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execHExpire 设置 field 的过期时间, 单位为秒
+// HEXPIRE key seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func execHExpire(db *DB, args [][]byte) resp.Reply {
+	return hashExpire(db, args, 1000, true, "hexpire")
+}
+
+// execHPExpire 设置 field 的过期时间, 单位为毫秒
+// HPEXPIRE key milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func execHPExpire(db *DB, args [][]byte) resp.Reply {
+	return hashExpire(db, args, 1, true, "hpexpire")
+}
+
+// execHExpireAt 将 field 的过期时间设置为 unix 时间戳(秒)
+// HEXPIREAT key unix-time-seconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func execHExpireAt(db *DB, args [][]byte) resp.Reply {
+	return hashExpire(db, args, 1000, false, "hexpireat")
+}
+
+// execHPExpireAt 将 field 的过期时间设置为 unix 时间戳(毫秒)
+// HPEXPIREAT key unix-time-milliseconds [NX | XX | GT | LT] FIELDS numfields field [field ...]
+func execHPExpireAt(db *DB, args [][]byte) resp.Reply {
+	return hashExpire(db, args, 1, false, "hpexpireat")
+}
+
+// hashTTL 是 HTTL/HPTTL/HEXPIRETIME/HPEXPIRETIME 的公共实现
+// format 将过期时间的毫秒时间戳转换为回复的值
+func hashTTL(db *DB, args [][]byte, format func(expireAt int64) int64) resp.Reply {
+	fields, errReply := parseHashFields(args[1:], 1)
+	if errReply != nil {
+		return errReply
+	}
+	h, errReply := db.getAsHash(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(fields))
+	for i, arg := range fields {
+		field := string(arg)
+		if _, exists := hashGet(h, field); !exists {
+			result[i] = reply.MakeIntReply(fieldNotExists)
+			continue
+		}
+		expireAt, hasTTL := h.GetExpire(field)
+		if !hasTTL {
+			result[i] = reply.MakeIntReply(fieldNoTTL)
+			continue
+		}
+		result[i] = reply.MakeIntReply(format(expireAt))
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execHTTL 返回 field 的剩余生存时间, 单位为秒
+// HTTL key FIELDS numfields field [field ...]
+func execHTTL(db *DB, args [][]byte) resp.Reply {
+	return hashTTL(db, args, func(expireAt int64) int64 {
+		return (expireAt - nowMilli() + 999) / 1000
+	})
+}
+
+// execHPTTL 返回 field 的剩余生存时间, 单位为毫秒
+// HPTTL key FIELDS numfields field [field ...]
+func execHPTTL(db *DB, args [][]byte) resp.Reply {
+	return hashTTL(db, args, func(expireAt int64) int64 {
+		return expireAt - nowMilli()
+	})
+}
+
+// execHExpireTime 返回 field 过期时的 unix 时间戳, 单位为秒
+// HEXPIRETIME key FIELDS numfields field [field ...]
+func execHExpireTime(db *DB, args [][]byte) resp.Reply {
+	return hashTTL(db, args, milliToSeconds)
+}
+
+// execHPExpireTime 返回 field 过期时的 unix 时间戳, 单位为毫秒
+// HPEXPIRETIME key FIELDS numfields field [field ...]
+func execHPExpireTime(db *DB, args [][]byte) resp.Reply {
+	return hashTTL(db, args, func(expireAt int64) int64 {
+		return expireAt
+	})
+}
+
+// execHPersist 清除 field 的过期时间
+// HPERSIST key FIELDS numfields field [field ...]
+func execHPersist(db *DB, args [][]byte) resp.Reply {
+	fields, errReply := parseHashFields(args[1:], 1)
+	if errReply != nil {
+		return errReply
+	}
+	key := string(args[0])
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(fields))
+	persisted := false
+	for i, arg := range fields {
+		field := string(arg)
+		if _, exists := hashGet(h, field); !exists {
+			result[i] = reply.MakeIntReply(fieldNotExists)
+		} else if h.Persist(field) {
+			persisted = true
+			result[i] = reply.MakeIntReply(1)
+		} else {
+			result[i] = reply.MakeIntReply(fieldNoTTL)
+		}
+	}
+	if persisted {
+		db.notify(notifyHash, "hpersist", key)
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// parseFieldExpireOption 解析 HGETEX/HSETEX 中的 EX/PX/EXAT/PXAT 选项
+func parseFieldExpireOption(option string, arg []byte, cmdName string) (int64, resp.ErrorReply) {
+	expireTime, errReply := parseExpireArg(option, arg, cmdName)
+	if errReply != nil {
+		return 0, errReply
+	}
+	expireAt := expireTime.UnixMilli()
+	if expireAt > maxFieldExpireTime {
+		return 0, reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	}
+	return expireAt, nil
+}
+
+// applyFieldExpireOption 根据 EX/PX/EXAT/PXAT/PERSIST 选项更新 field 的过期时间
+// 过期时间已经过去时删除 field, 返回需要发布的事件, 没有修改时返回空字符串
+func applyFieldExpireOption(h *hash.Hash, field string, opt *expireOption, expireAt int64) string {
+	if opt.set {
+		if expireAt <= nowMilli() {
+			h.Remove(field)
+			return "hdel"
+		}
+		h.SetExpire(field, expireAt)
+		return "hexpire"
+	}
+	if opt.persist && h.Persist(field) {
+		return "hpersist"
+	}
+	return ""
+}
+
+// execHGetEX 返回 field 的值并设置或清除它们的过期时间
+// HGETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST] FIELDS numfields field [field ...]
+func execHGetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	opt := &expireOption{}
+	var expireAt int64
+	rest := args[1:]
+	for len(rest) > 0 && toUpper(rest[0]) != "FIELDS" {
+		switch option := toUpper(rest[0]); option {
+		case "EX", "PX", "EXAT", "PXAT":
+			if opt.set || opt.persist || len(rest) < 2 {
+				return reply.MakeSyntaxErrReply()
+			}
+			var errReply resp.ErrorReply
+			expireAt, errReply = parseFieldExpireOption(option, rest[1], "hgetex")
+			if errReply != nil {
+				return errReply
+			}
+			opt.set = true
+			rest = rest[2:]
+		case "PERSIST":
+			if opt.set || opt.persist {
+				return reply.MakeSyntaxErrReply()
+			}
+			opt.persist = true
+			rest = rest[1:]
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	fields, errReply := parseHashFields(rest, 1)
+	if errReply != nil {
+		return errReply
+	}
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(fields))
+	if h == nil {
+		return reply.MakeMultiBulkReply(result)
+	}
+	event := ""
+	for i, arg := range fields {
+		field := string(arg)
+		value, exists := h.Get(field)
+		if !exists {
+			continue
+		}
+		result[i] = []byte(value)
+		if e := applyFieldExpireOption(h, field, opt, expireAt); e != "" {
+			event = e
+		}
+	}
+	if event != "" {
+		db.notify(notifyHash, event, key)
+	}
+	db.removeIfEmptyHash(key, h)
+	db.trackHashFieldExpires(key, &database.DataEntity{Data: h})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execHSetEX 写入 field 并设置它们的过期时间, 全部写入时返回 1, 不满足 FNX/FXX 条件时返回 0
+// 没有指定 KEEPTTL 时会清除 field 原有的过期时间
+// HSETEX key [FNX | FXX] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL] FIELDS numfields field value [field value ...]
+func execHSetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	policy := upsertPolicy
+	opt := &expireOption{}
+	var expireAt int64
+	rest := args[1:]
+	for len(rest) > 0 && toUpper(rest[0]) != "FIELDS" {
+		switch option := toUpper(rest[0]); option {
+		case "FNX", "FXX":
+			if policy != upsertPolicy {
+				return reply.MakeSyntaxErrReply()
+			}
+			policy = insertPolicy
+			if option == "FXX" {
+				policy = updatePolicy
+			}
+			rest = rest[1:]
+		case "EX", "PX", "EXAT", "PXAT":
+			if opt.set || opt.keepTTL || len(rest) < 2 {
+				return reply.MakeSyntaxErrReply()
+			}
+			var errReply resp.ErrorReply
+			expireAt, errReply = parseFieldExpireOption(option, rest[1], "hsetex")
+			if errReply != nil {
+				return errReply
+			}
+			opt.set = true
+			rest = rest[2:]
+		case "KEEPTTL":
+			if opt.set || opt.keepTTL {
+				return reply.MakeSyntaxErrReply()
+			}
+			opt.keepTTL = true
+			rest = rest[1:]
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	pairs, errReply := parseHashFields(rest, 2)
+	if errReply != nil {
+		return errReply
+	}
+	h, errReply := db.getAsHash(key)
+	if errReply != nil {
+		return errReply
+	}
+	if policy != upsertPolicy {
+		for i := 0; i < len(pairs); i += 2 {
+			_, exists := hashGet(h, string(pairs[i]))
+			if exists != (policy == updatePolicy) {
+				return reply.MakeIntReply(0)
+			}
+		}
+	}
+	if h == nil {
+		h, _, _ = db.getOrInitHash(key)
+	}
+	event := ""
+	for i := 0; i < len(pairs); i += 2 {
+		field := string(pairs[i])
+		hashSet(h, field, string(pairs[i+1]), opt.keepTTL)
+		event = applyFieldExpireOption(h, field, opt, expireAt)
+	}
+	db.notify(notifyHash, "hset", key)
+	if event != "" {
+		db.notify(notifyHash, event, key)
+	}
+	db.removeIfEmptyHash(key, h)
+	db.trackHashFieldExpires(key, &database.DataEntity{Data: h})
+	return reply.MakeIntReply(1)
+}
+
+func init() {
+	registerCommand("HExpire", execHExpire, -6, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HPExpire", execHPExpire, -6, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HExpireAt", execHExpireAt, -6, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HPExpireAt", execHPExpireAt, -6, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HTTL", execHTTL, -5, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HPTTL", execHPTTL, -5, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HExpireTime", execHExpireTime, -5, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HPExpireTime", execHPExpireTime, -5, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("HPersist", execHPersist, -5, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HGetEX", execHGetEX, -5, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("HSetEX", execHSetEX, -6, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+}
@@ -0,0 +1,37 @@
+package database
+
+import "testing"
+
+func TestHIncrByFloat(t *testing.T) {
+	mdb := NewStandaloneDatabase()
+	defer mdb.Close()
+	c := &fakeConn{}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"HINCRBYFLOAT h f 10.5", "$4\r\n10.5\r\n"},
+		{"HINCRBYFLOAT h f 0.1", "$4\r\n10.6\r\n"},
+		{"HINCRBYFLOAT h f 0.2", "$4\r\n10.8\r\n"},
+		{"HGET h f", "$4\r\n10.8\r\n"},
+		{"HINCRBYFLOAT h g 0.1", "$3\r\n0.1\r\n"},
+		{"HINCRBYFLOAT h g 0.2", "$3\r\n0.3\r\n"},
+		{"HINCRBYFLOAT h f inf", "-ERR increment would produce NaN or Infinity\r\n"},
+		{"HSET h s abc", ":1\r\n"},
+		{"HINCRBYFLOAT h s 1", "-ERR hash value is not a float\r\n"},
+	}
+	for _, tc := range cases {
+		if got := string(mdb.Exec(c, toCmdLine(tc.cmd)).ToBytes()); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.cmd, got, tc.want)
+		}
+	}
+
+	// 重复累加时存储的值不会逐渐偏离
+	for i := 0; i < 10; i++ {
+		mdb.Exec(c, toCmdLine("HINCRBYFLOAT h c 0.1"))
+	}
+	if got := string(mdb.Exec(c, toCmdLine("HGET h c")).ToBytes()); got != "$1\r\n1\r\n" {
+		t.Errorf("ten increments of 0.1: got %q, want %q", got, "$1\r\n1\r\n")
+	}
+}
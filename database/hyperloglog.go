@@ -0,0 +1,144 @@
+package database
+
+import (
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/datastruct/hyperloglog"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsHyperLogLog 返回 key 对应的 HyperLogLog, 不是合法的 HyperLogLog 时返回错误
+// 返回的是副本, 修改之后需要调用 PutEntity
+func (db *DB) getAsHyperLogLog(key string) ([]byte, resp.ErrorReply) {
+	value, errReply := db.getAsString(key)
+	if errReply != nil || value == nil {
+		return nil, errReply
+	}
+	if !hyperloglog.IsValid(value) {
+		return nil, reply.MakeErrReply("WRONGTYPE Key is not a valid HyperLogLog string value.")
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// execPFAdd 向 HyperLogLog 中添加元素, 估算的基数发生变化时返回 1, 否则返回 0
+// key 不存在时创建空的 HyperLogLog
+// PFADD key [element [element ...]]
+func execPFAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	hll, errReply := db.getAsHyperLogLog(key)
+	if errReply != nil {
+		return errReply
+	}
+	updated := false
+	if hll == nil {
+		hll = hyperloglog.New()
+		updated = true
+	}
+	for _, element := range args[1:] {
+		var changed bool
+		var err error
+		hll, changed, err = hyperloglog.Add(hll, element, config.Properties.HllSparseMaxBytes)
+		if err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+		updated = updated || changed
+	}
+	if !updated {
+		return reply.MakeIntReply(0)
+	}
+	db.PutEntity(key, &database.DataEntity{Data: hll})
+	db.notify(notifyString, "pfadd", key)
+	return reply.MakeIntReply(1)
+}
+
+// execPFCount 返回 HyperLogLog 估算的基数, 多个 key 时返回它们的并集的基数
+// 单个 key 时估算结果缓存在 HyperLogLog 的头部
+// PFCOUNT key [key ...]
+func execPFCount(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 1 {
+		var registers hyperloglog.Registers
+		for _, arg := range args {
+			hll, errReply := db.getAsHyperLogLog(string(arg))
+			if errReply != nil {
+				return errReply
+			}
+			if hll == nil {
+				continue
+			}
+			if err := registers.Merge(hll); err != nil {
+				return reply.MakeErrReply(err.Error())
+			}
+		}
+		return reply.MakeIntReply(int64(registers.Count()))
+	}
+
+	key := string(args[0])
+	hll, errReply := db.getAsHyperLogLog(key)
+	if errReply != nil {
+		return errReply
+	}
+	if hll == nil {
+		return reply.MakeIntReply(0)
+	}
+	if card, ok := hyperloglog.CachedCount(hll); ok {
+		return reply.MakeIntReply(int64(card))
+	}
+	card, err := hyperloglog.Count(hll)
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	hyperloglog.SetCachedCount(hll, card)
+	db.PutEntity(key, &database.DataEntity{Data: hll})
+	return reply.MakeIntReply(int64(card))
+}
+
+// execPFMerge 将多个 HyperLogLog 合并到 destkey 中, destkey 原有的值也参与合并
+// 任意一个输入使用稠密编码时结果使用稠密编码
+// PFMERGE destkey [sourcekey [sourcekey ...]]
+func execPFMerge(db *DB, args [][]byte) resp.Reply {
+	var registers hyperloglog.Registers
+	useDense := false
+	for _, arg := range args {
+		hll, errReply := db.getAsHyperLogLog(string(arg))
+		if errReply != nil {
+			return errReply
+		}
+		if hll == nil {
+			continue
+		}
+		if hyperloglog.Encoding(hll) == hyperloglog.EncodingDense {
+			useDense = true
+		}
+		if err := registers.Merge(hll); err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+	}
+
+	destKey := string(args[0])
+	dest, errReply := db.getAsHyperLogLog(destKey)
+	if errReply != nil {
+		return errReply
+	}
+	if dest == nil {
+		dest = hyperloglog.New()
+	}
+	var err error
+	if useDense {
+		if dest, err = hyperloglog.ToDense(dest); err != nil {
+			return reply.MakeErrReply(err.Error())
+		}
+	}
+	if dest, err = registers.Store(dest, config.Properties.HllSparseMaxBytes); err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	db.PutEntity(destKey, &database.DataEntity{Data: dest})
+	db.notify(notifyString, "pfadd", destKey)
+	return reply.MakeOkReply()
+}
+
+func init() {
+	registerCommand("PFAdd", execPFAdd, -2, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("PFCount", execPFCount, -2, FlagReadOnly, 1, -1, 1)
+	registerCommand("PFMerge", execPFMerge, -2, FlagWrite|FlagDenyOOM, 1, -1, 1)
+}
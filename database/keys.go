@@ -0,0 +1,278 @@
+package database
+
+import (
+	"time"
+
+	"github.com/LynchQ/my-go-redis/datastruct/hash"
+	"github.com/LynchQ/my-go-redis/datastruct/list"
+	"github.com/LynchQ/my-go-redis/datastruct/set"
+	"github.com/LynchQ/my-go-redis/datastruct/sortedset"
+	"github.com/LynchQ/my-go-redis/datastruct/stream"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/lib/glob"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 与 key 的类型无关的通用命令
+ */
+
+// typeName 返回 TYPE 命令输出的类型名称
+func typeName(entity *database.DataEntity) string {
+	switch entity.Data.(type) {
+	case []byte:
+		return "string"
+	case *list.QuickList:
+		return "list"
+	case *hash.Hash:
+		return "hash"
+	case *set.Set:
+		return "set"
+	case *sortedset.SortedSet:
+		return "zset"
+	case *stream.Stream:
+		return "stream"
+	}
+	return "none"
+}
+
+// copyData 返回值的深拷贝, 用于 COPY
+func copyData(data interface{}) interface{} {
+	switch data := data.(type) {
+	case []byte:
+		return append([]byte{}, data...)
+	case *list.QuickList:
+		return data.Copy()
+	case *hash.Hash:
+		return data.Copy()
+	case *set.Set:
+		return data.Copy()
+	case *sortedset.SortedSet:
+		return data.Copy()
+	case *stream.Stream:
+		return data.Copy()
+	}
+	return data
+}
+
+// execDel 删除 key, 返回删除的 key 的个数
+// DEL key [key ...]
+func execDel(db *DB, args [][]byte) resp.Reply {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return reply.MakeIntReply(int64(db.Removes(keys...)))
+}
+
+// execExists 返回存在的 key 的个数, 重复的 key 会被重复计数
+// EXISTS key [key ...]
+func execExists(db *DB, args [][]byte) resp.Reply {
+	count := int64(0)
+	for _, arg := range args {
+		if _, exists := db.peekEntity(string(arg)); exists {
+			count++
+		}
+	}
+	return reply.MakeIntReply(count)
+}
+
+// execTouch 更新 key 的访问信息, 返回存在的 key 的个数
+// TOUCH key [key ...]
+func execTouch(db *DB, args [][]byte) resp.Reply {
+	count := int64(0)
+	for _, arg := range args {
+		if _, exists := db.GetEntity(string(arg)); exists {
+			count++
+		}
+	}
+	return reply.MakeIntReply(count)
+}
+
+// execType 返回 key 的类型, key 不存在时返回 none
+// TYPE key
+func execType(db *DB, args [][]byte) resp.Reply {
+	entity, exists := db.peekEntity(string(args[0]))
+	if !exists {
+		return reply.MakeStatusReply("none")
+	}
+	return reply.MakeStatusReply(typeName(entity))
+}
+
+// rename 是 RENAME 和 RENAMENX 的公共实现, 过期时间随 key 一起转移
+func rename(db *DB, args [][]byte, nx bool) resp.Reply {
+	src, dest := string(args[0]), string(args[1])
+	entity, exists := db.GetEntity(src)
+	if !exists {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	if src == dest {
+		if nx {
+			return reply.MakeIntReply(0)
+		}
+		return reply.MakeOkReply()
+	}
+	if _, exists = db.GetEntity(dest); exists && nx {
+		return reply.MakeIntReply(0)
+	}
+	expireTime, hasTTL := db.GetExpireTime(src)
+	db.Remove(src)
+	db.Remove(dest)
+	db.PutEntity(dest, entity)
+	if hasTTL {
+		db.Expire(dest, expireTime)
+	}
+	db.notify(notifyGeneric, "rename_from", src)
+	db.notify(notifyGeneric, "rename_to", dest)
+	if nx {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeOkReply()
+}
+
+// execRename 将 key 重命名为 newkey, newkey 已经存在时被覆盖
+// RENAME key newkey
+func execRename(db *DB, args [][]byte) resp.Reply {
+	return rename(db, args, false)
+}
+
+// execRenameNX 仅当 newkey 不存在时将 key 重命名为 newkey, 成功时返回 1
+// RENAMENX key newkey
+func execRenameNX(db *DB, args [][]byte) resp.Reply {
+	return rename(db, args, true)
+}
+
+// execKeys 返回所有匹配 pattern 的 key, 需要遍历整个数据库
+// KEYS pattern
+func execKeys(db *DB, args [][]byte) resp.Reply {
+	pattern := string(args[0])
+	now := time.Now()
+	result := make([][]byte, 0)
+	db.data.ForEach(func(key string, val interface{}) bool {
+		if pattern != "*" && !glob.Match(pattern, key) {
+			return true
+		}
+		// 遍历时不删除 key, 只跳过已过期的 key
+		if expireTime, ok := db.GetExpireTime(key); ok && now.After(expireTime) {
+			return true
+		}
+		result = append(result, []byte(key))
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execScan 使用游标迭代当前数据库中的 key, 不会像 KEYS 一样长时间阻塞
+// 遍历开始时存在并且没有被删除的 key 至少会被返回一次, 但是可能返回重复的 key
+// SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func execScan(db *DB, args [][]byte) resp.Reply {
+	cursor, errReply := parseScanCursor(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	opts, errReply := parseScanOptions(args[1:], false, true)
+	if errReply != nil {
+		return errReply
+	}
+	var keys []string
+	cursor = scanLoop(cursor, opts.count, func(cursor uint64) uint64 {
+		return db.data.Scan(cursor, func(key string, val interface{}) {
+			keys = append(keys, key)
+		})
+	}, func() int { return len(keys) })
+
+	// 遍历结束之后再过滤, 因为删除已过期的 key 可能导致哈希表缩容
+	elements := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		if !opts.match(key) {
+			continue
+		}
+		entity, exists := db.peekEntity(key)
+		if !exists {
+			continue
+		}
+		if opts.typeName != "" && typeName(entity) != opts.typeName {
+			continue
+		}
+		elements = append(elements, []byte(key))
+	}
+	return makeScanReply(cursor, elements)
+}
+
+// execRandomKey 随机返回一个 key, 数据库为空时返回 nil
+// RANDOMKEY
+func execRandomKey(db *DB, args [][]byte) resp.Reply {
+	for {
+		keys := db.data.RandomKeys(1)
+		if len(keys) == 0 {
+			return reply.MakeNullBulkReply()
+		}
+		// 抽到已过期的 key 时删除并重新抽取
+		if !db.IsExpired(keys[0]) {
+			return reply.MakeBulkReply([]byte(keys[0]))
+		}
+	}
+}
+
+// execCopy 将 source 的值复制到 destination, 可以复制到其它数据库, 过期时间同样被复制
+// destination 已经存在时返回 0, 使用 REPLACE 选项时覆盖
+// COPY source destination [DB destination-db] [REPLACE]
+func execCopy(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	src, dest := string(args[0]), string(args[1])
+	srcDB, errReply := mdb.selectDB(c.GetDBIndex())
+	if errReply != nil {
+		return errReply
+	}
+	dstDB := srcDB
+	replace := false
+	for i := 2; i < len(args); i++ {
+		switch option := toUpper(args[i]); {
+		case option == "REPLACE":
+			replace = true
+		case option == "DB" && i+1 < len(args):
+			dstIndex, errReply := mdb.parseDBIndex(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			dstDB = mdb.dbSet[dstIndex]
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	if srcDB == dstDB && src == dest {
+		return reply.MakeErrReply("ERR source and destination objects are the same")
+	}
+
+	entity, exists := srcDB.GetEntity(src)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	if _, exists = dstDB.GetEntity(dest); exists {
+		if !replace {
+			return reply.MakeIntReply(0)
+		}
+		dstDB.Remove(dest)
+	}
+	dstDB.PutEntity(dest, &database.DataEntity{Data: copyData(entity.Data), Memory: entity.Memory})
+	if expireTime, ok := srcDB.GetExpireTime(src); ok {
+		dstDB.Expire(dest, expireTime)
+	}
+	dstDB.notify(notifyGeneric, "copy_to", dest)
+	return reply.MakeIntReply(1)
+}
+
+func init() {
+	registerCommand("Del", execDel, -2, FlagWrite, 1, -1, 1)
+	registerCommand("Unlink", execDel, -2, FlagWrite|FlagFast, 1, -1, 1)
+	registerCommand("Exists", execExists, -2, FlagReadOnly|FlagFast, 1, -1, 1)
+	registerCommand("Touch", execTouch, -2, FlagReadOnly|FlagFast, 1, -1, 1)
+	registerCommand("Type", execType, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("Rename", execRename, 3, FlagWrite, 1, 2, 1)
+	registerCommand("RenameNX", execRenameNX, 3, FlagWrite|FlagFast, 1, 2, 1)
+	registerCommand("Keys", execKeys, 2, FlagReadOnly, 0, 0, 0)
+	registerCommand("Scan", execScan, -2, FlagReadOnly, 0, 0, 0)
+	registerCommand("RandomKey", execRandomKey, 1, FlagReadOnly, 0, 0, 0)
+	registerSysCommand("Copy", execCopy, -3, FlagWrite|FlagDenyOOM, 1, 2, 1)
+}
@@ -0,0 +1,816 @@
+package database
+
+import (
+	"bytes"
+	"math"
+
+	"github.com/LynchQ/my-go-redis/datastruct/list"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsList 返回 key 对应的列表, key 不存在时返回 nil
+func (db *DB) getAsList(key string) (*list.QuickList, resp.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	l, ok := entity.Data.(*list.QuickList)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	return l, nil
+}
+
+// getOrInitList 返回 key 对应的列表, key 不存在时创建一个空列表
+func (db *DB) getOrInitList(key string) (l *list.QuickList, isNew bool, errReply resp.ErrorReply) {
+	l, errReply = db.getAsList(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if l == nil {
+		l = list.NewQuickList()
+		db.PutEntity(key, &database.DataEntity{Data: l})
+		isNew = true
+	}
+	return l, isNew, nil
+}
+
+// removeIfEmptyList 列表为空时删除 key, redis 中不存在空列表
+func (db *DB) removeIfEmptyList(key string, l *list.QuickList) {
+	if l.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// 列表的方向
+const (
+	listHead = iota // LEFT
+	listTail        // RIGHT
+)
+
+// parseListDirection 解析 LEFT|RIGHT
+func parseListDirection(arg []byte) (int, bool) {
+	switch toUpper(arg) {
+	case "LEFT":
+		return listHead, true
+	case "RIGHT":
+		return listTail, true
+	}
+	return 0, false
+}
+
+// listPop 从列表的一端弹出元素
+func listPop(l *list.QuickList, where int) []byte {
+	if where == listHead {
+		return l.PopFront()
+	}
+	return l.PopBack()
+}
+
+// listPush 在列表的一端添加元素
+func listPush(l *list.QuickList, where int, val []byte) {
+	if where == listHead {
+		l.PushFront(val)
+	} else {
+		l.PushBack(val)
+	}
+}
+
+// listPopEvent 返回从列表的一端弹出元素时发布的事件
+func listPopEvent(where int) string {
+	if where == listHead {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+// listPushEvent 返回在列表的一端添加元素时发布的事件
+func listPushEvent(where int) string {
+	if where == listHead {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+// normalizeIndex 将负数下标转换为正数下标, 不检查越界
+func normalizeIndex(index int64, size int) int64 {
+	if index < 0 {
+		index += int64(size)
+	}
+	return index
+}
+
+// push 是 LPUSH/RPUSH/LPUSHX/RPUSHX 的公共实现
+func push(db *DB, args [][]byte, where int, onlyIfExists bool) resp.Reply {
+	key := string(args[0])
+	if onlyIfExists {
+		l, errReply := db.getAsList(key)
+		if errReply != nil {
+			return errReply
+		}
+		if l == nil {
+			return reply.MakeIntReply(0)
+		}
+	}
+	l, _, errReply := db.getOrInitList(key)
+	if errReply != nil {
+		return errReply
+	}
+	for _, val := range args[1:] {
+		listPush(l, where, val)
+	}
+	db.signalKeyAsReady(key)
+	db.notify(notifyList, listPushEvent(where), key)
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// execLPush 在列表头部插入元素, 返回列表的长度
+// LPUSH key element [element ...]
+func execLPush(db *DB, args [][]byte) resp.Reply {
+	return push(db, args, listHead, false)
+}
+
+// execRPush 在列表尾部插入元素, 返回列表的长度
+// RPUSH key element [element ...]
+func execRPush(db *DB, args [][]byte) resp.Reply {
+	return push(db, args, listTail, false)
+}
+
+// execLPushX 仅当列表存在时在头部插入元素
+// LPUSHX key element [element ...]
+func execLPushX(db *DB, args [][]byte) resp.Reply {
+	return push(db, args, listHead, true)
+}
+
+// execRPushX 仅当列表存在时在尾部插入元素
+// RPUSHX key element [element ...]
+func execRPushX(db *DB, args [][]byte) resp.Reply {
+	return push(db, args, listTail, true)
+}
+
+// pop 是 LPOP/RPOP 的公共实现
+func pop(db *DB, args [][]byte, where int) resp.Reply {
+	key := string(args[0])
+	if len(args) > 2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	count := int64(-1)
+	if len(args) == 2 {
+		var errReply resp.ErrorReply
+		count, errReply = parseInt64(args[1])
+		if errReply != nil || count < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+	}
+
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		if count >= 0 {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if count < 0 {
+		val := listPop(l, where)
+		db.notify(notifyList, listPopEvent(where), key)
+		db.removeIfEmptyList(key, l)
+		return reply.MakeBulkReply(val)
+	}
+	if count > int64(l.Len()) {
+		count = int64(l.Len())
+	}
+	result := make([][]byte, count)
+	for i := range result {
+		result[i] = listPop(l, where)
+	}
+	if count > 0 {
+		db.notify(notifyList, listPopEvent(where), key)
+	}
+	db.removeIfEmptyList(key, l)
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execLPop 从列表头部弹出元素
+// LPOP key [count]
+func execLPop(db *DB, args [][]byte) resp.Reply {
+	return pop(db, args, listHead)
+}
+
+// execRPop 从列表尾部弹出元素
+// RPOP key [count]
+func execRPop(db *DB, args [][]byte) resp.Reply {
+	return pop(db, args, listTail)
+}
+
+// execLRange 返回列表中指定区间内的元素, start 和 stop 均包含在内
+// LRANGE key start stop
+func execLRange(db *DB, args [][]byte) resp.Reply {
+	start, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseInt64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+
+	size := l.Len()
+	start, stop = normalizeIndex(start, size), normalizeIndex(stop, size)
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= int64(size) {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	if stop >= int64(size) {
+		stop = int64(size) - 1
+	}
+	return reply.MakeMultiBulkReply(l.Range(int(start), int(stop)+1))
+}
+
+// execLIndex 返回列表中下标为 index 的元素
+// LINDEX key index
+func execLIndex(db *DB, args [][]byte) resp.Reply {
+	index, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeNullBulkReply()
+	}
+	index = normalizeIndex(index, l.Len())
+	if index < 0 || index >= int64(l.Len()) {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(l.Get(int(index)))
+}
+
+// execLSet 修改列表中下标为 index 的元素
+// LSET key index element
+func execLSet(db *DB, args [][]byte) resp.Reply {
+	index, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	index = normalizeIndex(index, l.Len())
+	if index < 0 || index >= int64(l.Len()) {
+		return reply.MakeErrReply("ERR index out of range")
+	}
+	l.Set(int(index), args[2])
+	db.notify(notifyList, "lset", string(args[0]))
+	return reply.MakeOkReply()
+}
+
+// execLInsert 在 pivot 之前或之后插入元素, 返回列表的长度, 找不到 pivot 时返回 -1
+// LINSERT key BEFORE | AFTER pivot element
+func execLInsert(db *DB, args [][]byte) resp.Reply {
+	var after bool
+	switch toUpper(args[1]) {
+	case "BEFORE":
+	case "AFTER":
+		after = true
+	default:
+		return reply.MakeSyntaxErrReply()
+	}
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	pivot := -1
+	l.ForEach(func(i int, val []byte) bool {
+		if bytes.Equal(val, args[2]) {
+			pivot = i
+			return false
+		}
+		return true
+	})
+	if pivot < 0 {
+		return reply.MakeIntReply(-1)
+	}
+	if after {
+		pivot++
+	}
+	l.Insert(pivot, args[3])
+	db.notify(notifyList, "linsert", string(args[0]))
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// execLRem 删除列表中等于 element 的元素
+// count > 0 从头部开始删除 count 个, count < 0 从尾部开始删除 -count 个, count = 0 删除所有
+// LREM key count element
+func execLRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	count, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	// 删除的个数不会超过列表的长度
+	limit := count
+	if limit < 0 {
+		limit = -limit
+	}
+	if limit > int64(l.Len()) || limit < 0 {
+		limit = int64(l.Len())
+	}
+	var removed int
+	if count >= 0 {
+		removed = l.RemoveByVal(args[2], int(limit))
+	} else {
+		removed = l.ReverseRemoveByVal(args[2], int(limit))
+	}
+	if removed > 0 {
+		db.notify(notifyList, "lrem", key)
+	}
+	db.removeIfEmptyList(key, l)
+	return reply.MakeIntReply(int64(removed))
+}
+
+// execLTrim 只保留列表中指定区间内的元素
+// LTRIM key start stop
+func execLTrim(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseInt64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	l, errReply := db.getAsList(key)
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeOkReply()
+	}
+
+	size := l.Len()
+	start, stop = normalizeIndex(start, size), normalizeIndex(stop, size)
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= int64(size) {
+		db.notify(notifyList, "ltrim", key)
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+		return reply.MakeOkReply()
+	}
+	if stop >= int64(size) {
+		stop = int64(size) - 1
+	}
+	l.Trim(int(start), int(stop)+1)
+	db.notify(notifyList, "ltrim", key)
+	return reply.MakeOkReply()
+}
+
+// execLLen 返回列表的长度
+// LLEN key
+func execLLen(db *DB, args [][]byte) resp.Reply {
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(l.Len()))
+}
+
+// execLPos 返回列表中等于 element 的元素的下标
+// LPOS key element [RANK rank] [COUNT num-matches] [MAXLEN len]
+func execLPos(db *DB, args [][]byte) resp.Reply {
+	rank, count, maxLen := int64(1), int64(-1), int64(0)
+	for i := 2; i < len(args); i++ {
+		option := toUpper(args[i])
+		if i+1 >= len(args) {
+			return reply.MakeSyntaxErrReply()
+		}
+		val, errReply := parseInt64(args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		i++
+		switch option {
+		case "RANK":
+			if val == 0 || val == math.MinInt64 {
+				return reply.MakeErrReply("ERR RANK can't be zero: use 1 to start from the first match, 2 from the second ... or use negative to start from the end of the list")
+			}
+			rank = val
+		case "COUNT":
+			if val < 0 {
+				return reply.MakeErrReply("ERR COUNT can't be negative")
+			}
+			count = val
+		case "MAXLEN":
+			if val < 0 {
+				return reply.MakeErrReply("ERR MAXLEN can't be negative")
+			}
+			maxLen = val
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	l, errReply := db.getAsList(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if l == nil {
+		if count >= 0 {
+			return reply.MakeEmptyMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+
+	reverse := rank < 0
+	if reverse {
+		rank = -rank
+	}
+	var positions []resp.Reply
+	var matches, scanned int64
+	consumer := func(i int, val []byte) bool {
+		if maxLen > 0 && scanned >= maxLen {
+			return false
+		}
+		scanned++
+		if !bytes.Equal(val, args[1]) {
+			return true
+		}
+		matches++
+		if matches < rank {
+			return true
+		}
+		positions = append(positions, reply.MakeIntReply(int64(i)))
+		// 未指定 COUNT 时只需要第一个匹配, COUNT 0 表示返回所有匹配
+		if count < 0 {
+			return false
+		}
+		return count == 0 || matches-rank+1 < count
+	}
+	if reverse {
+		l.ReverseForEach(consumer)
+	} else {
+		l.ForEach(consumer)
+	}
+
+	if count < 0 {
+		if len(positions) == 0 {
+			return reply.MakeNullBulkReply()
+		}
+		return positions[0]
+	}
+	if positions == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	return reply.MakeMultiRawReply(positions)
+}
+
+// listMove 从 src 的一端弹出元素并插入到 dest 的一端, src 不存在时返回 nil
+func (db *DB) listMove(srcKey string, destKey string, from int, to int) ([]byte, resp.ErrorReply) {
+	src, errReply := db.getAsList(srcKey)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if src == nil {
+		return nil, nil
+	}
+	// 先检查目标的类型, 避免弹出元素后无法写入
+	if _, errReply = db.getAsList(destKey); errReply != nil {
+		return nil, errReply
+	}
+	// 与 redis 相同, 写入 dest 之后才删除空的 src, src 和 dest 相同时 key 不会被删除
+	val := listPop(src, from)
+	dest, _, _ := db.getOrInitList(destKey)
+	listPush(dest, to, val)
+	db.signalKeyAsReady(destKey)
+	db.notify(notifyList, listPushEvent(to), destKey)
+	db.notify(notifyList, listPopEvent(from), srcKey)
+	db.removeIfEmptyList(srcKey, src)
+	return val, nil
+}
+
+// execLMove 从 source 的一端弹出元素并插入到 destination 的一端
+// LMOVE source destination LEFT | RIGHT LEFT | RIGHT
+func execLMove(db *DB, args [][]byte) resp.Reply {
+	from, ok := parseListDirection(args[2])
+	if !ok {
+		return reply.MakeSyntaxErrReply()
+	}
+	to, ok := parseListDirection(args[3])
+	if !ok {
+		return reply.MakeSyntaxErrReply()
+	}
+	val, errReply := db.listMove(string(args[0]), string(args[1]), from, to)
+	if errReply != nil {
+		return errReply
+	}
+	if val == nil {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(val)
+}
+
+// execRPopLPush 从 source 尾部弹出元素并插入到 destination 头部
+// RPOPLPUSH source destination
+func execRPopLPush(db *DB, args [][]byte) resp.Reply {
+	val, errReply := db.listMove(string(args[0]), string(args[1]), listTail, listHead)
+	if errReply != nil {
+		return errReply
+	}
+	if val == nil {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(val)
+}
+
+// parseNumKeys 解析 numkeys 以及之后的 key, 返回 key 列表和剩余的参数
+func parseNumKeys(args [][]byte) ([]string, [][]byte, resp.ErrorReply) {
+	numKeys, errReply := parseInt64(args[0])
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if numKeys <= 0 {
+		return nil, nil, reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, nil, reply.MakeSyntaxErrReply()
+	}
+	keys := make([]string, numKeys)
+	for i := range keys {
+		keys[i] = string(args[i+1])
+	}
+	return keys, args[numKeys+1:], nil
+}
+
+// numKeysGetter 返回提取 numkeys 形式的 key 的函数, numKeysIndex 是 numkeys 在命令行中的位置
+func numKeysGetter(numKeysIndex int) func(cmdLine [][]byte) []string {
+	return func(cmdLine [][]byte) []string {
+		if len(cmdLine) <= numKeysIndex {
+			return nil
+		}
+		keys, _, errReply := parseNumKeys(cmdLine[numKeysIndex:])
+		if errReply != nil {
+			return nil
+		}
+		return keys
+	}
+}
+
+// parseMPopArgs 解析 LMPOP/BLMPOP 中 key 之后的 LEFT | RIGHT [COUNT count]
+func parseMPopArgs(args [][]byte) (where int, count int64, errReply resp.ErrorReply) {
+	if len(args) == 0 {
+		return 0, 0, reply.MakeSyntaxErrReply()
+	}
+	var ok bool
+	where, ok = parseListDirection(args[0])
+	if !ok {
+		return 0, 0, reply.MakeSyntaxErrReply()
+	}
+	count, errReply = parseMPopCount(args[1:])
+	return
+}
+
+// parseMPopCount 解析可选的 [COUNT count]
+func parseMPopCount(args [][]byte) (int64, resp.ErrorReply) {
+	count := int64(1)
+	if len(args) == 0 {
+		return count, nil
+	}
+	if len(args) != 2 || toUpper(args[0]) != "COUNT" {
+		return 0, reply.MakeSyntaxErrReply()
+	}
+	count, errReply := parseInt64(args[1])
+	if errReply != nil || count <= 0 {
+		return 0, reply.MakeErrReply("ERR count should be greater than 0")
+	}
+	return count, nil
+}
+
+// listMPop 从第一个非空列表中弹出最多 count 个元素, 所有列表都为空时返回 nil
+func (db *DB) listMPop(keys []string, where int, count int64) (resp.Reply, resp.ErrorReply) {
+	for _, key := range keys {
+		l, errReply := db.getAsList(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if l == nil {
+			continue
+		}
+		if count > int64(l.Len()) {
+			count = int64(l.Len())
+		}
+		vals := make([][]byte, count)
+		for i := range vals {
+			vals[i] = listPop(l, where)
+		}
+		db.notify(notifyList, listPopEvent(where), key)
+		db.removeIfEmptyList(key, l)
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			reply.MakeMultiBulkReply(vals),
+		}), nil
+	}
+	return nil, nil
+}
+
+// execLMPop 从第一个非空列表中弹出元素, 返回 key 和弹出的元素
+// LMPOP numkeys key [key ...] LEFT | RIGHT [COUNT count]
+func execLMPop(db *DB, args [][]byte) resp.Reply {
+	keys, rest, errReply := parseNumKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	where, count, errReply := parseMPopArgs(rest)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.listMPop(keys, where, count)
+	if errReply != nil {
+		return errReply
+	}
+	if result == nil {
+		return reply.MakeNullMultiBulkReply()
+	}
+	return result
+}
+
+/* ---- 阻塞命令 ---- */
+
+// blockingPop 是 BLPOP/BRPOP 的公共实现
+func blockingPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte, where int) resp.Reply {
+	timeout, errReply := parseTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys := keysOf(args[:len(args)-1])
+	return execBlocking(mdb, c, &blockRequest{
+		keys:    keys,
+		timeout: timeout,
+		try: func(db *DB) resp.Reply {
+			for _, key := range keys {
+				l, errReply := db.getAsList(key)
+				if errReply != nil {
+					return errReply
+				}
+				if l == nil {
+					continue
+				}
+				val := listPop(l, where)
+				db.notify(notifyList, listPopEvent(where), key)
+				db.removeIfEmptyList(key, l)
+				return reply.MakeMultiBulkReply([][]byte{[]byte(key), val})
+			}
+			return nil
+		},
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+// execBLPop 是阻塞版本的 LPOP, 从第一个非空列表的头部弹出元素
+// BLPOP key [key ...] timeout
+func execBLPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingPop(mdb, c, args, listHead)
+}
+
+// execBRPop 是阻塞版本的 RPOP, 从第一个非空列表的尾部弹出元素
+// BRPOP key [key ...] timeout
+func execBRPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingPop(mdb, c, args, listTail)
+}
+
+// blockingMove 是 BLMOVE/BRPOPLPUSH 的公共实现
+func blockingMove(mdb *StandaloneDatabase, c resp.Connection, srcKey string, destKey string, from int, to int, timeoutArg []byte) resp.Reply {
+	timeout, errReply := parseTimeout(timeoutArg)
+	if errReply != nil {
+		return errReply
+	}
+	return execBlocking(mdb, c, &blockRequest{
+		keys:      []string{srcKey},
+		writeKeys: []string{destKey},
+		timeout:   timeout,
+		try: func(db *DB) resp.Reply {
+			val, errReply := db.listMove(srcKey, destKey, from, to)
+			if errReply != nil {
+				return errReply
+			}
+			if val == nil {
+				return nil
+			}
+			return reply.MakeBulkReply(val)
+		},
+		timeoutReply: reply.MakeNullBulkReply(),
+	})
+}
+
+// execBLMove 是阻塞版本的 LMOVE
+// BLMOVE source destination LEFT | RIGHT LEFT | RIGHT timeout
+func execBLMove(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	from, ok := parseListDirection(args[2])
+	if !ok {
+		return reply.MakeSyntaxErrReply()
+	}
+	to, ok := parseListDirection(args[3])
+	if !ok {
+		return reply.MakeSyntaxErrReply()
+	}
+	return blockingMove(mdb, c, string(args[0]), string(args[1]), from, to, args[4])
+}
+
+// execBRPopLPush 是阻塞版本的 RPOPLPUSH
+// BRPOPLPUSH source destination timeout
+func execBRPopLPush(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingMove(mdb, c, string(args[0]), string(args[1]), listTail, listHead, args[2])
+}
+
+// execBLMPop 是阻塞版本的 LMPOP
+// BLMPOP timeout numkeys key [key ...] LEFT | RIGHT [COUNT count]
+func execBLMPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	timeout, errReply := parseTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	keys, rest, errReply := parseNumKeys(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	where, count, errReply := parseMPopArgs(rest)
+	if errReply != nil {
+		return errReply
+	}
+	return execBlocking(mdb, c, &blockRequest{
+		keys:    keys,
+		timeout: timeout,
+		try: func(db *DB) resp.Reply {
+			result, errReply := db.listMPop(keys, where, count)
+			if errReply != nil {
+				return errReply
+			}
+			return result
+		},
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+func init() {
+	registerCommand("LPush", execLPush, -3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("RPush", execRPush, -3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("LPushX", execLPushX, -3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("RPushX", execRPushX, -3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("LPop", execLPop, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("RPop", execRPop, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("LRange", execLRange, 4, FlagReadOnly, 1, 1, 1)
+	registerCommand("LIndex", execLIndex, 3, FlagReadOnly, 1, 1, 1)
+	registerCommand("LSet", execLSet, 4, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("LInsert", execLInsert, 5, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("LRem", execLRem, 4, FlagWrite, 1, 1, 1)
+	registerCommand("LTrim", execLTrim, 4, FlagWrite, 1, 1, 1)
+	registerCommand("LLen", execLLen, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("LPos", execLPos, -3, FlagReadOnly, 1, 1, 1)
+	registerCommand("LMove", execLMove, 5, FlagWrite|FlagDenyOOM, 1, 2, 1)
+	registerCommand("RPopLPush", execRPopLPush, 3, FlagWrite|FlagDenyOOM, 1, 2, 1)
+	registerCommand("LMPop", execLMPop, -4, FlagWrite, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerSysCommand("BLPop", execBLPop, -3, FlagWrite|FlagBlocking, 1, -2, 1)
+	registerSysCommand("BRPop", execBRPop, -3, FlagWrite|FlagBlocking, 1, -2, 1)
+	registerSysCommand("BLMove", execBLMove, 6, FlagWrite|FlagDenyOOM|FlagBlocking, 1, 2, 1)
+	registerSysCommand("BRPopLPush", execBRPopLPush, 4, FlagWrite|FlagDenyOOM|FlagBlocking, 1, 2, 1)
+	registerSysCommand("BLMPop", execBLMPop, -5, FlagWrite|FlagBlocking, 0, 0, 0).
+		setKeysFunc(numKeysGetter(2))
+}
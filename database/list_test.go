@@ -0,0 +1,57 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeConn 是测试用的客户端连接, 不写入任何数据
+type fakeConn struct {
+	dbIndex int
+}
+
+func (c *fakeConn) Write([]byte) error { return nil }
+
+func (c *fakeConn) GetDBIndex() int { return c.dbIndex }
+
+func (c *fakeConn) SelectDB(dbNum int) { c.dbIndex = dbNum }
+
+// toCmdLine 将字符串形式的命令转换为命令行
+func toCmdLine(cmd string) [][]byte {
+	fields := strings.Fields(cmd)
+	args := make([][]byte, len(fields))
+	for i, field := range fields {
+		args[i] = []byte(field)
+	}
+	return args
+}
+
+func TestLPos(t *testing.T) {
+	mdb := NewStandaloneDatabase()
+	defer mdb.Close()
+	c := &fakeConn{}
+	mdb.Exec(c, toCmdLine("RPUSH m a b a c a"))
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"LPOS m a", ":0\r\n"},
+		{"LPOS m a RANK 2", ":2\r\n"},
+		{"LPOS m a RANK -1", ":4\r\n"},
+		{"LPOS m x", "$-1\r\n"},
+		{"LPOS m a COUNT 0", "*3\r\n:0\r\n:2\r\n:4\r\n"},
+		{"LPOS m a COUNT 2", "*2\r\n:0\r\n:2\r\n"},
+		{"LPOS m a RANK 2 COUNT 0", "*2\r\n:2\r\n:4\r\n"},
+		{"LPOS m a RANK -1 COUNT 0", "*3\r\n:4\r\n:2\r\n:0\r\n"},
+		{"LPOS m a COUNT 0 MAXLEN 3", "*2\r\n:0\r\n:2\r\n"},
+		{"LPOS m x COUNT 0", "*0\r\n"},
+		{"LPOS missing a COUNT 0", "*0\r\n"},
+	}
+	for _, tc := range cases {
+		result := mdb.Exec(c, toCmdLine(tc.cmd))
+		if got := string(result.ToBytes()); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.cmd, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+package database
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/LynchQ/my-go-redis/datastruct/hash"
+	"github.com/LynchQ/my-go-redis/datastruct/list"
+	"github.com/LynchQ/my-go-redis/datastruct/set"
+	"github.com/LynchQ/my-go-redis/datastruct/sortedset"
+	"github.com/LynchQ/my-go-redis/datastruct/stream"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 内存估算
+ * 与 redis 的 zmalloc 不同, Go 无法廉价地统计每次分配的内存, 因此按照数据结构的布局估算每个 key 占用的内存
+ * 集合类型只抽样前几个元素, 用平均大小乘以元素个数得到总大小
+ * 每个 DataEntity 记录已经计入 DB 已用内存的估算值, 写命令执行之后重新估算命令涉及的 key
+ */
+
+// 估算使用的结构开销, 按照 64 位平台上 Go 的对象布局粗略计算
+const (
+	stringHeaderSize = 16                 // string 的头部
+	sliceHeaderSize  = 24                 // []byte 的头部
+	dictEntrySize    = 48                 // ChainedDict 的节点: key、val 和 next 指针
+	keyOverhead      = dictEntrySize + 48 // 键空间中的节点以及 DataEntity
+	skiplistNodeSize = 56                 // 跳表节点: Element、后退指针以及平均 1.33 层的 level
+	streamEntrySize  = 64                 // stream 消息: ID、Fields 以及基数树中的节点
+	intsetEntrySize  = 8
+)
+
+// memoryUsageSamples 是估算集合类型的内存时默认抽样的元素个数
+const memoryUsageSamples = 5
+
+// sampledSize 根据前 samples 个元素的平均大小估算 n 个元素的总大小, samples 为 0 时统计所有元素
+// forEach 依次将每个元素的大小交给 consumer, consumer 返回 false 时停止
+func sampledSize(n int64, samples int, forEach func(consumer func(size int64) bool)) int64 {
+	var total, count int64
+	forEach(func(size int64) bool {
+		total += size
+		count++
+		return samples <= 0 || count < int64(samples)
+	})
+	if count == 0 {
+		return 0
+	}
+	return total * n / count
+}
+
+// estimateMemory 估算 key 和值占用的内存, 单位为字节
+func estimateMemory(key string, entity *database.DataEntity, samples int) int64 {
+	size := int64(keyOverhead + len(key))
+	switch data := entity.Data.(type) {
+	case []byte:
+		size += sliceHeaderSize + int64(cap(data))
+	case *list.QuickList:
+		size += sampledSize(int64(data.Len()), samples, func(consumer func(int64) bool) {
+			data.ForEach(func(i int, val []byte) bool {
+				return consumer(sliceHeaderSize + int64(len(val)))
+			})
+		})
+	case *hash.Hash:
+		// listpack 中每一项只有 1 字节左右的长度前缀
+		overhead := int64(dictEntrySize + 2*stringHeaderSize)
+		if data.IsCompact() {
+			overhead = 2
+		}
+		size += sampledSize(int64(data.Len()), samples, func(consumer func(int64) bool) {
+			data.ForEach(func(field string, value string) bool {
+				return consumer(overhead + int64(len(field)+len(value)))
+			})
+		})
+	case *set.Set:
+		if data.IsIntset() {
+			size += intsetEntrySize * int64(data.Len())
+			break
+		}
+		size += sampledSize(int64(data.Len()), samples, func(consumer func(int64) bool) {
+			data.ForEach(func(member string) bool {
+				return consumer(dictEntrySize + stringHeaderSize + int64(len(member)))
+			})
+		})
+	case *sortedset.SortedSet:
+		size += sampledSize(data.Len(), samples, func(consumer func(int64) bool) {
+			data.ForEach(func(element *sortedset.Element) bool {
+				return consumer(dictEntrySize + skiplistNodeSize + int64(len(element.Member)))
+			})
+		})
+	case *stream.Stream:
+		// 消费者组的内存没有计算在内
+		size += sampledSize(data.Len(), samples, func(consumer func(int64) bool) {
+			data.ForEachInRange(stream.MinID, stream.MaxID, false, func(entry *stream.Entry) bool {
+				entrySize := int64(streamEntrySize)
+				for _, field := range entry.Fields {
+					entrySize += stringHeaderSize + int64(len(field))
+				}
+				return consumer(entrySize)
+			})
+		})
+	}
+	return size
+}
+
+// addMemory 调整 DB 的已用内存
+func (db *DB) addMemory(delta int64) {
+	atomic.AddInt64(&db.usedMemory, delta)
+}
+
+// replaceMemory 在 key 的值由 old 替换为 entity 之后调整已用内存, old 为 nil 表示新插入的 key
+func (db *DB) replaceMemory(old interface{}, entity *database.DataEntity) {
+	delta := entity.Memory
+	if old != nil {
+		delta -= old.(*database.DataEntity).Memory
+	}
+	db.addMemory(delta)
+}
+
+// updateMemory 重新估算 key 占用的内存, 在写命令执行之后调用, 调用者需要锁定 key
+func (db *DB) updateMemory(keys ...string) {
+	for _, key := range keys {
+		raw, exists := db.data.Get(key)
+		if !exists {
+			continue
+		}
+		entity := raw.(*database.DataEntity)
+		size := estimateMemory(key, entity, memoryUsageSamples)
+		db.addMemory(size - entity.Memory)
+		entity.Memory = size
+	}
+}
+
+// usedMemory 返回所有 DB 的已用内存之和
+func (mdb *StandaloneDatabase) usedMemory() int64 {
+	total := int64(0)
+	for _, db := range mdb.dbSet {
+		total += atomic.LoadInt64(&db.usedMemory)
+	}
+	return total
+}
+
+/* ---- MEMORY 命令 ---- */
+
+const (
+	// clientMemorySize 是估算的每个客户端占用的内存: 解析协议的 bufio.Reader 缓冲区以及处理连接和解析协议的两个协程的栈
+	clientMemorySize = 4096 + 2*8192
+	// expireEntrySize 是过期字典中的节点: ChainedDict 的节点以及 time.Time
+	expireEntrySize = dictEntrySize + 24
+	// bucketSize 是哈希表中每个桶的指针
+	bucketSize = 8
+)
+
+// MEMORY DOCTOR 的诊断阈值, 与 redis 一致
+const (
+	doctorEmptyMemory   = 5 << 20  // 启动之后新增的已用内存低于该值时不做诊断
+	doctorPeakRatio     = 1.5      // 峰值超过已用内存的倍数
+	doctorFragRatio     = 1.4      // 进程内存超过 Go 堆已分配内存的倍数
+	doctorFragMinMemory = 10 << 20 // 碎片超过该值时才报告
+)
+
+// readMemStats 读取 Go 运行时的内存统计, 会短暂地暂停所有协程
+func readMemStats() *runtime.MemStats {
+	stats := &runtime.MemStats{}
+	runtime.ReadMemStats(stats)
+	return stats
+}
+
+// allocatedMemory 返回启动时的内存、客户端以及所有 key 估算占用的内存之和, 不包括哈希表的桶, 供定时任务更新峰值
+func (mdb *StandaloneDatabase) allocatedMemory() int64 {
+	return mdb.startupMemory + atomic.LoadInt64(&mdb.numClients)*clientMemorySize + mdb.usedMemory()
+}
+
+// updatePeakMemory 更新已用内存的峰值, 调用者需要持有排它锁
+func (mdb *StandaloneDatabase) updatePeakMemory(allocated int64) {
+	if allocated > mdb.peakMemory {
+		mdb.peakMemory = allocated
+	}
+}
+
+// dbMemoryStats 是一个 DB 中键空间和过期字典的结构开销
+type dbMemoryStats struct {
+	index    int
+	main     int64
+	expires  int64
+	keyCount int64
+}
+
+// memoryStats 是 MEMORY STATS 和 MEMORY DOCTOR 使用的内存统计
+// 与 redis 相同, total 为估算的已用内存, 由结构开销 overhead 和数据 dataset 两部分组成
+type memoryStats struct {
+	peak     int64
+	total    int64
+	startup  int64
+	clients  int64
+	aof      int64 // 没有实现 AOF, 始终为 0
+	dbs      []dbMemoryStats
+	overhead int64
+	keyCount int64
+	dataset  int64
+	runtime  *runtime.MemStats
+}
+
+// getMemoryStats 统计内存使用情况并更新峰值, 调用者需要持有排它锁
+func (mdb *StandaloneDatabase) getMemoryStats() *memoryStats {
+	stats := &memoryStats{
+		startup: mdb.startupMemory,
+		clients: atomic.LoadInt64(&mdb.numClients) * clientMemorySize,
+		runtime: readMemStats(),
+	}
+	stats.overhead = stats.startup + stats.clients + stats.aof
+	used := int64(0)
+	for _, db := range mdb.dbSet {
+		keyCount := int64(db.data.Len())
+		used += atomic.LoadInt64(&db.usedMemory)
+		if keyCount == 0 {
+			continue
+		}
+		dbStats := dbMemoryStats{
+			index:    db.index,
+			main:     keyCount*keyOverhead + int64(db.data.TableSize())*bucketSize,
+			expires:  int64(db.ttlMap.Len())*expireEntrySize + int64(db.ttlMap.TableSize())*bucketSize,
+			keyCount: keyCount,
+		}
+		stats.dbs = append(stats.dbs, dbStats)
+		stats.overhead += dbStats.main + dbStats.expires
+		stats.keyCount += keyCount
+		// 键空间中节点的开销已经计入 key 的估算值, 从数据中扣除
+		used -= keyCount * keyOverhead
+	}
+	if used < 0 {
+		used = 0
+	}
+	stats.dataset = used
+	stats.total = stats.overhead + stats.dataset
+	mdb.updatePeakMemory(stats.total)
+	stats.peak = mdb.peakMemory
+	return stats
+}
+
+// ratio 返回 a / b, b 为 0 时返回 0
+func ratio(a, b int64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+// makeMemoryStatsReply 按照 redis 的格式返回 MEMORY STATS 的结果
+// allocator 开头的字段是 Go 运行时的堆: allocated 为已分配的对象, active 为正在使用的内存页, resident 为向操作系统申请并且没有归还的内存
+func makeMemoryStatsReply(stats *memoryStats) resp.Reply {
+	heapAlloc := int64(stats.runtime.HeapAlloc)
+	heapInuse := int64(stats.runtime.HeapInuse)
+	heapResident := int64(stats.runtime.HeapSys - stats.runtime.HeapReleased)
+	sys := int64(stats.runtime.Sys)
+
+	var replies []resp.Reply
+	addInt := func(name string, val int64) {
+		replies = append(replies, reply.MakeBulkReply([]byte(name)), reply.MakeIntReply(val))
+	}
+	addFloat := func(name string, val float64) {
+		replies = append(replies, reply.MakeBulkReply([]byte(name)), reply.MakeBulkReply([]byte(formatFloat(val))))
+	}
+	addInt("peak.allocated", stats.peak)
+	addInt("total.allocated", stats.total)
+	addInt("startup.allocated", stats.startup)
+	addInt("replication.backlog", 0)
+	addInt("clients.slaves", 0)
+	addInt("clients.normal", stats.clients)
+	addInt("aof.buffer", stats.aof)
+	for _, db := range stats.dbs {
+		replies = append(replies, reply.MakeBulkReply([]byte("db."+strconv.Itoa(db.index))), reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("overhead.hashtable.main")), reply.MakeIntReply(db.main),
+			reply.MakeBulkReply([]byte("overhead.hashtable.expires")), reply.MakeIntReply(db.expires),
+		}))
+	}
+	addInt("overhead.total", stats.overhead)
+	addInt("keys.count", stats.keyCount)
+	bytesPerKey := int64(0)
+	if stats.keyCount > 0 {
+		bytesPerKey = (stats.total - stats.startup) / stats.keyCount
+	}
+	addInt("keys.bytes-per-key", bytesPerKey)
+	addInt("dataset.bytes", stats.dataset)
+	addFloat("dataset.percentage", ratio(stats.dataset, stats.total-stats.startup)*100)
+	addFloat("peak.percentage", ratio(stats.total, stats.peak)*100)
+	addInt("allocator.allocated", heapAlloc)
+	addInt("allocator.active", heapInuse)
+	addInt("allocator.resident", heapResident)
+	addFloat("allocator-fragmentation.ratio", ratio(heapInuse, heapAlloc))
+	addInt("allocator-fragmentation.bytes", heapInuse-heapAlloc)
+	addFloat("fragmentation", ratio(sys, heapAlloc))
+	addInt("fragmentation.bytes", sys-heapAlloc)
+	return reply.MakeMultiRawReply(replies)
+}
+
+// memoryDoctorReport 参照 redis 的 getMemoryDoctorReport 生成诊断报告
+func memoryDoctorReport(stats *memoryStats) string {
+	// 分段字典在启动时就占用了数 MB 内存, 所以不计算启动时的内存
+	if stats.total-stats.startup < doctorEmptyMemory {
+		return "Hi Sam, this instance is empty or is using very little memory, " +
+			"my issues detector can't be used in these conditions. " +
+			"Please, leave for your mission on Earth and fill it with some data. " +
+			"The new Sam and I will be back to our programming as soon as I finished rebooting."
+	}
+
+	var issues []string
+	if ratio(stats.peak, stats.total) > doctorPeakRatio {
+		issues = append(issues, " * Peak memory: In the past this instance used more than 150% the memory that is currently using. "+
+			"The Go runtime returns free memory to the operating system gradually after garbage collection, "+
+			"so the process may still be larger than expected for a while. "+
+			"If you want to try to reclaim memory now, please try the MEMORY PURGE command.")
+	}
+	heapAlloc := int64(stats.runtime.HeapAlloc)
+	sys := int64(stats.runtime.Sys)
+	if ratio(sys, heapAlloc) > doctorFragRatio && sys-heapAlloc > doctorFragMinMemory {
+		issues = append(issues, " * High total RSS: This instance has a memory fragmentation and RSS overhead greater than 1.4 "+
+			"(this means that the memory obtained by the process from the operating system is much larger than "+
+			"the sum of the objects allocated on the Go heap). "+
+			"This problem is usually due either to a large peak memory (check if there is a peak memory entry above in the report) "+
+			"or to garbage that has not been collected yet. If the problem is a large peak memory, then there is no issue. "+
+			"Otherwise, consider lowering GOGC to collect garbage more often.")
+	}
+	if len(issues) == 0 {
+		return "Hi Sam, I can't find any memory issue in your instance. I can only account for what occurs on this base."
+	}
+	var sb strings.Builder
+	sb.WriteString("Sam, I detected a few issues in this Redis instance memory implants:\n\n")
+	for _, issue := range issues {
+		sb.WriteString(issue + "\n\n")
+	}
+	sb.WriteString("I'm here to keep you safe, Sam. I want to help you.\n")
+	return sb.String()
+}
+
+var memoryHelp = []string{
+	"MEMORY <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"DOCTOR",
+	"    Return memory problems reports.",
+	"MALLOC-STATS",
+	"    Return internal statistics report from the memory allocator.",
+	"PURGE",
+	"    Attempt to purge dirty pages for reclamation by the allocator.",
+	"STATS",
+	"    Return information about the memory usage of the server.",
+	"USAGE <key> [SAMPLES <count>]",
+	"    Return memory in bytes used by <key> and its value. Nested values are",
+	"    sampled up to <count> times (default: 5, 0 means sample all).",
+	"HELP",
+	"    Print this help.",
+}
+
+// execMemoryUsage 返回 key 和值估算占用的内存, 不会更新 key 的访问信息
+// MEMORY USAGE key [SAMPLES count]
+func execMemoryUsage(db *DB, args [][]byte) resp.Reply {
+	samples := memoryUsageSamples
+	for i := 1; i < len(args); i++ {
+		if toUpper(args[i]) != "SAMPLES" || i == len(args)-1 {
+			return reply.MakeSyntaxErrReply()
+		}
+		count, errReply := parseInt64(args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		if count < 0 {
+			return reply.MakeSyntaxErrReply()
+		}
+		samples = int(count)
+		i++
+	}
+	key := string(args[0])
+	entity, exists := db.peekEntity(key)
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeIntReply(estimateMemory(key, entity, samples))
+}
+
+// execMemory 内存统计和诊断
+// MEMORY USAGE key [SAMPLES count]
+// MEMORY STATS | DOCTOR | MALLOC-STATS | PURGE | HELP
+func execMemory(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	switch {
+	case subCmd == "help" && len(args) == 1:
+		lines := make([]resp.Reply, len(memoryHelp))
+		for i, line := range memoryHelp {
+			lines[i] = reply.MakeStatusReply(line)
+		}
+		return reply.MakeMultiRawReply(lines)
+	case subCmd == "usage" && len(args) >= 2:
+		db, errReply := mdb.selectDB(c.GetDBIndex())
+		if errReply != nil {
+			return errReply
+		}
+		return execMemoryUsage(db, args[1:])
+	case subCmd == "stats" && len(args) == 1:
+		return makeMemoryStatsReply(mdb.getMemoryStats())
+	case subCmd == "doctor" && len(args) == 1:
+		return reply.MakeBulkReply([]byte(memoryDoctorReport(mdb.getMemoryStats())))
+	case subCmd == "malloc-stats" && len(args) == 1:
+		return reply.MakeBulkReply([]byte("Stats not supported for the current allocator"))
+	case subCmd == "purge" && len(args) == 1:
+		debug.FreeOSMemory()
+		return reply.MakeOkReply()
+	}
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'. Try MEMORY HELP.")
+}
+
+// memoryKeysGetter 提取 MEMORY USAGE 的 key, 其它子命令没有 key
+func memoryKeysGetter(cmdLine [][]byte) []string {
+	if len(cmdLine) >= 3 && strings.ToLower(string(cmdLine[1])) == "usage" {
+		return []string{string(cmdLine[2])}
+	}
+	return nil
+}
+
+func init() {
+	registerSysCommand("Memory", execMemory, -2, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(memoryKeysGetter)
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strconv"
+
+	"github.com/LynchQ/my-go-redis/config"
+)
+
+/*
+ * 键空间通知
+ * 命令修改 key、key 过期或者被淘汰时, 按照 notify-keyspace-events 的配置
+ * 向 __keyspace@<db>__:<key> 频道发布事件名, 向 __keyevent@<db>__:<event> 频道发布 key
+ * 与 redis 相同, 事件在修改完成之后、命令返回之前发布
+ */
+
+// 通知的类型, 与 notify-keyspace-events 中的字符一一对应
+const (
+	notifyKeyspace = 1 << iota // K: 发布到 __keyspace@<db>__ 频道
+	notifyKeyevent             // E: 发布到 __keyevent@<db>__ 频道
+	notifyGeneric              // g: DEL、EXPIRE、RENAME 等与类型无关的命令
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x: key 过期
+	notifyEvicted              // e: key 被 maxmemory 淘汰
+	notifyStream               // t
+	notifyKeyMiss              // m: 只读命令访问不存在的 key
+	notifyNew                  // n: 写入新的 key
+	// A 是 g$lshzxet 的别名, 不包括 m 和 n
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash |
+		notifyZSet | notifyExpired | notifyEvicted | notifyStream
+)
+
+// notifyClasses 是 notify-keyspace-events 中的字符对应的通知类型
+var notifyClasses = map[byte]int{
+	'K': notifyKeyspace,
+	'E': notifyKeyevent,
+	'g': notifyGeneric,
+	'$': notifyString,
+	'l': notifyList,
+	's': notifySet,
+	'h': notifyHash,
+	'z': notifyZSet,
+	'x': notifyExpired,
+	'e': notifyEvicted,
+	't': notifyStream,
+	'm': notifyKeyMiss,
+	'n': notifyNew,
+	'A': notifyAll,
+}
+
+// parseKeyspaceEvents 解析 notify-keyspace-events, 包含未知的字符时 ok 为 false
+func parseKeyspaceEvents(classes string) (flags int, ok bool) {
+	for i := 0; i < len(classes); i++ {
+		class, known := notifyClasses[classes[i]]
+		if !known {
+			return 0, false
+		}
+		flags |= class
+	}
+	return flags, true
+}
+
+// keyspaceEvents 返回当前配置的通知类型, 配置无效时不发布通知
+func keyspaceEvents() int {
+	if config.Properties.NotifyKeyspaceEvents == "" {
+		return 0
+	}
+	flags, _ := parseKeyspaceEvents(config.Properties.NotifyKeyspaceEvents)
+	return flags
+}
+
+// notify 发布 key 上发生的事件, class 为事件的类型
+// 只有配置中包含 class 并且包含 K 或 E 时才会发布
+func (db *DB) notify(class int, event string, key string) {
+	flags := keyspaceEvents()
+	if flags&class == 0 {
+		return
+	}
+	if flags&notifyKeyspace != 0 {
+		db.pubsub.publish("__keyspace@"+strconv.Itoa(db.index)+"__:"+key, []byte(event))
+	}
+	if flags&notifyKeyevent != 0 {
+		db.pubsub.publish("__keyevent@"+strconv.Itoa(db.index)+"__:"+event, []byte(key))
+	}
+}
+
+// notifyKeyMiss 在只读命令执行之后为不存在的 key 发布 keymiss 事件
+func (db *DB) notifyKeyMiss(keys []string) {
+	if keyspaceEvents()&notifyKeyMiss == 0 {
+		return
+	}
+	for _, key := range keys {
+		if _, exists := db.data.Get(key); !exists {
+			db.notify(notifyKeyMiss, "keymiss", key)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package database
+
+import (
+	"math/rand"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * key 的访问信息和 OBJECT 命令
+ * 与 redis 相同, 使用 LRU 淘汰策略时只记录最后一次访问的时间;
+ * 使用 LFU 淘汰策略时还维护一个 8 位的对数计数器, 访问时以一定的概率加 1, 长时间没有访问时衰减
+ */
+
+// lfuInitVal 是新写入的 key 的访问计数, 使新 key 不会立即被淘汰
+const lfuInitVal = 5
+
+// embstrSizeLimit 是使用 embstr 编码的字符串的最大长度
+const embstrSizeLimit = 44
+
+// isLFUPolicy 判断当前的淘汰策略是否是 LFU
+func isLFUPolicy() bool {
+	policy := maxmemoryPolicy()
+	return policy == policyAllKeysLFU || policy == policyVolatileLFU
+}
+
+// lfuLogIncr 以对数的方式增加计数器, 计数器越大增加的概率越小
+func lfuLogIncr(counter uint8) uint8 {
+	if counter == 255 {
+		return counter
+	}
+	baseVal := float64(counter) - lfuInitVal
+	if baseVal < 0 {
+		baseVal = 0
+	}
+	p := 1.0 / (baseVal*float64(config.Properties.LfuLogFactor) + 1)
+	if rand.Float64() < p {
+		counter++
+	}
+	return counter
+}
+
+// lfuDecr 返回衰减之后的计数器, 每经过 lfu-decay-time 分钟减 1, 不修改 entity
+func lfuDecr(entity *database.DataEntity) uint8 {
+	decayTime := int64(config.Properties.LfuDecayTime)
+	if decayTime <= 0 {
+		return entity.Freq
+	}
+	periods := (nowMilli() - entity.AccessTime) / 60000 / decayTime
+	if periods >= int64(entity.Freq) {
+		return 0
+	}
+	return entity.Freq - uint8(periods)
+}
+
+// touchEntity 在访问 key 时更新访问信息
+func touchEntity(entity *database.DataEntity) {
+	if isLFUPolicy() {
+		entity.Freq = lfuLogIncr(lfuDecr(entity))
+	}
+	entity.AccessTime = nowMilli()
+}
+
+// initAccess 初始化新写入的 DataEntity 的访问信息, 已经初始化过的 DataEntity(例如 RENAME、MOVE)保持不变
+// 与 redis 相同, 覆盖已有的 key 时保留原来的访问计数
+func (db *DB) initAccess(key string, entity *database.DataEntity) {
+	if entity.AccessTime != 0 {
+		return
+	}
+	entity.AccessTime = nowMilli()
+	entity.Freq = lfuInitVal
+	if raw, ok := db.data.Get(key); ok {
+		entity.Freq = raw.(*database.DataEntity).Freq
+	}
+}
+
+// stringEncoding 根据内容推断字符串的编码: 可以表示为 64 位整数时为 int, 较短时为 embstr, 否则为 raw
+func stringEncoding(value []byte) string {
+	if _, ok := strictParseInt64(value); ok {
+		return "int"
+	}
+	if len(value) <= embstrSizeLimit {
+		return "embstr"
+	}
+	return "raw"
+}
+
+// objectEncoding 返回值的内部编码
+func objectEncoding(entity *database.DataEntity) string {
+	switch data := entity.Data.(type) {
+	case []byte:
+		return stringEncoding(data)
+	case interface{ Encoding() string }:
+		return data.Encoding()
+	}
+	return "unknown"
+}
+
+var objectHelp = []string{
+	"OBJECT <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"ENCODING <key>",
+	"    Return the kind of internal representation used in order to store the value",
+	"    associated with a <key>.",
+	"FREQ <key>",
+	"    Return the access frequency index of the <key>. The returned integer is",
+	"    proportional to the logarithm of the recent access frequency of the key.",
+	"IDLETIME <key>",
+	"    Return the idle time of the <key>, that is the approximated number of",
+	"    seconds elapsed since the last access to the key.",
+	"REFCOUNT <key>",
+	"    Return the number of references of the value associated with the specified",
+	"    <key>.",
+	"HELP",
+	"    Print this help.",
+}
+
+// execObject 查看 key 的内部信息, 不会更新 key 的访问信息
+// OBJECT ENCODING|FREQ|IDLETIME|REFCOUNT key
+// OBJECT HELP
+func execObject(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	if subCmd == "help" && len(args) == 1 {
+		lines := make([]resp.Reply, len(objectHelp))
+		for i, line := range objectHelp {
+			lines[i] = reply.MakeStatusReply(line)
+		}
+		return reply.MakeMultiRawReply(lines)
+	}
+	switch subCmd {
+	case "encoding", "freq", "idletime", "refcount":
+		if len(args) != 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'")
+		}
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try OBJECT HELP.")
+	}
+
+	entity, exists := db.peekEntity(string(args[1]))
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	switch subCmd {
+	case "encoding":
+		return reply.MakeBulkReply([]byte(objectEncoding(entity)))
+	case "freq":
+		if !isLFUPolicy() {
+			return reply.MakeErrReply("ERR An LFU maxmemory policy is not selected, access frequency not tracked. " +
+				"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+		}
+		return reply.MakeIntReply(int64(lfuDecr(entity)))
+	case "idletime":
+		if isLFUPolicy() {
+			return reply.MakeErrReply("ERR An LFU maxmemory policy is selected, idle time not tracked. " +
+				"Please note that when switching between policies at runtime LRU and LFU data will take some time to adjust.")
+		}
+		return reply.MakeIntReply((nowMilli() - entity.AccessTime) / 1000)
+	default:
+		// 值不会在 key 之间共享
+		return reply.MakeIntReply(1)
+	}
+}
+
+func init() {
+	registerCommand("Object", execObject, -2, FlagReadOnly, 2, 2, 1)
+}
@@ -0,0 +1,452 @@
+package database
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/lib/glob"
+	"github.com/LynchQ/my-go-redis/lib/logger"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 发布订阅
+ * 客户端可以订阅频道(SUBSCRIBE)或者频道的模式(PSUBSCRIBE), 消息由 PUBLISH 或者键空间通知发布
+ * 订阅了频道或模式的客户端进入订阅状态, 只能执行订阅相关的命令
+ * 键空间通知由持有共享锁或排它锁的命令发布, 因此 pubsubHub 使用自己的锁保护订阅关系,
+ * 并且消息只放入客户端的发送队列, 由每个客户端的写协程写入连接, 缓慢的订阅者不会阻塞命令
+ */
+
+// pubsubOutputLimit 是订阅客户端发送队列的上限, 超过时断开客户端, 与 redis 的 client-output-buffer-limit pubsub 硬限制相同
+const pubsubOutputLimit = 32 << 20
+
+// pubsubHub 记录所有的订阅关系, 所有 DB 共享
+type pubsubHub struct {
+	mu       sync.RWMutex
+	channels map[string][]resp.Connection // 频道 -> 按照订阅顺序排列的客户端
+	patterns map[string][]resp.Connection // 模式 -> 按照订阅顺序排列的客户端
+	clients  map[resp.Connection]*subscriber
+	outboxes map[resp.Connection]*outbox // 执行过订阅命令的客户端的发送队列, 客户端关闭时删除
+}
+
+// subscriber 记录一个客户端订阅的频道和模式
+type subscriber struct {
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func makePubsubHub() *pubsubHub {
+	return &pubsubHub{
+		channels: make(map[string][]resp.Connection),
+		patterns: make(map[string][]resp.Connection),
+		clients:  make(map[resp.Connection]*subscriber),
+		outboxes: make(map[resp.Connection]*outbox),
+	}
+}
+
+// disconnector 是可以立即断开的连接
+type disconnector interface {
+	Disconnect()
+}
+
+// outbox 是订阅客户端的发送队列, 写协程按照放入的顺序将消息写入连接
+// 命令的回复由处理程序直接写入连接, 所以命令返回之前需要等待发送队列清空, 见 flush
+type outbox struct {
+	conn    resp.Connection
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   [][]byte
+	size    int  // queue 中的字节数
+	writing bool // 写协程正在写入取出的消息
+	closed  bool // 客户端已关闭或者发送队列超过上限, 不再接收消息
+}
+
+func makeOutbox(conn resp.Connection) *outbox {
+	box := &outbox{conn: conn}
+	box.cond = sync.NewCond(&box.mu)
+	go box.loop()
+	return box
+}
+
+// push 将消息放入发送队列, 不会阻塞在客户端的连接上
+// 队列超过上限时丢弃所有未发送的消息并断开客户端
+func (box *outbox) push(payload []byte) {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	if box.closed {
+		return
+	}
+	if box.size+len(payload) > pubsubOutputLimit {
+		logger.Warn("pubsub client output buffer overcome limit, closing client")
+		box.queue, box.size, box.closed = nil, 0, true
+		box.cond.Broadcast()
+		// 关闭连接后客户端的读取会失败, 由 AfterClientClose 清理订阅
+		if d, ok := box.conn.(disconnector); ok {
+			d.Disconnect()
+		}
+		return
+	}
+	box.queue = append(box.queue, payload)
+	box.size += len(payload)
+	box.cond.Broadcast()
+}
+
+// close 在客户端关闭时丢弃未发送的消息并停止写协程
+func (box *outbox) close() {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	box.queue, box.size, box.closed = nil, 0, true
+	box.cond.Broadcast()
+}
+
+// isClosed 判断发送队列是否已经关闭
+func (box *outbox) isClosed() bool {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	return box.closed
+}
+
+// loop 是写协程, 每次取出队列中的所有消息写入连接, 发送队列关闭后退出
+func (box *outbox) loop() {
+	for {
+		box.mu.Lock()
+		for len(box.queue) == 0 && !box.closed {
+			box.cond.Wait()
+		}
+		if box.closed {
+			box.mu.Unlock()
+			return
+		}
+		batch := box.queue
+		box.queue, box.size = nil, 0
+		box.writing = true
+		box.mu.Unlock()
+
+		for _, payload := range batch {
+			// 客户端关闭后不再写入连接
+			if box.isClosed() || box.conn.Write(payload) != nil {
+				break
+			}
+		}
+
+		box.mu.Lock()
+		box.writing = false
+		box.cond.Broadcast()
+		box.mu.Unlock()
+	}
+}
+
+// flush 等待发送队列中的消息全部写入连接, 发送队列关闭时立即返回
+func (box *outbox) flush() {
+	box.mu.Lock()
+	defer box.mu.Unlock()
+	for (len(box.queue) > 0 || box.writing) && !box.closed {
+		box.cond.Wait()
+	}
+}
+
+// outbox 返回客户端的发送队列, 不存在时创建, 调用者必须持有 hub.mu 的排它锁
+func (hub *pubsubHub) outbox(c resp.Connection) *outbox {
+	box := hub.outboxes[c]
+	if box == nil {
+		box = makeOutbox(c)
+		hub.outboxes[c] = box
+	}
+	return box
+}
+
+// count 返回客户端订阅的频道和模式的总数
+func (sub *subscriber) count() int {
+	return len(sub.channels) + len(sub.patterns)
+}
+
+// tables 返回客户端订阅的频道或模式以及 hub 中对应的表
+func (hub *pubsubHub) tables(sub *subscriber, pattern bool) (map[string]struct{}, map[string][]resp.Connection) {
+	if pattern {
+		return sub.patterns, hub.patterns
+	}
+	return sub.channels, hub.channels
+}
+
+// subscribe 订阅频道或模式, 并将确认消息放入客户端的发送队列
+// 确认消息在持有锁时放入队列, 因此总是先于这个频道的消息发送给客户端
+func (hub *pubsubHub) subscribe(c resp.Connection, name string, pattern bool, kind string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	sub := hub.clients[c]
+	if sub == nil {
+		sub = &subscriber{
+			channels: make(map[string]struct{}),
+			patterns: make(map[string]struct{}),
+		}
+		hub.clients[c] = sub
+	}
+	subs, table := hub.tables(sub, pattern)
+	if _, ok := subs[name]; !ok {
+		subs[name] = struct{}{}
+		table[name] = append(table[name], c)
+	}
+	hub.outbox(c).push(makeSubscribeReply(kind, []byte(name), sub.count()).ToBytes())
+}
+
+// unsubscribe 取消订阅频道或模式, 并将确认消息放入客户端的发送队列
+func (hub *pubsubHub) unsubscribe(c resp.Connection, name string, pattern bool, kind string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	count := hub.remove(c, name, pattern)
+	hub.outbox(c).push(makeSubscribeReply(kind, []byte(name), count).ToBytes())
+}
+
+// remove 取消订阅频道或模式, 返回客户端剩余的订阅数, 调用者必须持有 hub.mu 的排它锁
+func (hub *pubsubHub) remove(c resp.Connection, name string, pattern bool) int {
+	sub := hub.clients[c]
+	if sub == nil {
+		return 0
+	}
+	subs, table := hub.tables(sub, pattern)
+	if _, ok := subs[name]; ok {
+		delete(subs, name)
+		conns := table[name]
+		for i, conn := range conns {
+			if conn == c {
+				conns = append(conns[:i:i], conns[i+1:]...)
+				break
+			}
+		}
+		if len(conns) == 0 {
+			delete(table, name)
+		} else {
+			table[name] = conns
+		}
+	}
+	if sub.count() == 0 {
+		delete(hub.clients, c)
+	}
+	return sub.count()
+}
+
+// send 将回复放入客户端的发送队列, 用于没有订阅时的 UNSUBSCRIBE, 保证回复不会先于之前的消息
+func (hub *pubsubHub) send(c resp.Connection, payload []byte) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.outbox(c).push(payload)
+}
+
+// flush 等待客户端的发送队列清空, 之后处理程序直接写入的回复不会先于之前放入队列的消息
+// 调用者不能持有数据库的锁, 否则缓慢的客户端会阻塞其它命令
+func (hub *pubsubHub) flush(c resp.Connection) {
+	hub.mu.RLock()
+	box := hub.outboxes[c]
+	hub.mu.RUnlock()
+	if box != nil {
+		box.flush()
+	}
+}
+
+// subscriptions 返回客户端订阅的所有频道或模式
+func (hub *pubsubHub) subscriptions(c resp.Connection, pattern bool) []string {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	sub := hub.clients[c]
+	if sub == nil {
+		return nil
+	}
+	subs, _ := hub.tables(sub, pattern)
+	names := make([]string, 0, len(subs))
+	for name := range subs {
+		names = append(names, name)
+	}
+	return names
+}
+
+// subscriptionCount 返回客户端订阅的总数, 大于 0 表示客户端处于订阅状态
+func (hub *pubsubHub) subscriptionCount(c resp.Connection) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	if sub := hub.clients[c]; sub != nil {
+		return sub.count()
+	}
+	return 0
+}
+
+// unsubscribeAll 在客户端关闭时取消它的所有订阅并关闭它的发送队列
+func (hub *pubsubHub) unsubscribeAll(c resp.Connection) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if sub := hub.clients[c]; sub != nil {
+		for channel := range sub.channels {
+			hub.remove(c, channel, false)
+		}
+		for pattern := range sub.patterns {
+			hub.remove(c, pattern, true)
+		}
+	}
+	if box := hub.outboxes[c]; box != nil {
+		delete(hub.outboxes, c)
+		box.close()
+	}
+}
+
+// publish 将消息放入订阅了频道以及订阅了匹配的模式的客户端的发送队列, 返回接收消息的客户端个数
+// 调用者可能持有数据库的锁, 所以只放入队列, 不写入连接
+func (hub *pubsubHub) publish(channel string, message []byte) int {
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	receivers := 0
+	if conns := hub.channels[channel]; len(conns) > 0 {
+		payload := reply.MakeMultiBulkReply([][]byte{[]byte("message"), []byte(channel), message}).ToBytes()
+		for _, c := range conns {
+			hub.outboxes[c].push(payload)
+		}
+		receivers += len(conns)
+	}
+	for pattern, conns := range hub.patterns {
+		if !glob.Match(pattern, channel) {
+			continue
+		}
+		payload := reply.MakeMultiBulkReply([][]byte{[]byte("pmessage"), []byte(pattern), []byte(channel), message}).ToBytes()
+		for _, c := range conns {
+			hub.outboxes[c].push(payload)
+		}
+		receivers += len(conns)
+	}
+	return receivers
+}
+
+// subscribeContextCommands 是客户端处于订阅状态时可以执行的命令
+var subscribeContextCommands = map[string]struct{}{
+	"subscribe":    {},
+	"unsubscribe":  {},
+	"psubscribe":   {},
+	"punsubscribe": {},
+	"ping":         {},
+	"quit":         {},
+}
+
+// makeSubscribeReply 返回 [kind, name, count], name 为 nil 时返回空字符串
+func makeSubscribeReply(kind string, name []byte, count int) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(kind)),
+		reply.MakeBulkReply(name),
+		reply.MakeIntReply(int64(count)),
+	})
+}
+
+// subscribe 是 SUBSCRIBE/PSUBSCRIBE 的公共实现, 每个频道或模式向客户端发送一条确认消息
+func (mdb *StandaloneDatabase) subscribe(c resp.Connection, args [][]byte, pattern bool, kind string) resp.Reply {
+	for _, arg := range args {
+		mdb.pubsub.subscribe(c, string(arg), pattern, kind)
+	}
+	return reply.MakeNoReply()
+}
+
+// unsubscribe 是 UNSUBSCRIBE/PUNSUBSCRIBE 的公共实现, 没有参数时取消所有的订阅
+func (mdb *StandaloneDatabase) unsubscribe(c resp.Connection, args [][]byte, pattern bool, kind string) resp.Reply {
+	if len(args) == 0 {
+		for _, name := range mdb.pubsub.subscriptions(c, pattern) {
+			args = append(args, []byte(name))
+		}
+		if len(args) == 0 {
+			// 没有订阅时仍然需要回复, 频道为 nil
+			mdb.pubsub.send(c, makeSubscribeReply(kind, nil, mdb.pubsub.subscriptionCount(c)).ToBytes())
+			return reply.MakeNoReply()
+		}
+	}
+	for _, arg := range args {
+		mdb.pubsub.unsubscribe(c, string(arg), pattern, kind)
+	}
+	return reply.MakeNoReply()
+}
+
+// execSubscribe 订阅频道
+// SUBSCRIBE channel [channel ...]
+func execSubscribe(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return mdb.subscribe(c, args, false, "subscribe")
+}
+
+// execUnsubscribe 取消订阅频道
+// UNSUBSCRIBE [channel [channel ...]]
+func execUnsubscribe(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return mdb.unsubscribe(c, args, false, "unsubscribe")
+}
+
+// execPSubscribe 订阅与模式匹配的所有频道
+// PSUBSCRIBE pattern [pattern ...]
+func execPSubscribe(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return mdb.subscribe(c, args, true, "psubscribe")
+}
+
+// execPUnsubscribe 取消订阅模式
+// PUNSUBSCRIBE [pattern [pattern ...]]
+func execPUnsubscribe(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return mdb.unsubscribe(c, args, true, "punsubscribe")
+}
+
+// execPublish 向频道发布消息, 返回接收消息的客户端个数
+// PUBLISH channel message
+func execPublish(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return reply.MakeIntReply(int64(mdb.pubsub.publish(string(args[0]), args[1])))
+}
+
+var pubsubHelp = []string{
+	"PUBSUB <subcommand> [<arg> [value] [opt] ...]. Subcommands are:",
+	"CHANNELS [<pattern>]",
+	"    Return the currently active channels matching a <pattern> (default: '*').",
+	"NUMPAT",
+	"    Return number of subscriptions to patterns.",
+	"NUMSUB [<channel> ...]",
+	"    Return the number of subscribers for the specified channels, excluding",
+	"    pattern subscriptions(default: no channels).",
+	"HELP",
+	"    Print this help.",
+}
+
+// execPubSub 查看订阅状态
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT | HELP
+func execPubSub(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	hub := mdb.pubsub
+	subCmd := strings.ToLower(string(args[0]))
+	switch {
+	case subCmd == "help" && len(args) == 1:
+		lines := make([]resp.Reply, len(pubsubHelp))
+		for i, line := range pubsubHelp {
+			lines[i] = reply.MakeStatusReply(line)
+		}
+		return reply.MakeMultiRawReply(lines)
+	case subCmd == "channels" && len(args) <= 2:
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		channels := make([][]byte, 0)
+		for channel := range hub.channels {
+			if len(args) == 1 || glob.Match(string(args[1]), channel) {
+				channels = append(channels, []byte(channel))
+			}
+		}
+		return reply.MakeMultiBulkReply(channels)
+	case subCmd == "numsub":
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		result := make([]resp.Reply, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			result = append(result,
+				reply.MakeBulkReply(channel),
+				reply.MakeIntReply(int64(len(hub.channels[string(channel)]))))
+		}
+		return reply.MakeMultiRawReply(result)
+	case subCmd == "numpat" && len(args) == 1:
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return reply.MakeIntReply(int64(len(hub.patterns)))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+func init() {
+	registerSysCommand("Subscribe", execSubscribe, -2, FlagPubSub|FlagNoScript, 0, 0, 0)
+	registerSysCommand("Unsubscribe", execUnsubscribe, -1, FlagPubSub|FlagNoScript, 0, 0, 0)
+	registerSysCommand("PSubscribe", execPSubscribe, -2, FlagPubSub|FlagNoScript, 0, 0, 0)
+	registerSysCommand("PUnsubscribe", execPUnsubscribe, -1, FlagPubSub|FlagNoScript, 0, 0, 0)
+	registerSysCommand("Publish", execPublish, 3, FlagPubSub|FlagFast, 0, 0, 0)
+	registerSysCommand("PubSub", execPubSub, -2, FlagPubSub, 0, 0, 0)
+}
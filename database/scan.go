@@ -0,0 +1,96 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/lib/glob"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// scanOptions 是 SCAN 系列命令的公共选项
+type scanOptions struct {
+	pattern  string // MATCH, 为空时不过滤
+	count    int    // COUNT, 每次迭代大约返回的元素个数
+	noValues bool   // NOVALUES, 仅 HSCAN 支持
+	typeName string // TYPE, 仅 SCAN 支持, 为空时不过滤
+}
+
+// scanTypeNames 是 TYPE 选项可以使用的类型名称
+var scanTypeNames = map[string]bool{
+	"string": true, "list": true, "set": true, "zset": true, "hash": true, "stream": true,
+}
+
+// parseScanCursor 解析游标, 游标是无符号整数
+func parseScanCursor(arg []byte) (uint64, resp.ErrorReply) {
+	cursor, err := strconv.ParseUint(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR invalid cursor")
+	}
+	return cursor, nil
+}
+
+// parseScanOptions 解析 [MATCH pattern] [COUNT count] [NOVALUES] [TYPE type]
+func parseScanOptions(args [][]byte, allowNoValues bool, allowType bool) (*scanOptions, resp.ErrorReply) {
+	opts := &scanOptions{count: 10}
+	for i := 0; i < len(args); i++ {
+		switch option := toUpper(args[i]); {
+		case option == "MATCH" && i+1 < len(args):
+			opts.pattern = string(args[i+1])
+			if opts.pattern == "*" {
+				opts.pattern = ""
+			}
+			i++
+		case option == "COUNT" && i+1 < len(args):
+			count, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if count < 1 {
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			opts.count = int(count)
+			i++
+		case option == "NOVALUES" && allowNoValues:
+			opts.noValues = true
+		case option == "TYPE" && allowType && i+1 < len(args):
+			opts.typeName = strings.ToLower(string(args[i+1]))
+			if !scanTypeNames[opts.typeName] {
+				return nil, reply.MakeErrReply("ERR unknown type name '" + string(args[i+1]) + "'")
+			}
+			i++
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+	return opts, nil
+}
+
+// match 判断元素是否满足 MATCH 选项
+func (opts *scanOptions) match(s string) bool {
+	if opts.pattern == "" {
+		return true
+	}
+	return glob.Match(opts.pattern, s)
+}
+
+// scanLoop 从 cursor 开始反复调用 scan 遍历哈希表的桶, 返回下一次遍历的游标
+// 与 redis 相同, 遍历过至少 count 个元素、遍历结束或者遍历了 count*10 个桶时停止, 避免哈希表稀疏时遍历过多的空桶
+// sampled 返回已经遍历过的元素个数, 包括不满足 MATCH 等条件的元素
+func scanLoop(cursor uint64, count int, scan func(cursor uint64) uint64, sampled func() int) uint64 {
+	for maxIterations := count * 10; ; maxIterations-- {
+		cursor = scan(cursor)
+		if cursor == 0 || maxIterations <= 1 || sampled() >= count {
+			return cursor
+		}
+	}
+}
+
+// makeScanReply 创建 SCAN 系列命令的回复: 下一次迭代的游标和本次返回的元素
+func makeScanReply(cursor uint64, elements [][]byte) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(strconv.FormatUint(cursor, 10))),
+		reply.MakeMultiBulkReply(elements),
+	})
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// execDBSize 返回当前数据库中 key 的数量
+// DBSIZE
+func execDBSize(db *DB, args [][]byte) resp.Reply {
+	return reply.MakeIntReply(int64(db.data.Len()))
+}
+
+// execFlushDB 清空当前数据库
+// FLUSHDB [ASYNC|SYNC]
+func execFlushDB(db *DB, args [][]byte) resp.Reply {
+	if !validFlushArgs(args) {
+		return reply.MakeSyntaxErrReply()
+	}
+	db.Flush()
+	return reply.MakeOkReply()
+}
+
+// execCommand 返回命令表中的元数据
+// COMMAND [COUNT | INFO name [name ...] | LIST | GETKEYS cmd [arg ...]]
+func execCommand(db *DB, args [][]byte) resp.Reply {
+	if len(args) == 0 {
+		names := make([]string, 0, len(cmdTable))
+		for name := range cmdTable {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		replies := make([]resp.Reply, len(names))
+		for i, name := range names {
+			replies[i] = makeCommandInfoReply(cmdTable[name])
+		}
+		return reply.MakeMultiRawReply(replies)
+	}
+
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "count":
+		if len(args) != 1 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'count'")
+		}
+		return reply.MakeIntReply(int64(len(cmdTable)))
+	case "list":
+		if len(args) != 1 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'list'")
+		}
+		names := make([][]byte, 0, len(cmdTable))
+		for name := range cmdTable {
+			names = append(names, []byte(name))
+		}
+		return reply.MakeMultiBulkReply(names)
+	case "info":
+		replies := make([]resp.Reply, len(args)-1)
+		for i, name := range args[1:] {
+			cmd, ok := LookupCommand(string(name))
+			if !ok {
+				replies[i] = &reply.NullMultiBulkReply{}
+				continue
+			}
+			replies[i] = makeCommandInfoReply(cmd)
+		}
+		return reply.MakeMultiRawReply(replies)
+	case "getkeys":
+		if len(args) < 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'getkeys'")
+		}
+		cmdLine := args[1:]
+		cmd, ok := LookupCommand(string(cmdLine[0]))
+		if !ok {
+			return reply.MakeErrReply("ERR Invalid command specified")
+		}
+		if !cmd.ValidateArity(cmdLine) {
+			return reply.MakeErrReply("ERR Invalid number of arguments specified for command")
+		}
+		keys := cmd.GetKeys(cmdLine)
+		if len(keys) == 0 {
+			return reply.MakeErrReply("ERR The command has no key arguments")
+		}
+		result := make([][]byte, len(keys))
+		for i, key := range keys {
+			result[i] = []byte(key)
+		}
+		return reply.MakeMultiBulkReply(result)
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try COMMAND HELP.")
+}
+
+// makeCommandInfoReply 返回 [name, arity, [flags], firstKey, lastKey, keyStep]
+func makeCommandInfoReply(cmd *Command) resp.Reply {
+	flagNames := cmd.FlagNames()
+	flags := make([]resp.Reply, len(flagNames))
+	for i, name := range flagNames {
+		flags[i] = reply.MakeStatusReply(name)
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(cmd.name)),
+		reply.MakeIntReply(int64(cmd.arity)),
+		reply.MakeMultiRawReply(flags),
+		reply.MakeIntReply(int64(cmd.firstKey)),
+		reply.MakeIntReply(int64(cmd.lastKey)),
+		reply.MakeIntReply(int64(cmd.keyStep)),
+	})
+}
+
+func init() {
+	registerCommand("DBSize", execDBSize, 1, FlagReadOnly|FlagFast, 0, 0, 0)
+	registerCommand("FlushDB", execFlushDB, -1, FlagWrite, 0, 0, 0)
+	registerCommand("Command", execCommand, -1, FlagReadOnly, 0, 0, 0)
+}
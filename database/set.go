@@ -0,0 +1,542 @@
+package database
+
+import (
+	"math"
+	"sort"
+
+	"github.com/LynchQ/my-go-redis/config"
+	"github.com/LynchQ/my-go-redis/datastruct/set"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsSet 返回 key 对应的集合, key 不存在时返回 nil
+func (db *DB) getAsSet(key string) (*set.Set, resp.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*set.Set)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	return s, nil
+}
+
+// getOrInitSet 返回 key 对应的集合, key 不存在时创建一个空集合
+func (db *DB) getOrInitSet(key string) (s *set.Set, isNew bool, errReply resp.ErrorReply) {
+	s, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if s == nil {
+		s = set.Make()
+		db.PutEntity(key, &database.DataEntity{Data: s})
+		isNew = true
+	}
+	return s, isNew, nil
+}
+
+// removeIfEmptySet 集合为空时删除 key
+func (db *DB) removeIfEmptySet(key string, s *set.Set) {
+	if s.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// getSets 返回多个 key 对应的集合, 不存在的 key 对应 nil
+func (db *DB) getSets(keys []string) ([]*set.Set, resp.ErrorReply) {
+	sets := make([]*set.Set, len(keys))
+	for i, key := range keys {
+		s, errReply := db.getAsSet(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets[i] = s
+	}
+	return sets, nil
+}
+
+// setAdd 添加元素, 元素个数超过 set-max-intset-entries 时转换为 hashtable 编码
+func setAdd(s *set.Set, member string) bool {
+	added := s.Add(member)
+	if s.IsIntset() && s.Len() > config.Properties.SetMaxIntsetEntries {
+		s.Convert()
+	}
+	return added
+}
+
+// membersReply 将元素列表转换为回复
+func membersReply(members []string) resp.Reply {
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		result[i] = []byte(member)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execSAdd 添加一个或多个元素, 返回新添加的元素个数
+// SADD key member [member ...]
+func execSAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	s, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	for _, member := range args[1:] {
+		if setAdd(s, string(member)) {
+			added++
+		}
+	}
+	if added > 0 {
+		db.notify(notifySet, "sadd", key)
+	}
+	return reply.MakeIntReply(int64(added))
+}
+
+// execSRem 删除一个或多个元素, 返回删除的元素个数
+// SREM key member [member ...]
+func execSRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	removed := 0
+	for _, member := range args[1:] {
+		if s.Remove(string(member)) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		db.notify(notifySet, "srem", key)
+	}
+	db.removeIfEmptySet(key, s)
+	return reply.MakeIntReply(int64(removed))
+}
+
+// execSIsMember 判断元素是否在集合中
+// SISMEMBER key member
+func execSIsMember(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s != nil && s.Has(string(args[1])) {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execSMIsMember 判断多个元素是否在集合中
+// SMISMEMBER key member [member ...]
+func execSMIsMember(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]resp.Reply, len(args)-1)
+	for i, member := range args[1:] {
+		if s != nil && s.Has(string(member)) {
+			result[i] = reply.MakeIntReply(1)
+		} else {
+			result[i] = reply.MakeIntReply(0)
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execSMembers 返回所有的元素
+// SMEMBERS key
+func execSMembers(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	return membersReply(s.Members())
+}
+
+// execSCard 返回元素个数
+// SCARD key
+func execSCard(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(s.Len()))
+}
+
+// execSPop 随机删除并返回元素
+// SPOP key [count]
+func execSPop(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := int64(-1)
+	if len(args) == 2 {
+		var errReply resp.ErrorReply
+		count, errReply = parseInt64(args[1])
+		if errReply != nil {
+			return errReply
+		}
+		if count < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+	}
+	s, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if count < 0 {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	if count < 0 {
+		member := s.RandomMember()
+		s.Remove(member)
+		db.notify(notifySet, "spop", key)
+		db.removeIfEmptySet(key, s)
+		return reply.MakeBulkReply([]byte(member))
+	}
+	if count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	var members []string
+	if count >= int64(s.Len()) {
+		members = s.Members()
+		db.Remove(key)
+		db.notify(notifySet, "spop", key)
+		db.notify(notifyGeneric, "del", key)
+	} else {
+		members = s.RandomDistinctMembers(int(count))
+		for _, member := range members {
+			s.Remove(member)
+		}
+		db.notify(notifySet, "spop", key)
+	}
+	return membersReply(members)
+}
+
+// execSRandMember 随机返回元素
+// count 为正数时返回不重复的元素, 为负数时可能返回重复的元素
+// SRANDMEMBER key [count]
+func execSRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if s == nil {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeBulkReply([]byte(s.RandomMember()))
+	}
+	count, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if count < -math.MaxInt64 {
+		return reply.MakeErrReply("ERR value is out of range")
+	}
+	if s == nil || count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	if count > 0 {
+		return membersReply(s.RandomDistinctMembers(int(count)))
+	}
+	return membersReply(s.RandomMembers(int(-count)))
+}
+
+// execSMove 将元素从 source 移动到 destination
+// SMOVE source destination member
+func execSMove(db *DB, args [][]byte) resp.Reply {
+	srcKey, destKey, member := string(args[0]), string(args[1]), string(args[2])
+	src, errReply := db.getAsSet(srcKey)
+	if errReply != nil {
+		return errReply
+	}
+	dest, errReply := db.getAsSet(destKey)
+	if errReply != nil {
+		return errReply
+	}
+	if src == nil || !src.Has(member) {
+		return reply.MakeIntReply(0)
+	}
+	if srcKey == destKey {
+		return reply.MakeIntReply(1)
+	}
+	src.Remove(member)
+	db.notify(notifySet, "srem", srcKey)
+	db.removeIfEmptySet(srcKey, src)
+	if dest == nil {
+		dest, _, _ = db.getOrInitSet(destKey)
+	}
+	if setAdd(dest, member) {
+		db.notify(notifySet, "sadd", destKey)
+	}
+	return reply.MakeIntReply(1)
+}
+
+/* ---- 集合运算 ---- */
+
+// 集合运算的类型
+const (
+	setInter = iota
+	setUnion
+	setDiff
+)
+
+// setIntersect 返回多个集合的交集中的元素, limit 大于 0 时最多返回 limit 个
+func setIntersect(sets []*set.Set, limit int) []string {
+	for _, s := range sets {
+		if s == nil {
+			return nil
+		}
+	}
+	// 从元素最少的集合开始检查, 可以尽早排除不在交集中的元素
+	sorted := make([]*set.Set, len(sets))
+	copy(sorted, sets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Len() < sorted[j].Len()
+	})
+	var result []string
+	sorted[0].ForEach(func(member string) bool {
+		for _, s := range sorted[1:] {
+			if !s.Has(member) {
+				return true
+			}
+		}
+		result = append(result, member)
+		return limit <= 0 || len(result) < limit
+	})
+	return result
+}
+
+// setOperation 计算多个集合的交集、并集或差集, 返回新的集合
+func setOperation(sets []*set.Set, op int) *set.Set {
+	result := set.Make()
+	switch op {
+	case setInter:
+		for _, member := range setIntersect(sets, 0) {
+			setAdd(result, member)
+		}
+	case setUnion:
+		for _, s := range sets {
+			if s == nil {
+				continue
+			}
+			s.ForEach(func(member string) bool {
+				setAdd(result, member)
+				return true
+			})
+		}
+	case setDiff:
+		if sets[0] == nil {
+			break
+		}
+		sets[0].ForEach(func(member string) bool {
+			for _, s := range sets[1:] {
+				if s != nil && s.Has(member) {
+					return true
+				}
+			}
+			setAdd(result, member)
+			return true
+		})
+	}
+	return result
+}
+
+// keysOf 将参数转换为 key 列表
+func keysOf(args [][]byte) []string {
+	keys := make([]string, len(args))
+	for i, arg := range args {
+		keys[i] = string(arg)
+	}
+	return keys
+}
+
+// setOperationCommand 是 SINTER/SUNION/SDIFF 的公共实现
+func setOperationCommand(db *DB, args [][]byte, op int) resp.Reply {
+	sets, errReply := db.getSets(keysOf(args))
+	if errReply != nil {
+		return errReply
+	}
+	return membersReply(setOperation(sets, op).Members())
+}
+
+// setStoreEvents 是 SINTERSTORE/SUNIONSTORE/SDIFFSTORE 发布的事件
+var setStoreEvents = [...]string{
+	setInter: "sinterstore",
+	setUnion: "sunionstore",
+	setDiff:  "sdiffstore",
+}
+
+// setOperationStore 是 SINTERSTORE/SUNIONSTORE/SDIFFSTORE 的公共实现
+// 结果为空时删除 destination, 否则覆盖 destination 并清除它的过期时间
+func setOperationStore(db *DB, args [][]byte, op int) resp.Reply {
+	dest := string(args[0])
+	sets, errReply := db.getSets(keysOf(args[1:]))
+	if errReply != nil {
+		return errReply
+	}
+	result := setOperation(sets, op)
+	if result.Len() == 0 {
+		db.Removes(dest)
+		return reply.MakeIntReply(0)
+	}
+	db.Remove(dest)
+	db.PutEntity(dest, &database.DataEntity{Data: result})
+	db.notify(notifySet, setStoreEvents[op], dest)
+	return reply.MakeIntReply(int64(result.Len()))
+}
+
+// execSInter 返回多个集合的交集
+// SINTER key [key ...]
+func execSInter(db *DB, args [][]byte) resp.Reply {
+	return setOperationCommand(db, args, setInter)
+}
+
+// execSUnion 返回多个集合的并集
+// SUNION key [key ...]
+func execSUnion(db *DB, args [][]byte) resp.Reply {
+	return setOperationCommand(db, args, setUnion)
+}
+
+// execSDiff 返回第一个集合与其它集合的差集
+// SDIFF key [key ...]
+func execSDiff(db *DB, args [][]byte) resp.Reply {
+	return setOperationCommand(db, args, setDiff)
+}
+
+// execSInterStore 将多个集合的交集存储到 destination, 返回结果的元素个数
+// SINTERSTORE destination key [key ...]
+func execSInterStore(db *DB, args [][]byte) resp.Reply {
+	return setOperationStore(db, args, setInter)
+}
+
+// execSUnionStore 将多个集合的并集存储到 destination, 返回结果的元素个数
+// SUNIONSTORE destination key [key ...]
+func execSUnionStore(db *DB, args [][]byte) resp.Reply {
+	return setOperationStore(db, args, setUnion)
+}
+
+// execSDiffStore 将差集存储到 destination, 返回结果的元素个数
+// SDIFFSTORE destination key [key ...]
+func execSDiffStore(db *DB, args [][]byte) resp.Reply {
+	return setOperationStore(db, args, setDiff)
+}
+
+// parseCardArgs 解析 SINTERCARD/ZINTERCARD 的参数 numkeys key [key ...] [LIMIT limit]
+func parseCardArgs(args [][]byte) (keys []string, limit int, errReply resp.ErrorReply) {
+	numKeys, errReply := parseInt64(args[0])
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, 0, reply.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys, rest, errReply := parseNumKeys(args)
+	if errReply != nil {
+		return nil, 0, errReply
+	}
+	for len(rest) > 0 {
+		if toUpper(rest[0]) != "LIMIT" || len(rest) < 2 {
+			return nil, 0, reply.MakeSyntaxErrReply()
+		}
+		val, errReply := parseInt64(rest[1])
+		if errReply != nil {
+			return nil, 0, errReply
+		}
+		if val < 0 {
+			return nil, 0, reply.MakeErrReply("ERR LIMIT can't be negative")
+		}
+		limit = int(val)
+		rest = rest[2:]
+	}
+	return keys, limit, nil
+}
+
+// execSInterCard 返回多个集合的交集的元素个数, limit 大于 0 时最多计数到 limit
+// SINTERCARD numkeys key [key ...] [LIMIT limit]
+func execSInterCard(db *DB, args [][]byte) resp.Reply {
+	keys, limit, errReply := parseCardArgs(args)
+	if errReply != nil {
+		return errReply
+	}
+	sets, errReply := db.getSets(keys)
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(len(setIntersect(sets, limit))))
+}
+
+// execSScan 使用游标迭代集合中的元素, intset 编码时一次返回所有元素
+// SSCAN key cursor [MATCH pattern] [COUNT count]
+func execSScan(db *DB, args [][]byte) resp.Reply {
+	cursor, errReply := parseScanCursor(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	opts, errReply := parseScanOptions(args[2:], false, false)
+	if errReply != nil {
+		return errReply
+	}
+	s, errReply := db.getAsSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return makeScanReply(0, [][]byte{})
+	}
+	elements := make([][]byte, 0)
+	sampled := 0
+	cursor = scanLoop(cursor, opts.count, func(cursor uint64) uint64 {
+		return s.Scan(cursor, func(member string) {
+			sampled++
+			if opts.match(member) {
+				elements = append(elements, []byte(member))
+			}
+		})
+	}, func() int { return sampled })
+	return makeScanReply(cursor, elements)
+}
+
+func init() {
+	registerCommand("SAdd", execSAdd, -3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("SRem", execSRem, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("SIsMember", execSIsMember, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("SMIsMember", execSMIsMember, -3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("SMembers", execSMembers, 2, FlagReadOnly, 1, 1, 1)
+	registerCommand("SCard", execSCard, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("SPop", execSPop, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("SRandMember", execSRandMember, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("SMove", execSMove, 4, FlagWrite|FlagFast, 1, 2, 1)
+	registerCommand("SInter", execSInter, -2, FlagReadOnly, 1, -1, 1)
+	registerCommand("SUnion", execSUnion, -2, FlagReadOnly, 1, -1, 1)
+	registerCommand("SDiff", execSDiff, -2, FlagReadOnly, 1, -1, 1)
+	registerCommand("SInterStore", execSInterStore, -3, FlagWrite|FlagDenyOOM, 1, -1, 1)
+	registerCommand("SUnionStore", execSUnionStore, -3, FlagWrite|FlagDenyOOM, 1, -1, 1)
+	registerCommand("SDiffStore", execSDiffStore, -3, FlagWrite|FlagDenyOOM, 1, -1, 1)
+	registerCommand("SInterCard", execSInterCard, -3, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerCommand("SScan", execSScan, -3, FlagReadOnly, 1, 1, 1)
+}
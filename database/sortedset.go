@@ -0,0 +1,973 @@
+package database
+
+import (
+	"math"
+
+	"github.com/LynchQ/my-go-redis/datastruct/sortedset"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsSortedSet 返回 key 对应的有序集合, key 不存在时返回 nil
+func (db *DB) getAsSortedSet(key string) (*sortedset.SortedSet, resp.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	zset, ok := entity.Data.(*sortedset.SortedSet)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	return zset, nil
+}
+
+// getOrInitSortedSet 返回 key 对应的有序集合, key 不存在时创建一个空的有序集合
+func (db *DB) getOrInitSortedSet(key string) (zset *sortedset.SortedSet, isNew bool, errReply resp.ErrorReply) {
+	zset, errReply = db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	if zset == nil {
+		zset = sortedset.Make()
+		db.PutEntity(key, &database.DataEntity{Data: zset})
+		isNew = true
+	}
+	return zset, isNew, nil
+}
+
+// removeIfEmptySortedSet 有序集合为空时删除 key
+func (db *DB) removeIfEmptySortedSet(key string, zset *sortedset.SortedSet) {
+	if zset.Len() == 0 {
+		db.Remove(key)
+		db.notify(notifyGeneric, "del", key)
+	}
+}
+
+// storeSortedSet 将结果存储到 dest, 结果为空时删除 dest, 否则覆盖 dest 并清除它的过期时间
+// event 为存储成功时发布的事件
+func (db *DB) storeSortedSet(dest string, zset *sortedset.SortedSet, event string) {
+	if zset.Len() == 0 {
+		db.Removes(dest)
+		return
+	}
+	db.Remove(dest)
+	db.PutEntity(dest, &database.DataEntity{Data: zset})
+	db.notify(notifyZSet, event, dest)
+}
+
+// elementsReply 将元素列表转换为回复, withScores 为 true 时每个元素后面跟着它的分值
+func elementsReply(elements []*sortedset.Element, withScores bool) resp.Reply {
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	for _, element := range elements {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, []byte(formatScore(element.Score)))
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// ZADD 的选项
+type zaddOptions struct {
+	policy int  // upsertPolicy, insertPolicy(NX) 或 updatePolicy(XX)
+	gt, lt bool // 仅当新分值更大(更小)时更新已有元素
+	ch     bool // 返回新增和被修改分值的元素个数
+	incr   bool // 与 ZINCRBY 相同
+}
+
+// parseZAddOptions 解析 ZADD 的选项, 返回选项之后的参数
+func parseZAddOptions(args [][]byte) (*zaddOptions, [][]byte, resp.ErrorReply) {
+	opts := &zaddOptions{}
+	i := 0
+loop:
+	for ; i < len(args); i++ {
+		switch toUpper(args[i]) {
+		case "NX":
+			if opts.policy == updatePolicy {
+				return nil, nil, reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+			}
+			opts.policy = insertPolicy
+		case "XX":
+			if opts.policy == insertPolicy {
+				return nil, nil, reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+			}
+			opts.policy = updatePolicy
+		case "GT":
+			opts.gt = true
+		case "LT":
+			opts.lt = true
+		case "CH":
+			opts.ch = true
+		case "INCR":
+			opts.incr = true
+		default:
+			break loop
+		}
+	}
+	if (opts.gt && opts.lt) || ((opts.gt || opts.lt) && opts.policy == insertPolicy) {
+		return nil, nil, reply.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	return opts, args[i:], nil
+}
+
+// zsetAdd 按照 ZADD 的选项添加元素或者增加元素的分值
+// 返回元素最终的分值以及元素是否新增、分值是否被修改, 不满足选项的条件时 ok 为 false
+func zsetAdd(zset *sortedset.SortedSet, member string, score float64, opts *zaddOptions) (result float64, added bool, updated bool, ok bool, errReply resp.ErrorReply) {
+	element, exists := zset.Get(member)
+	if (exists && opts.policy == insertPolicy) || (!exists && opts.policy == updatePolicy) {
+		return 0, false, false, false, nil
+	}
+	if !exists {
+		zset.Add(member, score)
+		return score, true, false, true, nil
+	}
+	current := element.Score
+	if opts.incr {
+		score += current
+		if math.IsNaN(score) {
+			return 0, false, false, false, reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+		}
+	}
+	if (opts.gt && score <= current) || (opts.lt && score >= current) {
+		return 0, false, false, false, nil
+	}
+	if score != current {
+		zset.Add(member, score)
+		updated = true
+	}
+	return score, false, updated, true, nil
+}
+
+// execZAdd 添加元素或者更新元素的分值
+// 返回新增的元素个数, 指定 CH 时返回新增和被修改分值的元素个数, 指定 INCR 时返回元素的新分值
+// ZADD key [NX | XX] [GT | LT] [CH] [INCR] score member [score member ...]
+func execZAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	opts, pairs, errReply := parseZAddOptions(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return reply.MakeSyntaxErrReply()
+	}
+	if opts.incr && len(pairs) > 2 {
+		return reply.MakeErrReply("ERR INCR option supports a single increment-element pair")
+	}
+	// 先检查所有的分值, 出错时不修改有序集合
+	scores := make([]float64, len(pairs)/2)
+	for i := range scores {
+		scores[i], errReply = parseFloat64(pairs[i*2])
+		if errReply != nil {
+			return errReply
+		}
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		if opts.policy == updatePolicy {
+			if opts.incr {
+				return reply.MakeNullBulkReply()
+			}
+			return reply.MakeIntReply(0)
+		}
+		zset, _, _ = db.getOrInitSortedSet(key)
+	}
+
+	var addedCount, updatedCount int64
+	for i, score := range scores {
+		member := string(pairs[i*2+1])
+		result, added, updated, ok, errReply := zsetAdd(zset, member, score, opts)
+		if errReply != nil {
+			db.removeIfEmptySortedSet(key, zset)
+			return errReply
+		}
+		if opts.incr {
+			if !ok {
+				return reply.MakeNullBulkReply()
+			}
+			db.notify(notifyZSet, "zincr", key)
+			return reply.MakeBulkReply([]byte(formatScore(result)))
+		}
+		if added {
+			addedCount++
+		}
+		if updated {
+			updatedCount++
+		}
+	}
+	if addedCount+updatedCount > 0 {
+		db.notify(notifyZSet, "zadd", key)
+	}
+	if opts.ch {
+		return reply.MakeIntReply(addedCount + updatedCount)
+	}
+	return reply.MakeIntReply(addedCount)
+}
+
+// execZIncrBy 增加元素的分值, 元素不存在时以 0 为初始值, 返回新的分值
+// ZINCRBY key increment member
+func execZIncrBy(db *DB, args [][]byte) resp.Reply {
+	return execZAdd(db, [][]byte{args[0], []byte("INCR"), args[1], args[2]})
+}
+
+// execZRem 删除一个或多个元素, 返回删除的元素个数
+// ZREM key member [member ...]
+func execZRem(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	var removed int64
+	for _, member := range args[1:] {
+		if zset.Remove(string(member)) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		db.notify(notifyZSet, "zrem", key)
+	}
+	db.removeIfEmptySortedSet(key, zset)
+	return reply.MakeIntReply(removed)
+}
+
+// execZScore 返回元素的分值
+// ZSCORE key member
+func execZScore(db *DB, args [][]byte) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeNullBulkReply()
+	}
+	element, exists := zset.Get(string(args[1]))
+	if !exists {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply([]byte(formatScore(element.Score)))
+}
+
+// execZMScore 返回多个元素的分值, 不存在的元素返回 nil
+// ZMSCORE key member [member ...]
+func execZMScore(db *DB, args [][]byte) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	result := make([][]byte, len(args)-1)
+	if zset == nil {
+		return reply.MakeMultiBulkReply(result)
+	}
+	for i, member := range args[1:] {
+		if element, exists := zset.Get(string(member)); exists {
+			result[i] = []byte(formatScore(element.Score))
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execZCard 返回元素个数
+// ZCARD key
+func execZCard(db *DB, args [][]byte) resp.Reply {
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(zset.Len())
+}
+
+// parseRangeBorders 解析分值或字典序范围的边界
+func parseRangeBorders(minArg []byte, maxArg []byte, byLex bool) (min sortedset.Border, max sortedset.Border, errReply resp.ErrorReply) {
+	parse := sortedset.ParseScoreBorder
+	if byLex {
+		parse = sortedset.ParseLexBorder
+	}
+	min, err := parse(string(minArg))
+	if err != nil {
+		return nil, nil, reply.MakeErrReply(err.Error())
+	}
+	max, err = parse(string(maxArg))
+	if err != nil {
+		return nil, nil, reply.MakeErrReply(err.Error())
+	}
+	return min, max, nil
+}
+
+// zsetCount 是 ZCOUNT/ZLEXCOUNT 的公共实现
+func zsetCount(db *DB, args [][]byte, byLex bool) resp.Reply {
+	min, max, errReply := parseRangeBorders(args[1], args[2], byLex)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(zset.RangeCount(min, max))
+}
+
+// execZCount 返回分值在 [min, max] 中的元素个数
+// ZCOUNT key min max
+func execZCount(db *DB, args [][]byte) resp.Reply {
+	return zsetCount(db, args, false)
+}
+
+// execZLexCount 返回字典序在 [min, max] 中的元素个数, 所有元素的分值需要相同
+// ZLEXCOUNT key min max
+func execZLexCount(db *DB, args [][]byte) resp.Reply {
+	return zsetCount(db, args, true)
+}
+
+// zsetRank 是 ZRANK/ZREVRANK 的公共实现
+func zsetRank(db *DB, args [][]byte, desc bool) resp.Reply {
+	if len(args) > 3 || (len(args) == 3 && toUpper(args[2]) != "WITHSCORE") {
+		return reply.MakeSyntaxErrReply()
+	}
+	withScore := len(args) == 3
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	var rank int64
+	exists := false
+	if zset != nil {
+		rank, exists = zset.GetRank(string(args[1]), desc)
+	}
+	if !exists {
+		if withScore {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeNullBulkReply()
+	}
+	if !withScore {
+		return reply.MakeIntReply(rank)
+	}
+	element, _ := zset.Get(string(args[1]))
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeIntReply(rank),
+		reply.MakeBulkReply([]byte(formatScore(element.Score))),
+	})
+}
+
+// execZRank 返回元素按照分值从小到大的排名, 从 0 开始
+// ZRANK key member [WITHSCORE]
+func execZRank(db *DB, args [][]byte) resp.Reply {
+	return zsetRank(db, args, false)
+}
+
+// execZRevRank 返回元素按照分值从大到小的排名, 从 0 开始
+// ZREVRANK key member [WITHSCORE]
+func execZRevRank(db *DB, args [][]byte) resp.Reply {
+	return zsetRank(db, args, true)
+}
+
+/* ---- ZRANGE ---- */
+
+// ZRANGE 的范围类型
+const (
+	zrangeAuto  = iota // 由 BYSCORE/BYLEX 选项决定
+	zrangeRank         // 按照排名
+	zrangeScore        // 按照分值
+	zrangeLex          // 按照字典序
+)
+
+// ZRANGE 的方向
+const (
+	zrangeDirectionAuto = iota // 由 REV 选项决定
+	zrangeForward
+	zrangeReverse
+)
+
+// zrangeSpec 描述 ZRANGE 系列命令的查询
+type zrangeSpec struct {
+	rangeType  int
+	direction  int
+	minArg     []byte // 按照排名查询时为 start
+	maxArg     []byte // 按照排名查询时为 stop
+	offset     int64
+	limit      int64 // 小于 0 表示不限制
+	withScores bool
+}
+
+// parseZRangeSpec 解析 ZRANGE 系列命令在 key 之后的参数
+// rangeType 和 direction 为 auto 时才接受 BYSCORE/BYLEX 和 REV 选项, store 为 true 时不接受 WITHSCORES
+func parseZRangeSpec(args [][]byte, rangeType int, direction int, store bool) (*zrangeSpec, resp.ErrorReply) {
+	spec := &zrangeSpec{
+		rangeType: rangeType,
+		direction: direction,
+		minArg:    args[0],
+		maxArg:    args[1],
+		limit:     -1,
+	}
+	hasLimit := false
+	for i := 2; i < len(args); i++ {
+		option := toUpper(args[i])
+		switch {
+		case !store && option == "WITHSCORES":
+			spec.withScores = true
+		case option == "LIMIT" && i+2 < len(args):
+			offset, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			limit, errReply := parseInt64(args[i+2])
+			if errReply != nil {
+				return nil, errReply
+			}
+			spec.offset, spec.limit = offset, limit
+			hasLimit = true
+			i += 2
+		case direction == zrangeDirectionAuto && spec.direction == zrangeDirectionAuto && option == "REV":
+			spec.direction = zrangeReverse
+		case rangeType == zrangeAuto && spec.rangeType == zrangeAuto && option == "BYSCORE":
+			spec.rangeType = zrangeScore
+		case rangeType == zrangeAuto && spec.rangeType == zrangeAuto && option == "BYLEX":
+			spec.rangeType = zrangeLex
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+	if spec.direction == zrangeDirectionAuto {
+		spec.direction = zrangeForward
+	}
+	if spec.rangeType == zrangeAuto {
+		spec.rangeType = zrangeRank
+	}
+	if hasLimit && spec.rangeType == zrangeRank {
+		return nil, reply.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	if spec.withScores && spec.rangeType == zrangeLex {
+		return nil, reply.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+	}
+	// 逆序按照分值或字典序查询时, 参数的顺序是 max min
+	if spec.direction == zrangeReverse && spec.rangeType != zrangeRank {
+		spec.minArg, spec.maxArg = spec.maxArg, spec.minArg
+	}
+	return spec, nil
+}
+
+// normalizeRankRange 将 [start, stop] 形式的排名范围(可以为负数)转换为 [start, stop), 范围为空时 ok 为 false
+func normalizeRankRange(start int64, stop int64, size int64) (int64, int64, bool) {
+	if start < 0 {
+		start += size
+	}
+	if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop || start >= size {
+		return 0, 0, false
+	}
+	if stop >= size {
+		stop = size - 1
+	}
+	return start, stop + 1, true
+}
+
+// zrange 执行 ZRANGE 系列命令的查询, key 不存在时返回空列表
+func zrange(zset *sortedset.SortedSet, spec *zrangeSpec) ([]*sortedset.Element, resp.ErrorReply) {
+	desc := spec.direction == zrangeReverse
+	if spec.rangeType == zrangeRank {
+		start, errReply := parseInt64(spec.minArg)
+		if errReply != nil {
+			return nil, errReply
+		}
+		stop, errReply := parseInt64(spec.maxArg)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if zset == nil {
+			return nil, nil
+		}
+		start, stop, ok := normalizeRankRange(start, stop, zset.Len())
+		if !ok {
+			return nil, nil
+		}
+		return zset.RangeByRank(start, stop, desc), nil
+	}
+	min, max, errReply := parseRangeBorders(spec.minArg, spec.maxArg, spec.rangeType == zrangeLex)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if zset == nil || spec.offset < 0 {
+		return nil, nil
+	}
+	return zset.Range(min, max, spec.offset, spec.limit, desc), nil
+}
+
+// zrangeCommand 是 ZRANGE 系列只读命令的公共实现
+func zrangeCommand(db *DB, args [][]byte, rangeType int, direction int) resp.Reply {
+	spec, errReply := parseZRangeSpec(args[1:], rangeType, direction, false)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	elements, errReply := zrange(zset, spec)
+	if errReply != nil {
+		return errReply
+	}
+	return elementsReply(elements, spec.withScores)
+}
+
+// execZRange 返回指定范围内的元素
+// ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func execZRange(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeAuto, zrangeDirectionAuto)
+}
+
+// execZRevRange 按照分值从大到小返回排名在 [start, stop] 中的元素
+// ZREVRANGE key start stop [WITHSCORES]
+func execZRevRange(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeRank, zrangeReverse)
+}
+
+// execZRangeByScore 返回分值在 [min, max] 中的元素
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func execZRangeByScore(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeScore, zrangeForward)
+}
+
+// execZRevRangeByScore 按照分值从大到小返回分值在 [min, max] 中的元素
+// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+func execZRevRangeByScore(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeScore, zrangeReverse)
+}
+
+// execZRangeByLex 返回字典序在 [min, max] 中的元素
+// ZRANGEBYLEX key min max [LIMIT offset count]
+func execZRangeByLex(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeLex, zrangeForward)
+}
+
+// execZRevRangeByLex 按照字典序从大到小返回字典序在 [min, max] 中的元素
+// ZREVRANGEBYLEX key max min [LIMIT offset count]
+func execZRevRangeByLex(db *DB, args [][]byte) resp.Reply {
+	return zrangeCommand(db, args, zrangeLex, zrangeReverse)
+}
+
+// execZRangeStore 将指定范围内的元素存储到 dst, 返回结果的元素个数
+// ZRANGESTORE dst src min max [BYSCORE | BYLEX] [REV] [LIMIT offset count]
+func execZRangeStore(db *DB, args [][]byte) resp.Reply {
+	spec, errReply := parseZRangeSpec(args[2:], zrangeAuto, zrangeDirectionAuto, true)
+	if errReply != nil {
+		return errReply
+	}
+	src, errReply := db.getAsSortedSet(string(args[1]))
+	if errReply != nil {
+		return errReply
+	}
+	elements, errReply := zrange(src, spec)
+	if errReply != nil {
+		return errReply
+	}
+	result := sortedset.Make()
+	for _, element := range elements {
+		result.Add(element.Member, element.Score)
+	}
+	db.storeSortedSet(string(args[0]), result, "zrangestore")
+	return reply.MakeIntReply(result.Len())
+}
+
+/* ---- 删除范围 ---- */
+
+// execZRemRangeByRank 删除排名在 [start, stop] 中的元素, 返回删除的元素个数
+// ZREMRANGEBYRANK key start stop
+func execZRemRangeByRank(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	start, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	stop, errReply := parseInt64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	start, stop, ok := normalizeRankRange(start, stop, zset.Len())
+	if !ok {
+		return reply.MakeIntReply(0)
+	}
+	removed := zset.RemoveByRank(start, stop)
+	if removed > 0 {
+		db.notify(notifyZSet, "zremrangebyrank", key)
+	}
+	db.removeIfEmptySortedSet(key, zset)
+	return reply.MakeIntReply(removed)
+}
+
+// zsetRemoveRange 是 ZREMRANGEBYSCORE/ZREMRANGEBYLEX 的公共实现
+func zsetRemoveRange(db *DB, args [][]byte, byLex bool) resp.Reply {
+	key := string(args[0])
+	min, max, errReply := parseRangeBorders(args[1], args[2], byLex)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return reply.MakeIntReply(0)
+	}
+	removed := zset.RemoveRange(min, max)
+	if removed > 0 {
+		if byLex {
+			db.notify(notifyZSet, "zremrangebylex", key)
+		} else {
+			db.notify(notifyZSet, "zremrangebyscore", key)
+		}
+	}
+	db.removeIfEmptySortedSet(key, zset)
+	return reply.MakeIntReply(removed)
+}
+
+// execZRemRangeByScore 删除分值在 [min, max] 中的元素, 返回删除的元素个数
+// ZREMRANGEBYSCORE key min max
+func execZRemRangeByScore(db *DB, args [][]byte) resp.Reply {
+	return zsetRemoveRange(db, args, false)
+}
+
+// execZRemRangeByLex 删除字典序在 [min, max] 中的元素, 返回删除的元素个数
+// ZREMRANGEBYLEX key min max
+func execZRemRangeByLex(db *DB, args [][]byte) resp.Reply {
+	return zsetRemoveRange(db, args, true)
+}
+
+/* ---- 弹出 ---- */
+
+// 弹出元素的方向
+const (
+	zsetMin = iota // 分值最小的元素
+	zsetMax        // 分值最大的元素
+)
+
+// parseZSetDirection 解析 MIN|MAX
+func parseZSetDirection(arg []byte) (int, bool) {
+	switch toUpper(arg) {
+	case "MIN":
+		return zsetMin, true
+	case "MAX":
+		return zsetMax, true
+	}
+	return 0, false
+}
+
+// zsetPop 从有序集合中弹出 count 个元素, 有序集合为空时删除 key
+func (db *DB) zsetPop(key string, zset *sortedset.SortedSet, where int, count int64) []*sortedset.Element {
+	var elements []*sortedset.Element
+	event := "zpopmin"
+	if where == zsetMin {
+		elements = zset.PopMin(count)
+	} else {
+		elements = zset.PopMax(count)
+		event = "zpopmax"
+	}
+	if len(elements) > 0 {
+		db.notify(notifyZSet, event, key)
+	}
+	db.removeIfEmptySortedSet(key, zset)
+	return elements
+}
+
+// zsetPopCommand 是 ZPOPMIN/ZPOPMAX 的公共实现
+func zsetPopCommand(db *DB, args [][]byte, where int) resp.Reply {
+	if len(args) > 2 {
+		return reply.MakeSyntaxErrReply()
+	}
+	key := string(args[0])
+	count := int64(1)
+	if len(args) == 2 {
+		var errReply resp.ErrorReply
+		count, errReply = parseInt64(args[1])
+		if errReply != nil {
+			return errReply
+		}
+		if count < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+	}
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil || count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	return elementsReply(db.zsetPop(key, zset, where, count), true)
+}
+
+// execZPopMin 删除并返回分值最小的 count 个元素
+// ZPOPMIN key [count]
+func execZPopMin(db *DB, args [][]byte) resp.Reply {
+	return zsetPopCommand(db, args, zsetMin)
+}
+
+// execZPopMax 删除并返回分值最大的 count 个元素
+// ZPOPMAX key [count]
+func execZPopMax(db *DB, args [][]byte) resp.Reply {
+	return zsetPopCommand(db, args, zsetMax)
+}
+
+// parseZMPopArgs 解析 ZMPOP/BZMPOP 中 key 之后的 MIN | MAX [COUNT count]
+func parseZMPopArgs(args [][]byte) (where int, count int64, errReply resp.ErrorReply) {
+	if len(args) == 0 {
+		return 0, 0, reply.MakeSyntaxErrReply()
+	}
+	var ok bool
+	where, ok = parseZSetDirection(args[0])
+	if !ok {
+		return 0, 0, reply.MakeSyntaxErrReply()
+	}
+	count, errReply = parseMPopCount(args[1:])
+	return
+}
+
+// zsetMPop 从第一个非空的有序集合中弹出最多 count 个元素, 所有有序集合都为空时返回 nil
+func (db *DB) zsetMPop(keys []string, where int, count int64) (resp.Reply, resp.ErrorReply) {
+	for _, key := range keys {
+		zset, errReply := db.getAsSortedSet(key)
+		if errReply != nil {
+			return nil, errReply
+		}
+		if zset == nil {
+			continue
+		}
+		elements := db.zsetPop(key, zset, where, count)
+		pairs := make([]resp.Reply, len(elements))
+		for i, element := range elements {
+			pairs[i] = reply.MakeMultiBulkReply([][]byte{
+				[]byte(element.Member),
+				[]byte(formatScore(element.Score)),
+			})
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			reply.MakeMultiRawReply(pairs),
+		}), nil
+	}
+	return nil, nil
+}
+
+// execZMPop 从第一个非空的有序集合中弹出元素, 返回 key 和弹出的元素
+// ZMPOP numkeys key [key ...] MIN | MAX [COUNT count]
+func execZMPop(db *DB, args [][]byte) resp.Reply {
+	keys, rest, errReply := parseNumKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	where, count, errReply := parseZMPopArgs(rest)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.zsetMPop(keys, where, count)
+	if errReply != nil {
+		return errReply
+	}
+	if result == nil {
+		return reply.MakeNullMultiBulkReply()
+	}
+	return result
+}
+
+/* ---- 随机元素与迭代 ---- */
+
+// execZRandMember 随机返回元素
+// count 为正数时返回不重复的元素, 为负数时可能返回重复的元素
+// ZRANDMEMBER key [count [WITHSCORES]]
+func execZRandMember(db *DB, args [][]byte) resp.Reply {
+	if len(args) > 3 || (len(args) == 3 && toUpper(args[2]) != "WITHSCORES") {
+		return reply.MakeSyntaxErrReply()
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if len(args) == 1 {
+		if zset == nil {
+			return reply.MakeNullBulkReply()
+		}
+		return reply.MakeBulkReply([]byte(zset.RandomElements(1)[0].Member))
+	}
+	count, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	withScores := len(args) == 3
+	if count < -math.MaxInt64 || (withScores && count < -math.MaxInt64/2) {
+		return reply.MakeErrReply("ERR value is out of range")
+	}
+	if zset == nil || count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	if count > 0 {
+		return elementsReply(zset.RandomDistinctElements(int(count)), withScores)
+	}
+	return elementsReply(zset.RandomElements(int(-count)), withScores)
+}
+
+// execZScan 使用游标迭代有序集合中的元素
+// ZSCAN key cursor [MATCH pattern] [COUNT count]
+func execZScan(db *DB, args [][]byte) resp.Reply {
+	cursor, errReply := parseScanCursor(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	opts, errReply := parseScanOptions(args[2:], false, false)
+	if errReply != nil {
+		return errReply
+	}
+	zset, errReply := db.getAsSortedSet(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if zset == nil {
+		return makeScanReply(0, [][]byte{})
+	}
+	elements := make([][]byte, 0)
+	sampled := 0
+	cursor = scanLoop(cursor, opts.count, func(cursor uint64) uint64 {
+		return zset.Scan(cursor, func(element *sortedset.Element) {
+			sampled++
+			if opts.match(element.Member) {
+				elements = append(elements, []byte(element.Member), []byte(formatScore(element.Score)))
+			}
+		})
+	}, func() int { return sampled })
+	return makeScanReply(cursor, elements)
+}
+
+/* ---- 阻塞命令 ---- */
+
+// blockingZSetPop 是 BZPOPMIN/BZPOPMAX 的公共实现
+func blockingZSetPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte, where int) resp.Reply {
+	timeout, errReply := parseTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	keys := keysOf(args[:len(args)-1])
+	return execBlocking(mdb, c, &blockRequest{
+		keys:    keys,
+		timeout: timeout,
+		try: func(db *DB) resp.Reply {
+			for _, key := range keys {
+				zset, errReply := db.getAsSortedSet(key)
+				if errReply != nil {
+					return errReply
+				}
+				if zset == nil {
+					continue
+				}
+				element := db.zsetPop(key, zset, where, 1)[0]
+				return reply.MakeMultiBulkReply([][]byte{
+					[]byte(key),
+					[]byte(element.Member),
+					[]byte(formatScore(element.Score)),
+				})
+			}
+			return nil
+		},
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+// execBZPopMin 是阻塞版本的 ZPOPMIN, 从第一个非空的有序集合中弹出分值最小的元素
+// BZPOPMIN key [key ...] timeout
+func execBZPopMin(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingZSetPop(mdb, c, args, zsetMin)
+}
+
+// execBZPopMax 是阻塞版本的 ZPOPMAX, 从第一个非空的有序集合中弹出分值最大的元素
+// BZPOPMAX key [key ...] timeout
+func execBZPopMax(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	return blockingZSetPop(mdb, c, args, zsetMax)
+}
+
+// execBZMPop 是阻塞版本的 ZMPOP
+// BZMPOP timeout numkeys key [key ...] MIN | MAX [COUNT count]
+func execBZMPop(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	timeout, errReply := parseTimeout(args[0])
+	if errReply != nil {
+		return errReply
+	}
+	keys, rest, errReply := parseNumKeys(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	where, count, errReply := parseZMPopArgs(rest)
+	if errReply != nil {
+		return errReply
+	}
+	return execBlocking(mdb, c, &blockRequest{
+		keys:    keys,
+		timeout: timeout,
+		try: func(db *DB) resp.Reply {
+			result, errReply := db.zsetMPop(keys, where, count)
+			if errReply != nil {
+				return errReply
+			}
+			return result
+		},
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+func init() {
+	registerCommand("ZAdd", execZAdd, -4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("ZIncrBy", execZIncrBy, 4, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("ZRem", execZRem, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("ZScore", execZScore, 3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZMScore", execZMScore, -3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZCard", execZCard, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZCount", execZCount, 4, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZLexCount", execZLexCount, 4, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZRank", execZRank, -3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZRevRank", execZRevRank, -3, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("ZRange", execZRange, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRevRange", execZRevRange, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRangeByScore", execZRangeByScore, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRevRangeByScore", execZRevRangeByScore, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRangeByLex", execZRangeByLex, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRevRangeByLex", execZRevRangeByLex, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZRangeStore", execZRangeStore, -5, FlagWrite|FlagDenyOOM, 1, 2, 1)
+	registerCommand("ZRemRangeByRank", execZRemRangeByRank, 4, FlagWrite, 1, 1, 1)
+	registerCommand("ZRemRangeByScore", execZRemRangeByScore, 4, FlagWrite, 1, 1, 1)
+	registerCommand("ZRemRangeByLex", execZRemRangeByLex, 4, FlagWrite, 1, 1, 1)
+	registerCommand("ZPopMin", execZPopMin, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("ZPopMax", execZPopMax, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("ZMPop", execZMPop, -4, FlagWrite, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerCommand("ZRandMember", execZRandMember, -2, FlagReadOnly, 1, 1, 1)
+	registerCommand("ZScan", execZScan, -3, FlagReadOnly, 1, 1, 1)
+	registerSysCommand("BZPopMin", execBZPopMin, -3, FlagWrite|FlagFast|FlagBlocking, 1, -2, 1)
+	registerSysCommand("BZPopMax", execBZPopMax, -3, FlagWrite|FlagFast|FlagBlocking, 1, -2, 1)
+	registerSysCommand("BZMPop", execBZMPop, -5, FlagWrite|FlagBlocking, 0, 0, 0).
+		setKeysFunc(numKeysGetter(2))
+}
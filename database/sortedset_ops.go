@@ -0,0 +1,361 @@
+package database
+
+import (
+	"math"
+	"sort"
+
+	"github.com/LynchQ/my-go-redis/datastruct/set"
+	"github.com/LynchQ/my-go-redis/datastruct/sortedset"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * 有序集合的交集、并集和差集, 普通集合也可以作为输入, 其中元素的分值视为 1
+ */
+
+// 分值的聚合方式
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zsetInput 是集合运算的一个输入, zset 和 set 最多只有一个不为 nil, 都为 nil 时表示 key 不存在
+type zsetInput struct {
+	zset   *sortedset.SortedSet
+	set    *set.Set
+	weight float64
+}
+
+func (input *zsetInput) len() int64 {
+	switch {
+	case input.zset != nil:
+		return input.zset.Len()
+	case input.set != nil:
+		return int64(input.set.Len())
+	}
+	return 0
+}
+
+// score 返回元素乘以权重之后的分值, 结果为 NaN(0 * inf) 时视为 0
+func (input *zsetInput) score(member string) (float64, bool) {
+	var score float64
+	switch {
+	case input.zset != nil:
+		element, exists := input.zset.Get(member)
+		if !exists {
+			return 0, false
+		}
+		score = element.Score
+	case input.set != nil:
+		if !input.set.Has(member) {
+			return 0, false
+		}
+		score = 1
+	default:
+		return 0, false
+	}
+	return weightedScore(score, input.weight), true
+}
+
+// forEach 遍历所有元素, 分值已经乘以权重
+func (input *zsetInput) forEach(consumer func(member string, score float64) bool) {
+	switch {
+	case input.zset != nil:
+		input.zset.ForEach(func(element *sortedset.Element) bool {
+			return consumer(element.Member, weightedScore(element.Score, input.weight))
+		})
+	case input.set != nil:
+		input.set.ForEach(func(member string) bool {
+			return consumer(member, weightedScore(1, input.weight))
+		})
+	}
+}
+
+func weightedScore(score float64, weight float64) float64 {
+	score *= weight
+	if math.IsNaN(score) {
+		return 0
+	}
+	return score
+}
+
+// aggregateScore 按照聚合方式合并两个分值
+func aggregateScore(aggregate int, a float64, b float64) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	}
+	sum := a + b
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+// getZSetInputs 返回多个 key 对应的输入, key 必须是有序集合或者集合
+func (db *DB) getZSetInputs(keys []string) ([]*zsetInput, resp.ErrorReply) {
+	inputs := make([]*zsetInput, len(keys))
+	for i, key := range keys {
+		input := &zsetInput{weight: 1}
+		if entity, exists := db.GetEntity(key); exists {
+			switch data := entity.Data.(type) {
+			case *sortedset.SortedSet:
+				input.zset = data
+			case *set.Set:
+				input.set = data
+			default:
+				return nil, reply.MakeWrongTypeErrReply()
+			}
+		}
+		inputs[i] = input
+	}
+	return inputs, nil
+}
+
+// zsetIntersect 遍历多个输入的交集, consumer 返回 false 时停止
+func zsetIntersect(inputs []*zsetInput, aggregate int, consumer func(member string, score float64) bool) {
+	for _, input := range inputs {
+		if input.len() == 0 {
+			return
+		}
+	}
+	// 从元素最少的输入开始检查, 可以尽早排除不在交集中的元素
+	sorted := make([]*zsetInput, len(inputs))
+	copy(sorted, inputs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].len() < sorted[j].len()
+	})
+	sorted[0].forEach(func(member string, score float64) bool {
+		for _, input := range sorted[1:] {
+			other, exists := input.score(member)
+			if !exists {
+				return true
+			}
+			score = aggregateScore(aggregate, score, other)
+		}
+		return consumer(member, score)
+	})
+}
+
+// zsetOperation 计算多个输入的交集、并集或差集, 返回新的有序集合
+// 差集中元素的分值来自第一个输入
+func zsetOperation(inputs []*zsetInput, op int, aggregate int) *sortedset.SortedSet {
+	result := sortedset.Make()
+	switch op {
+	case setInter:
+		zsetIntersect(inputs, aggregate, func(member string, score float64) bool {
+			result.Add(member, score)
+			return true
+		})
+	case setUnion:
+		scores := make(map[string]float64)
+		for _, input := range inputs {
+			input.forEach(func(member string, score float64) bool {
+				if current, exists := scores[member]; exists {
+					score = aggregateScore(aggregate, current, score)
+				}
+				scores[member] = score
+				return true
+			})
+		}
+		for member, score := range scores {
+			result.Add(member, score)
+		}
+	case setDiff:
+		inputs[0].forEach(func(member string, score float64) bool {
+			for _, input := range inputs[1:] {
+				if _, exists := input.score(member); exists {
+					return true
+				}
+			}
+			result.Add(member, score)
+			return true
+		})
+	}
+	return result
+}
+
+// zsetOperationSpec 是 ZUNION/ZINTER/ZDIFF 系列命令的参数
+type zsetOperationSpec struct {
+	keys       []string
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// parseZSetOperationArgs 解析 numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// ZDIFF 系列命令不接受 WEIGHTS 和 AGGREGATE, store 为 true 时不接受 WITHSCORES
+func parseZSetOperationArgs(cmdName string, args [][]byte, op int, store bool) (*zsetOperationSpec, resp.ErrorReply) {
+	numKeys, errReply := parseInt64(args[0])
+	if errReply != nil {
+		return nil, errReply
+	}
+	if numKeys < 1 {
+		return nil, reply.MakeErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, reply.MakeSyntaxErrReply()
+	}
+	spec := &zsetOperationSpec{
+		keys:      keysOf(args[1 : numKeys+1]),
+		aggregate: aggregateSum,
+	}
+	rest := args[numKeys+1:]
+	for i := 0; i < len(rest); i++ {
+		option := toUpper(rest[i])
+		remaining := len(rest) - i - 1
+		switch {
+		case op != setDiff && option == "WEIGHTS" && remaining >= len(spec.keys):
+			spec.weights = make([]float64, len(spec.keys))
+			for j := range spec.weights {
+				weight, errReply := parseFloat64(rest[i+1+j])
+				if errReply != nil {
+					return nil, reply.MakeErrReply("ERR weight value is not a float")
+				}
+				spec.weights[j] = weight
+			}
+			i += len(spec.keys)
+		case op != setDiff && option == "AGGREGATE" && remaining >= 1:
+			switch toUpper(rest[i+1]) {
+			case "SUM":
+				spec.aggregate = aggregateSum
+			case "MIN":
+				spec.aggregate = aggregateMin
+			case "MAX":
+				spec.aggregate = aggregateMax
+			default:
+				return nil, reply.MakeSyntaxErrReply()
+			}
+			i++
+		case !store && option == "WITHSCORES":
+			spec.withScores = true
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+	return spec, nil
+}
+
+// execZSetOperation 读取输入并执行集合运算
+func (db *DB) execZSetOperation(spec *zsetOperationSpec, op int) (*sortedset.SortedSet, resp.ErrorReply) {
+	inputs, errReply := db.getZSetInputs(spec.keys)
+	if errReply != nil {
+		return nil, errReply
+	}
+	for i, weight := range spec.weights {
+		inputs[i].weight = weight
+	}
+	return zsetOperation(inputs, op, spec.aggregate), nil
+}
+
+// zsetOperationCommand 是 ZUNION/ZINTER/ZDIFF 的公共实现
+func zsetOperationCommand(db *DB, cmdName string, args [][]byte, op int) resp.Reply {
+	spec, errReply := parseZSetOperationArgs(cmdName, args, op, false)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.execZSetOperation(spec, op)
+	if errReply != nil {
+		return errReply
+	}
+	return elementsReply(result.RangeByRank(0, result.Len(), false), spec.withScores)
+}
+
+// zsetOperationStore 是 ZUNIONSTORE/ZINTERSTORE/ZDIFFSTORE 的公共实现
+// 结果为空时删除 destination, 否则覆盖 destination 并清除它的过期时间
+func zsetOperationStore(db *DB, cmdName string, args [][]byte, op int) resp.Reply {
+	spec, errReply := parseZSetOperationArgs(cmdName, args[1:], op, true)
+	if errReply != nil {
+		return errReply
+	}
+	result, errReply := db.execZSetOperation(spec, op)
+	if errReply != nil {
+		return errReply
+	}
+	db.storeSortedSet(string(args[0]), result, cmdName)
+	return reply.MakeIntReply(result.Len())
+}
+
+// execZUnion 返回多个有序集合的并集
+// ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func execZUnion(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationCommand(db, "zunion", args, setUnion)
+}
+
+// execZInter 返回多个有序集合的交集
+// ZINTER numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func execZInter(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationCommand(db, "zinter", args, setInter)
+}
+
+// execZDiff 返回第一个有序集合与其它有序集合的差集
+// ZDIFF numkeys key [key ...] [WITHSCORES]
+func execZDiff(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationCommand(db, "zdiff", args, setDiff)
+}
+
+// execZUnionStore 将多个有序集合的并集存储到 destination, 返回结果的元素个数
+// ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZUnionStore(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationStore(db, "zunionstore", args, setUnion)
+}
+
+// execZInterStore 将多个有序集合的交集存储到 destination, 返回结果的元素个数
+// ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZInterStore(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationStore(db, "zinterstore", args, setInter)
+}
+
+// execZDiffStore 将差集存储到 destination, 返回结果的元素个数
+// ZDIFFSTORE destination numkeys key [key ...]
+func execZDiffStore(db *DB, args [][]byte) resp.Reply {
+	return zsetOperationStore(db, "zdiffstore", args, setDiff)
+}
+
+// execZInterCard 返回多个有序集合的交集的元素个数, limit 大于 0 时最多计数到 limit
+// ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func execZInterCard(db *DB, args [][]byte) resp.Reply {
+	keys, limit, errReply := parseCardArgs(args)
+	if errReply != nil {
+		return errReply
+	}
+	inputs, errReply := db.getZSetInputs(keys)
+	if errReply != nil {
+		return errReply
+	}
+	var count int64
+	zsetIntersect(inputs, aggregateSum, func(member string, score float64) bool {
+		count++
+		return limit <= 0 || count < int64(limit)
+	})
+	return reply.MakeIntReply(count)
+}
+
+// storeNumKeysGetter 提取 ZUNIONSTORE destination numkeys key [key ...] 形式的 key
+func storeNumKeysGetter(cmdLine [][]byte) []string {
+	if len(cmdLine) < 2 {
+		return nil
+	}
+	return append([]string{string(cmdLine[1])}, numKeysGetter(2)(cmdLine)...)
+}
+
+func init() {
+	registerCommand("ZUnion", execZUnion, -3, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerCommand("ZInter", execZInter, -3, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerCommand("ZDiff", execZDiff, -3, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+	registerCommand("ZUnionStore", execZUnionStore, -4, FlagWrite|FlagDenyOOM, 1, 1, 1).
+		setKeysFunc(storeNumKeysGetter)
+	registerCommand("ZInterStore", execZInterStore, -4, FlagWrite|FlagDenyOOM, 1, 1, 1).
+		setKeysFunc(storeNumKeysGetter)
+	registerCommand("ZDiffStore", execZDiffStore, -4, FlagWrite|FlagDenyOOM, 1, 1, 1).
+		setKeysFunc(storeNumKeysGetter)
+	registerCommand("ZInterCard", execZInterCard, -3, FlagReadOnly, 0, 0, 0).
+		setKeysFunc(numKeysGetter(1))
+}
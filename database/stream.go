@@ -0,0 +1,660 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/datastruct/stream"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// approxTrimLimit 是使用 ~ 近似裁剪且没有指定 LIMIT 时, 每次最多删除的消息个数
+// 与 redis 的默认值 100 * stream-node-max-entries 一致
+const approxTrimLimit = 100 * 100
+
+// getAsStream 返回 key 对应的 stream, key 不存在时返回 nil
+func (db *DB) getAsStream(key string) (*stream.Stream, resp.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	s, ok := entity.Data.(*stream.Stream)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	return s, nil
+}
+
+// parseStrictID 解析 <ms>-<seq> 或 <ms> 格式的 ID, 省略 seq 时使用 missingSeq
+func parseStrictID(arg []byte, missingSeq uint64) (stream.ID, resp.ErrorReply) {
+	id, err := stream.ParseID(string(arg), missingSeq)
+	if err != nil {
+		return stream.ID{}, reply.MakeErrReply(err.Error())
+	}
+	return id, nil
+}
+
+// entryReply 将消息转换为 [id, [field1, value1, ...]]
+func entryReply(entry *stream.Entry) resp.Reply {
+	fields := make([][]byte, len(entry.Fields))
+	for i, field := range entry.Fields {
+		fields[i] = []byte(field)
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(entry.ID.String())),
+		reply.MakeMultiBulkReply(fields),
+	})
+}
+
+// entriesReply 将消息列表转换为回复
+func entriesReply(entries []*stream.Entry) resp.Reply {
+	replies := make([]resp.Reply, len(entries))
+	for i, entry := range entries {
+		replies[i] = entryReply(entry)
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+/* ---- 裁剪 ---- */
+
+// 裁剪策略
+const (
+	trimNone   = iota
+	trimMaxLen // 保留最新的 maxLen 条消息
+	trimMinID  // 删除 ID 小于 minID 的消息
+)
+
+// streamAddTrimArgs 是 XADD 和 XTRIM 的公共参数
+type streamAddTrimArgs struct {
+	// 裁剪
+	strategy int
+	maxLen   int64
+	minID    stream.ID
+	approx   bool  // ~, 近似裁剪
+	limit    int64 // 最多删除的消息个数, 0 表示不限制
+	// XADD
+	noMkStream bool
+	id         stream.ID
+	idGiven    bool
+	seqGiven   bool
+	fieldsPos  int // field 在参数中开始的位置
+}
+
+// parseStreamAddTrimArgs 解析 XADD 和 XTRIM 在 key 之后的参数
+// XADD: [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+// XTRIM: MAXLEN|MINID [=|~] threshold [LIMIT count]
+func parseStreamAddTrimArgs(args [][]byte, xadd bool) (*streamAddTrimArgs, resp.ErrorReply) {
+	result := &streamAddTrimArgs{}
+	limitGiven := false
+	i := 0
+	for ; i < len(args); i++ {
+		moreArgs := len(args) - i - 1
+		option := toUpper(args[i])
+		switch {
+		case xadd && option == "*":
+			// 自动生成 ID
+		case (option == "MAXLEN" || option == "MINID") && moreArgs > 0:
+			if result.strategy != trimNone {
+				return nil, reply.MakeErrReply("ERR syntax error, MAXLEN and MINID options at the same time are not compatible")
+			}
+			next := string(args[i+1])
+			if (next == "~" || next == "=") && moreArgs >= 2 {
+				result.approx = next == "~"
+				i++
+			}
+			i++
+			if option == "MAXLEN" {
+				maxLen, errReply := parseInt64(args[i])
+				if errReply != nil {
+					return nil, errReply
+				}
+				if maxLen < 0 {
+					return nil, reply.MakeErrReply("ERR The MAXLEN argument must be >= 0.")
+				}
+				result.strategy, result.maxLen = trimMaxLen, maxLen
+			} else {
+				minID, errReply := parseStrictID(args[i], 0)
+				if errReply != nil {
+					return nil, errReply
+				}
+				result.strategy, result.minID = trimMinID, minID
+			}
+			continue
+		case option == "LIMIT" && moreArgs > 0:
+			limit, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if limit < 0 {
+				return nil, reply.MakeErrReply("ERR The LIMIT argument must be >= 0.")
+			}
+			result.limit = limit
+			limitGiven = true
+			i++
+			continue
+		case xadd && option == "NOMKSTREAM":
+			result.noMkStream = true
+			continue
+		case xadd:
+			id, seqGiven, err := stream.ParseAddID(string(args[i]))
+			if err != nil {
+				return nil, reply.MakeErrReply(err.Error())
+			}
+			result.id, result.idGiven, result.seqGiven = id, true, seqGiven
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+		// XADD 的 ID 之后是 field 和 value
+		break
+	}
+	if limitGiven && !result.approx {
+		return nil, reply.MakeErrReply("ERR syntax error, LIMIT cannot be used without the special ~ option")
+	}
+	if result.approx && !limitGiven {
+		result.limit = approxTrimLimit
+	}
+	if !xadd && result.strategy == trimNone {
+		return nil, reply.MakeErrReply("ERR syntax error, XTRIM must be called with a trimming strategy")
+	}
+	result.fieldsPos = i + 1
+	return result, nil
+}
+
+// trimStream 按照参数裁剪 stream, 返回删除的消息个数
+func trimStream(s *stream.Stream, args *streamAddTrimArgs) int64 {
+	switch args.strategy {
+	case trimMaxLen:
+		return s.TrimByMaxLen(args.maxLen, args.limit)
+	case trimMinID:
+		return s.TrimByMinID(args.minID, args.limit)
+	}
+	return 0
+}
+
+/* ---- 命令 ---- */
+
+// execXAdd 添加消息, 返回消息的 ID
+// XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]
+func execXAdd(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	addArgs, errReply := parseStreamAddTrimArgs(args[1:], true)
+	if errReply != nil {
+		return errReply
+	}
+	fieldArgs := args[1:]
+	if addArgs.fieldsPos > len(fieldArgs) {
+		return reply.MakeArgNumErrReply("xadd")
+	}
+	fieldArgs = fieldArgs[addArgs.fieldsPos:]
+	if len(fieldArgs) < 2 || len(fieldArgs)%2 != 0 {
+		return reply.MakeArgNumErrReply("xadd")
+	}
+	if addArgs.idGiven && addArgs.seqGiven && addArgs.id == stream.MinID {
+		return reply.MakeErrReply("ERR The ID specified in XADD must be greater than 0-0")
+	}
+
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		if addArgs.noMkStream {
+			return reply.MakeNullBulkReply()
+		}
+		s = stream.Make()
+	}
+	fields := make([]string, len(fieldArgs))
+	for i, arg := range fieldArgs {
+		fields[i] = string(arg)
+	}
+	id, err := s.Add(addArgs.id, addArgs.idGiven, addArgs.seqGiven, uint64(nowMilli()), fields)
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	// 添加成功之后才创建 key, 避免 ID 错误时留下空的 stream
+	if _, exists := db.GetEntity(key); !exists {
+		db.PutEntity(key, &database.DataEntity{Data: s})
+	} else {
+		db.signalKeyAsReady(key)
+	}
+	db.notify(notifyStream, "xadd", key)
+	if trimStream(s, addArgs) > 0 {
+		db.notify(notifyStream, "xtrim", key)
+	}
+	return reply.MakeBulkReply([]byte(id.String()))
+}
+
+// execXLen 返回消息个数
+// XLEN key
+func execXLen(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(s.Len())
+}
+
+// parseRangeID 解析 XRANGE 的范围边界, 可以是 -、+、ID 或者以 ( 开头的不包含边界的 ID
+// 省略 seq 时, 起点使用 0, 终点使用最大值
+func parseRangeID(arg []byte, isStart bool) (stream.ID, resp.ErrorReply) {
+	str := string(arg)
+	switch str {
+	case "-":
+		return stream.MinID, nil
+	case "+":
+		return stream.MaxID, nil
+	}
+	exclude := strings.HasPrefix(str, "(")
+	if exclude {
+		str = str[1:]
+	}
+	missingSeq := uint64(0)
+	if !isStart {
+		missingSeq = stream.MaxID.Seq
+	}
+	id, errReply := parseStrictID([]byte(str), missingSeq)
+	if errReply != nil {
+		return stream.ID{}, errReply
+	}
+	if !exclude {
+		return id, nil
+	}
+	var ok bool
+	if isStart {
+		id, ok = id.Incr()
+		if !ok {
+			return stream.ID{}, reply.MakeErrReply("ERR invalid start ID for the interval")
+		}
+	} else {
+		id, ok = id.Decr()
+		if !ok {
+			return stream.ID{}, reply.MakeErrReply("ERR invalid end ID for the interval")
+		}
+	}
+	return id, nil
+}
+
+// streamRange 是 XRANGE/XREVRANGE 的公共实现, args 为 key start end [COUNT count]
+func streamRange(db *DB, args [][]byte, desc bool) resp.Reply {
+	startArg, endArg := args[1], args[2]
+	if desc {
+		startArg, endArg = endArg, startArg
+	}
+	start, errReply := parseRangeID(startArg, true)
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseRangeID(endArg, false)
+	if errReply != nil {
+		return errReply
+	}
+	count := int64(-1)
+	for i := 3; i < len(args); i++ {
+		if toUpper(args[i]) != "COUNT" || i+1 >= len(args) {
+			return reply.MakeSyntaxErrReply()
+		}
+		count, errReply = parseInt64(args[i+1])
+		if errReply != nil {
+			return errReply
+		}
+		if count < 0 {
+			count = 0
+		}
+		i++
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil || count == 0 {
+		return reply.MakeEmptyMultiBulkReply()
+	}
+	return entriesReply(s.Range(start, end, count, desc))
+}
+
+// execXRange 返回 ID 在 [start, end] 中的消息
+// XRANGE key start end [COUNT count]
+func execXRange(db *DB, args [][]byte) resp.Reply {
+	return streamRange(db, args, false)
+}
+
+// execXRevRange 按照 ID 从大到小返回 ID 在 [start, end] 中的消息
+// XREVRANGE key end start [COUNT count]
+func execXRevRange(db *DB, args [][]byte) resp.Reply {
+	return streamRange(db, args, true)
+}
+
+// execXDel 删除消息, 返回删除的消息个数, stream 为空时不会删除 key
+// XDEL key id [id ...]
+func execXDel(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, errReply := parseStrictID(arg, 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	key := string(args[0])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	var deleted int64
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.notify(notifyStream, "xdel", key)
+	}
+	return reply.MakeIntReply(deleted)
+}
+
+// execXTrim 裁剪 stream, 返回删除的消息个数
+// XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]
+func execXTrim(db *DB, args [][]byte) resp.Reply {
+	trimArgs, errReply := parseStreamAddTrimArgs(args[1:], false)
+	if errReply != nil {
+		return errReply
+	}
+	key := string(args[0])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	deleted := trimStream(s, trimArgs)
+	if deleted > 0 {
+		db.notify(notifyStream, "xtrim", key)
+	}
+	return reply.MakeIntReply(deleted)
+}
+
+/* ---- XREAD ---- */
+
+// xreadArgs 是 XREAD 和 XREADGROUP 的参数
+type xreadArgs struct {
+	count   int64 // 每个 stream 最多返回的消息个数, 0 表示不限制
+	block   bool
+	timeout time.Duration // 0 表示一直等待
+	keys    []string
+	idArgs  [][]byte // 每个 key 对应的起始 ID, $ 表示只读取新的消息, XREADGROUP 中 > 表示读取未投递的消息
+	// XREADGROUP
+	group    string
+	consumer string
+	noAck    bool
+}
+
+// parseXReadArgs 解析 XREAD 和 XREADGROUP 的参数
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func parseXReadArgs(args [][]byte, readGroup bool) (*xreadArgs, resp.ErrorReply) {
+	result := &xreadArgs{}
+	groupGiven := false
+	for i := 0; i < len(args); i++ {
+		moreArgs := len(args) - i - 1
+		switch option := toUpper(args[i]); {
+		case option == "COUNT" && moreArgs > 0:
+			count, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return nil, errReply
+			}
+			if count < 0 {
+				count = 0
+			}
+			result.count = count
+			i++
+		case option == "BLOCK" && moreArgs > 0:
+			ms, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return nil, reply.MakeErrReply("ERR timeout is not an integer or out of range")
+			}
+			if ms < 0 {
+				return nil, reply.MakeErrReply("ERR timeout is negative")
+			}
+			result.block = true
+			result.timeout = time.Duration(ms) * time.Millisecond
+			i++
+		case option == "GROUP" && moreArgs >= 2:
+			if !readGroup {
+				return nil, reply.MakeErrReply("ERR The GROUP option is only supported by XREADGROUP. You called XREAD instead.")
+			}
+			result.group, result.consumer = string(args[i+1]), string(args[i+2])
+			groupGiven = true
+			i += 2
+		case option == "NOACK" && readGroup:
+			result.noAck = true
+		case option == "STREAMS" && moreArgs > 0:
+			rest := args[i+1:]
+			if len(rest)%2 != 0 {
+				cmdName := "xread"
+				if readGroup {
+					cmdName = "xreadgroup"
+				}
+				return nil, reply.MakeErrReply("ERR Unbalanced '" + cmdName + "' list of streams: for each stream key an ID or '$' must be specified.")
+			}
+			if readGroup && !groupGiven {
+				return nil, reply.MakeErrReply("ERR Missing GROUP option for XREADGROUP")
+			}
+			result.keys = keysOf(rest[:len(rest)/2])
+			result.idArgs = rest[len(rest)/2:]
+			return result, nil
+		default:
+			return nil, reply.MakeSyntaxErrReply()
+		}
+	}
+	return nil, reply.MakeSyntaxErrReply()
+}
+
+// xreadKeysGetter 提取 XREAD 中 STREAMS 之后的 key
+func xreadKeysGetter(cmdLine [][]byte) []string {
+	args, errReply := parseXReadArgs(cmdLine[1:], false)
+	if errReply != nil {
+		return nil
+	}
+	return args.keys
+}
+
+// resolveXReadIDs 将 XREAD 的起始 ID 解析为具体的 ID, $ 解析为当前的最后一个 ID
+func (db *DB) resolveXReadIDs(args *xreadArgs) ([]stream.ID, resp.ErrorReply) {
+	ids := make([]stream.ID, len(args.keys))
+	for i, key := range args.keys {
+		if string(args.idArgs[i]) == ">" {
+			return nil, reply.MakeErrReply("ERR The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+		}
+		if string(args.idArgs[i]) == "$" {
+			s, errReply := db.getAsStream(key)
+			if errReply != nil {
+				return nil, errReply
+			}
+			if s != nil {
+				ids[i] = s.LastID()
+			}
+			continue
+		}
+		id, errReply := parseStrictID(args.idArgs[i], 0)
+		if errReply != nil {
+			return nil, errReply
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// streamRead 读取每个 stream 中 ID 大于 ids[i] 的消息, 所有 stream 都没有新消息时返回 nil
+func (db *DB) streamRead(keys []string, ids []stream.ID, count int64) resp.Reply {
+	var replies []resp.Reply
+	for i, key := range keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			continue
+		}
+		start, ok := ids[i].Incr()
+		if !ok {
+			continue
+		}
+		entries := s.Range(start, stream.MaxID, count, false)
+		if len(entries) == 0 {
+			continue
+		}
+		replies = append(replies, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			entriesReply(entries),
+		}))
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// execXRead 从一个或多个 stream 中读取 ID 大于指定 ID 的消息
+// 指定 BLOCK 且没有新消息时阻塞直到有新消息或者超时
+// XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+func execXRead(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	readArgs, errReply := parseXReadArgs(args, false)
+	if errReply != nil {
+		return errReply
+	}
+	db, selectErr := mdb.selectDB(c.GetDBIndex())
+	if selectErr != nil {
+		return selectErr
+	}
+	// $ 必须在阻塞之前解析, 否则阻塞期间添加的消息会被跳过
+	ids, errReply := db.resolveXReadIDs(readArgs)
+	if errReply != nil {
+		return errReply
+	}
+	try := func(db *DB) resp.Reply {
+		return db.streamRead(readArgs.keys, ids, readArgs.count)
+	}
+	if !readArgs.block {
+		if result := try(db); result != nil {
+			return result
+		}
+		return reply.MakeNullMultiBulkReply()
+	}
+	return execBlocking(mdb, c, &blockRequest{
+		keys:         readArgs.keys,
+		timeout:      readArgs.timeout,
+		try:          try,
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+/* ---- XINFO ---- */
+
+// execXInfo 返回 stream、消费者组或消费者的信息
+// XINFO STREAM key [FULL [COUNT count]]
+// XINFO GROUPS key
+// XINFO CONSUMERS key group
+func execXInfo(db *DB, args [][]byte) resp.Reply {
+	switch strings.ToLower(string(args[0])) {
+	case "stream":
+		if len(args) < 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'stream'")
+		}
+		return xinfoStream(db, args[1:])
+	case "groups":
+		if len(args) != 2 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'groups'")
+		}
+		return xinfoGroups(db, args[1:])
+	case "consumers":
+		if len(args) != 3 {
+			return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for 'consumers'")
+		}
+		return xinfoConsumers(db, args[1:])
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XINFO HELP.")
+}
+
+// xinfoStream 实现 XINFO STREAM key [FULL [COUNT count]]
+func xinfoStream(db *DB, args [][]byte) resp.Reply {
+	full := false
+	count := int64(10)
+	if len(args) > 1 {
+		if toUpper(args[1]) != "FULL" {
+			return reply.MakeSyntaxErrReply()
+		}
+		full = true
+		if len(args) == 4 && toUpper(args[2]) == "COUNT" {
+			var errReply resp.ErrorReply
+			count, errReply = parseInt64(args[3])
+			if errReply != nil {
+				return errReply
+			}
+			if count < 0 {
+				count = 10
+			}
+		} else if len(args) != 2 {
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+
+	keys, nodes := s.RadixTreeStats()
+	recordedFirstID := stream.MinID
+	first := s.FirstEntry()
+	if first != nil {
+		recordedFirstID = first.ID
+	}
+	result := []resp.Reply{
+		reply.MakeBulkReply([]byte("length")), reply.MakeIntReply(s.Len()),
+		reply.MakeBulkReply([]byte("radix-tree-keys")), reply.MakeIntReply(int64(keys)),
+		reply.MakeBulkReply([]byte("radix-tree-nodes")), reply.MakeIntReply(int64(nodes)),
+		reply.MakeBulkReply([]byte("last-generated-id")), reply.MakeBulkReply([]byte(s.LastID().String())),
+		reply.MakeBulkReply([]byte("max-deleted-entry-id")), reply.MakeBulkReply([]byte(s.MaxDeletedID().String())),
+		reply.MakeBulkReply([]byte("entries-added")), reply.MakeIntReply(s.EntriesAdded()),
+		reply.MakeBulkReply([]byte("recorded-first-entry-id")), reply.MakeBulkReply([]byte(recordedFirstID.String())),
+	}
+	if full {
+		return reply.MakeMultiRawReply(append(result,
+			reply.MakeBulkReply([]byte("entries")), entriesReply(s.Range(stream.MinID, stream.MaxID, count, false)),
+			reply.MakeBulkReply([]byte("groups")), groupsFullReply(s, count),
+		))
+	}
+	var firstReply, lastReply resp.Reply = reply.MakeNullBulkReply(), reply.MakeNullBulkReply()
+	if first != nil {
+		firstReply = entryReply(first)
+		lastReply = entryReply(s.LastEntry())
+	}
+	return reply.MakeMultiRawReply(append(result,
+		reply.MakeBulkReply([]byte("groups")), reply.MakeIntReply(int64(s.GroupCount())),
+		reply.MakeBulkReply([]byte("first-entry")), firstReply,
+		reply.MakeBulkReply([]byte("last-entry")), lastReply,
+	))
+}
+
+func init() {
+	registerCommand("XAdd", execXAdd, -5, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("XLen", execXLen, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("XRange", execXRange, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("XRevRange", execXRevRange, -4, FlagReadOnly, 1, 1, 1)
+	registerCommand("XDel", execXDel, -3, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("XTrim", execXTrim, -4, FlagWrite, 1, 1, 1)
+	registerSysCommand("XRead", execXRead, -4, FlagReadOnly|FlagBlocking, 0, 0, 0).
+		setKeysFunc(xreadKeysGetter)
+	registerCommand("XInfo", execXInfo, -2, FlagReadOnly, 2, 2, 1)
+}
@@ -0,0 +1,833 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/datastruct/stream"
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+/*
+ * stream 的消费者组: XGROUP、XREADGROUP、XACK、XPENDING、XCLAIM、XAUTOCLAIM
+ */
+
+// errNoGroup 返回 key 或消费者组不存在时的错误
+func errNoGroup(key string, group string) resp.ErrorReply {
+	return reply.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + group + "'")
+}
+
+// getStreamGroup 返回 key 对应的 stream 和消费者组, 任意一个不存在时返回 NOGROUP 错误
+func (db *DB) getStreamGroup(key string, groupName string) (*stream.Stream, *stream.Group, resp.ErrorReply) {
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return nil, nil, errReply
+	}
+	if s == nil {
+		return nil, nil, errNoGroup(key, groupName)
+	}
+	g, ok := s.Group(groupName)
+	if !ok {
+		return nil, nil, errNoGroup(key, groupName)
+	}
+	return s, g, nil
+}
+
+// getOrCreateConsumer 返回消费者, 不存在时创建, 并更新它的 seen-time
+func (db *DB) getOrCreateConsumer(key string, g *stream.Group, name string, nowMs int64) *stream.Consumer {
+	c, created := g.CreateConsumer(name, nowMs)
+	if created {
+		db.notify(notifyStream, "xgroup-createconsumer", key)
+	}
+	c.SeenTime = nowMs
+	return c
+}
+
+// parseEntriesRead 解析 ENTRIESREAD 选项的参数
+func parseEntriesRead(arg []byte) (int64, resp.ErrorReply) {
+	entriesRead, errReply := parseInt64(arg)
+	if errReply != nil {
+		return 0, errReply
+	}
+	if entriesRead < 0 && entriesRead != stream.InvalidEntriesRead {
+		return 0, reply.MakeErrReply("ERR value for ENTRIESREAD must be positive or -1")
+	}
+	return entriesRead, nil
+}
+
+// parseGroupID 解析 XGROUP CREATE/SETID 的 ID, $ 表示 stream 的最后一个 ID
+func parseGroupID(arg []byte, s *stream.Stream) (stream.ID, resp.ErrorReply) {
+	if string(arg) == "$" {
+		if s == nil {
+			return stream.MinID, nil
+		}
+		return s.LastID(), nil
+	}
+	return parseStrictID(arg, 0)
+}
+
+/* ---- XGROUP ---- */
+
+// execXGroup 管理消费者组和消费者
+// XGROUP CREATE key group id|$ [MKSTREAM] [ENTRIESREAD entries-read]
+// XGROUP SETID key group id|$ [ENTRIESREAD entries-read]
+// XGROUP DESTROY key group
+// XGROUP CREATECONSUMER key group consumer
+// XGROUP DELCONSUMER key group consumer
+func execXGroup(db *DB, args [][]byte) resp.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	argNum := len(args) + 1 // 包含命令名
+	valid := false
+	switch subCmd {
+	case "create":
+		valid = argNum >= 5 && argNum <= 8
+	case "setid":
+		valid = argNum >= 5 && argNum <= 7
+	case "destroy":
+		valid = argNum == 4
+	case "createconsumer", "delconsumer":
+		valid = argNum == 5
+	default:
+		return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try XGROUP HELP.")
+	}
+	if !valid {
+		return reply.MakeErrReply("ERR unknown subcommand or wrong number of arguments for '" + subCmd + "'")
+	}
+
+	key, groupName := string(args[1]), string(args[2])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	// CREATE 的选项需要在检查 key 是否存在之前解析, 因为 MKSTREAM 会创建 key
+	mkStream := false
+	entriesRead := int64(stream.InvalidEntriesRead)
+	if subCmd == "create" || subCmd == "setid" {
+		for i := 4; i < len(args); i++ {
+			switch option := toUpper(args[i]); {
+			case subCmd == "create" && option == "MKSTREAM":
+				mkStream = true
+			case option == "ENTRIESREAD" && i+1 < len(args):
+				entriesRead, errReply = parseEntriesRead(args[i+1])
+				if errReply != nil {
+					return errReply
+				}
+				i++
+			default:
+				return reply.MakeSyntaxErrReply()
+			}
+		}
+	}
+	if s == nil {
+		if !mkStream {
+			return reply.MakeErrReply("ERR The XGROUP subcommand requires the key to exist. " +
+				"Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+		}
+	}
+
+	var g *stream.Group
+	if subCmd != "create" {
+		var ok bool
+		g, ok = s.Group(groupName)
+		if !ok && subCmd != "destroy" {
+			return reply.MakeErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+		}
+	}
+
+	switch subCmd {
+	case "create":
+		id, errReply := parseGroupID(args[3], s)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			s = stream.Make()
+			db.PutEntity(key, &database.DataEntity{Data: s})
+		}
+		if _, created := s.CreateGroup(groupName, id, entriesRead); !created {
+			return reply.MakeErrReply("BUSYGROUP Consumer Group name already exists")
+		}
+		db.notify(notifyStream, "xgroup-create", key)
+		return reply.MakeOkReply()
+	case "setid":
+		id, errReply := parseGroupID(args[3], s)
+		if errReply != nil {
+			return errReply
+		}
+		g.LastID = id
+		g.EntriesRead = entriesRead
+		db.notify(notifyStream, "xgroup-setid", key)
+		return reply.MakeOkReply()
+	case "destroy":
+		if !s.DestroyGroup(groupName) {
+			return reply.MakeIntReply(0)
+		}
+		// 唤醒阻塞在这个消费者组上的 XREADGROUP, 使它们返回错误
+		db.signalKeyAsReady(key)
+		db.notify(notifyStream, "xgroup-destroy", key)
+		return reply.MakeIntReply(1)
+	case "createconsumer":
+		if _, created := g.CreateConsumer(string(args[3]), nowMilli()); !created {
+			return reply.MakeIntReply(0)
+		}
+		db.notify(notifyStream, "xgroup-createconsumer", key)
+		return reply.MakeIntReply(1)
+	default: // delconsumer
+		pending, deleted := g.DeleteConsumer(string(args[3]))
+		if deleted {
+			db.notify(notifyStream, "xgroup-delconsumer", key)
+		}
+		return reply.MakeIntReply(int64(pending))
+	}
+}
+
+/* ---- XREADGROUP ---- */
+
+// readGroupHistory 返回消费者的待确认消息中 ID 大于 after 的最多 count 条消息, 并增加它们的投递次数
+// 已经被删除的消息返回 [id, nil]
+func readGroupHistory(s *stream.Stream, c *stream.Consumer, after stream.ID, count int64, nowMs int64) resp.Reply {
+	replies := make([]resp.Reply, 0)
+	start, ok := after.Incr()
+	if !ok {
+		return reply.MakeMultiRawReply(replies)
+	}
+	c.ForEachPending(start, func(pe *stream.PendingEntry) bool {
+		entry, exists := s.Get(pe.ID)
+		if exists {
+			pe.DeliveryTime = nowMs
+			pe.DeliveryCount++
+			replies = append(replies, entryReply(entry))
+		} else {
+			replies = append(replies, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply([]byte(pe.ID.String())),
+				reply.MakeNullMultiBulkReply(),
+			}))
+		}
+		return count <= 0 || int64(len(replies)) < count
+	})
+	return reply.MakeMultiRawReply(replies)
+}
+
+// streamReadGroup 执行 XREADGROUP, ids[i] 为 nil 表示 >, 即读取未投递给消费者组的消息
+// 所有 > 的 stream 都没有新消息且没有读取历史消息时返回 nil
+// blocked 为 true 时表示在阻塞之后重新执行, stream 或消费者组被删除时返回错误
+func (db *DB) streamReadGroup(args *xreadArgs, ids []*stream.ID, blocked bool) resp.Reply {
+	// 先检查所有的 stream 和消费者组, 避免出错时已经投递了部分消息
+	streams := make([]*stream.Stream, len(args.keys))
+	groups := make([]*stream.Group, len(args.keys))
+	for i, key := range args.keys {
+		s, errReply := db.getAsStream(key)
+		if errReply != nil {
+			return errReply
+		}
+		if s == nil {
+			if blocked {
+				return reply.MakeErrReply("UNBLOCKED the stream key no longer exists")
+			}
+			return reply.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + args.group + "' in XREADGROUP with GROUP option")
+		}
+		g, ok := s.Group(args.group)
+		if !ok {
+			if blocked {
+				return reply.MakeErrReply("NOGROUP the consumer group this client was blocked on no longer exists")
+			}
+			return reply.MakeErrReply("NOGROUP No such key '" + key + "' or consumer group '" + args.group + "' in XREADGROUP with GROUP option")
+		}
+		streams[i], groups[i] = s, g
+	}
+
+	nowMs := nowMilli()
+	var replies []resp.Reply
+	for i, key := range args.keys {
+		s, g := streams[i], groups[i]
+		c := db.getOrCreateConsumer(key, g, args.consumer, nowMs)
+		var entries resp.Reply
+		if ids[i] == nil {
+			read := s.ReadGroup(g, c, args.count, args.noAck, nowMs)
+			if len(read) == 0 {
+				continue
+			}
+			entries = entriesReply(read)
+		} else {
+			entries = readGroupHistory(s, c, *ids[i], args.count, nowMs)
+		}
+		replies = append(replies, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(key)),
+			entries,
+		}))
+	}
+	if len(replies) == 0 {
+		return nil
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// xreadGroupKeysGetter 提取 XREADGROUP 中 STREAMS 之后的 key
+func xreadGroupKeysGetter(cmdLine [][]byte) []string {
+	args, errReply := parseXReadArgs(cmdLine[1:], true)
+	if errReply != nil {
+		return nil
+	}
+	return args.keys
+}
+
+// execXReadGroup 以消费者组中消费者的身份读取消息
+// ID 为 > 时读取未投递给消费者组的消息, 并加入消费者的待确认消息列表; 其它 ID 读取消费者的待确认消息
+// 指定 BLOCK 且所有 stream 都没有新消息时阻塞直到有新消息或者超时
+// XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func execXReadGroup(mdb *StandaloneDatabase, c resp.Connection, args [][]byte) resp.Reply {
+	readArgs, errReply := parseXReadArgs(args, true)
+	if errReply != nil {
+		return errReply
+	}
+	ids := make([]*stream.ID, len(readArgs.keys))
+	history := false
+	for i, arg := range readArgs.idArgs {
+		switch string(arg) {
+		case ">":
+			continue
+		case "$":
+			return reply.MakeErrReply("ERR The $ ID is meaningless in the context of XREADGROUP: " +
+				"you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. " +
+				"The $ ID would just return an empty result set.")
+		}
+		id, errReply := parseStrictID(arg, 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = &id
+		history = true
+	}
+	db, selectErr := mdb.selectDB(c.GetDBIndex())
+	if selectErr != nil {
+		return selectErr
+	}
+	result := db.streamReadGroup(readArgs, ids, false)
+	if result != nil {
+		return result
+	}
+	// 读取历史消息时不会阻塞
+	if !readArgs.block || history {
+		return reply.MakeNullMultiBulkReply()
+	}
+	return mdb.blockClient(c, &blockRequest{
+		keys:    readArgs.keys,
+		timeout: readArgs.timeout,
+		try: func(db *DB) resp.Reply {
+			return db.streamReadGroup(readArgs, ids, true)
+		},
+		timeoutReply: reply.MakeNullMultiBulkReply(),
+	})
+}
+
+/* ---- XACK ---- */
+
+// execXAck 确认消息, 返回确认的消息个数
+// XACK key group id [id ...]
+func execXAck(db *DB, args [][]byte) resp.Reply {
+	ids := make([]stream.ID, len(args)-2)
+	for i, arg := range args[2:] {
+		id, errReply := parseStrictID(arg, 0)
+		if errReply != nil {
+			return errReply
+		}
+		ids[i] = id
+	}
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeIntReply(0)
+	}
+	g, ok := s.Group(string(args[1]))
+	if !ok {
+		return reply.MakeIntReply(0)
+	}
+	var acked int64
+	for _, id := range ids {
+		if g.Ack(id) {
+			acked++
+		}
+	}
+	return reply.MakeIntReply(acked)
+}
+
+/* ---- XPENDING ---- */
+
+// execXPending 返回待确认消息的信息
+// 不指定范围时返回 [消息个数, 最小 ID, 最大 ID, [[消费者, 消息个数], ...]]
+// 指定范围时返回 [[id, 消费者, 空闲毫秒数, 投递次数], ...]
+// XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func execXPending(db *DB, args [][]byte) resp.Reply {
+	key, groupName := string(args[0]), string(args[1])
+	argNum := len(args) + 1
+	extended := argNum >= 6
+	if !extended && argNum != 3 {
+		return reply.MakeSyntaxErrReply()
+	}
+
+	var minIdle, count int64
+	var start, end stream.ID
+	var consumerName string
+	filterConsumer := false
+	if extended {
+		startIdx := 2
+		if toUpper(args[2]) == "IDLE" {
+			var errReply resp.ErrorReply
+			minIdle, errReply = parseInt64(args[3])
+			if errReply != nil {
+				return errReply
+			}
+			if argNum < 8 {
+				return reply.MakeSyntaxErrReply()
+			}
+			startIdx += 2
+		}
+		if len(args) > startIdx+4 {
+			return reply.MakeSyntaxErrReply()
+		}
+		if len(args) < startIdx+3 {
+			return reply.MakeSyntaxErrReply()
+		}
+		var errReply resp.ErrorReply
+		count, errReply = parseInt64(args[startIdx+2])
+		if errReply != nil {
+			return errReply
+		}
+		if count < 0 {
+			count = 0
+		}
+		start, errReply = parseRangeID(args[startIdx], true)
+		if errReply != nil {
+			return errReply
+		}
+		end, errReply = parseRangeID(args[startIdx+1], false)
+		if errReply != nil {
+			return errReply
+		}
+		if len(args) == startIdx+4 {
+			consumerName = string(args[startIdx+3])
+			filterConsumer = true
+		}
+	}
+
+	_, g, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	if !extended {
+		return pendingSummaryReply(g)
+	}
+
+	nowMs := nowMilli()
+	replies := make([]resp.Reply, 0)
+	var consumer *stream.Consumer
+	if filterConsumer {
+		var ok bool
+		consumer, ok = g.Consumer(consumerName)
+		if !ok {
+			return reply.MakeMultiRawReply(replies)
+		}
+	}
+	if count == 0 {
+		return reply.MakeMultiRawReply(replies)
+	}
+	g.ForEachPending(start, end, func(pe *stream.PendingEntry) bool {
+		if consumer != nil && pe.Consumer != consumer {
+			return true
+		}
+		idle := nowMs - pe.DeliveryTime
+		if idle < minIdle {
+			return true
+		}
+		replies = append(replies, reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte(pe.ID.String())),
+			reply.MakeBulkReply([]byte(pe.Consumer.Name)),
+			reply.MakeIntReply(idle),
+			reply.MakeIntReply(pe.DeliveryCount),
+		}))
+		return int64(len(replies)) < count
+	})
+	return reply.MakeMultiRawReply(replies)
+}
+
+// pendingSummaryReply 返回 XPENDING 的概要信息
+func pendingSummaryReply(g *stream.Group) resp.Reply {
+	if g.PendingLen() == 0 {
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeIntReply(0),
+			reply.MakeNullBulkReply(),
+			reply.MakeNullBulkReply(),
+			reply.MakeNullMultiBulkReply(),
+		})
+	}
+	var first, last stream.ID
+	g.ForEachPending(stream.MinID, stream.MaxID, func(pe *stream.PendingEntry) bool {
+		if first == stream.MinID {
+			first = pe.ID
+		}
+		last = pe.ID
+		return true
+	})
+	consumers := make([]resp.Reply, 0)
+	for _, c := range g.Consumers() {
+		if c.PendingLen() == 0 {
+			continue
+		}
+		consumers = append(consumers, reply.MakeMultiBulkReply([][]byte{
+			[]byte(c.Name),
+			[]byte(strconv.FormatInt(int64(c.PendingLen()), 10)),
+		}))
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeIntReply(int64(g.PendingLen())),
+		reply.MakeBulkReply([]byte(first.String())),
+		reply.MakeBulkReply([]byte(last.String())),
+		reply.MakeMultiRawReply(consumers),
+	})
+}
+
+/* ---- XCLAIM/XAUTOCLAIM ---- */
+
+// claimedReply 返回被认领的消息, justID 为 true 时只返回 ID
+func claimedReply(s *stream.Stream, pe *stream.PendingEntry, justID bool) resp.Reply {
+	if justID {
+		return reply.MakeBulkReply([]byte(pe.ID.String()))
+	}
+	entry, _ := s.Get(pe.ID)
+	return entryReply(entry)
+}
+
+// execXClaim 将空闲时间不小于 min-idle-time 的待确认消息转移给消费者, 返回被认领的消息
+// XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func execXClaim(db *DB, args [][]byte) resp.Reply {
+	key, groupName, consumerName := string(args[0]), string(args[1]), string(args[2])
+	s, g, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	minIdle, ok := strictParseInt64(args[3])
+	if !ok {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+
+	// ID 之后的第一个无法解析为 ID 的参数开始是选项
+	var ids []stream.ID
+	i := 4
+	for ; i < len(args); i++ {
+		id, err := stream.ParseID(string(args[i]), 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	nowMs := nowMilli()
+	deliveryTime := int64(-1)
+	retryCount := int64(-1)
+	force, justID := false, false
+	var lastID *stream.ID
+	for ; i < len(args); i++ {
+		moreArgs := len(args) - i - 1
+		switch option := toUpper(args[i]); {
+		case option == "FORCE":
+			force = true
+		case option == "JUSTID":
+			justID = true
+		case option == "IDLE" && moreArgs > 0:
+			i++
+			idle, ok := strictParseInt64(args[i])
+			if !ok {
+				return reply.MakeErrReply("ERR Invalid IDLE option argument for XCLAIM")
+			}
+			deliveryTime = nowMs - idle
+		case option == "TIME" && moreArgs > 0:
+			i++
+			t, ok := strictParseInt64(args[i])
+			if !ok {
+				return reply.MakeErrReply("ERR Invalid TIME option argument for XCLAIM")
+			}
+			deliveryTime = t
+		case option == "RETRYCOUNT" && moreArgs > 0:
+			i++
+			n, ok := strictParseInt64(args[i])
+			if !ok {
+				return reply.MakeErrReply("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			retryCount = n
+		case option == "LASTID" && moreArgs > 0:
+			i++
+			id, errReply := parseStrictID(args[i], 0)
+			if errReply != nil {
+				return errReply
+			}
+			lastID = &id
+		default:
+			return reply.MakeErrReply("ERR Unrecognized XCLAIM option '" + string(args[i]) + "'")
+		}
+	}
+	if deliveryTime < 0 || deliveryTime > nowMs {
+		deliveryTime = nowMs
+	}
+	if lastID != nil && g.LastID.Less(*lastID) {
+		g.LastID = *lastID
+	}
+
+	consumer, exists := g.Consumer(consumerName)
+	if exists {
+		consumer.SeenTime = nowMs
+	}
+	replies := make([]resp.Reply, 0)
+	for _, id := range ids {
+		pe, pending := g.GetPending(id)
+		_, inStream := s.Get(id)
+		if pending && !inStream {
+			// 消息已经被删除, 从待确认消息列表中删除
+			g.Ack(id)
+			continue
+		}
+		if !pending {
+			if !force || !inStream {
+				continue
+			}
+			consumer = db.getOrCreateConsumer(key, g, consumerName, nowMs)
+			pe = g.AddPending(id, consumer, deliveryTime)
+			pe.DeliveryCount = 1
+		}
+		if minIdle > 0 && nowMs-pe.DeliveryTime < minIdle {
+			continue
+		}
+		consumer = db.getOrCreateConsumer(key, g, consumerName, nowMs)
+		g.Claim(pe, consumer, deliveryTime, retryCount < 0 && !justID)
+		if retryCount >= 0 {
+			pe.DeliveryCount = retryCount
+		}
+		consumer.ActiveTime = nowMs
+		replies = append(replies, claimedReply(s, pe, justID))
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// execXAutoClaim 从 start 开始扫描待确认消息, 将空闲时间不小于 min-idle-time 的最多 count 条消息转移给消费者
+// 返回 [下一次扫描的起始 ID, 被认领的消息, 已经被删除的消息 ID], 扫描结束时起始 ID 为 0-0
+// XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func execXAutoClaim(db *DB, args [][]byte) resp.Reply {
+	key, groupName, consumerName := string(args[0]), string(args[1]), string(args[2])
+	s, g, errReply := db.getStreamGroup(key, groupName)
+	if errReply != nil {
+		return errReply
+	}
+	minIdle, ok := strictParseInt64(args[3])
+	if !ok {
+		return reply.MakeErrReply("ERR Invalid min-idle-time argument for XAUTOCLAIM")
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	start, errReply := parseRangeID(args[4], true)
+	if errReply != nil {
+		return errReply
+	}
+	// 每次最多检查 count * attemptsFactor 条待确认消息, 避免在大量不满足条件的消息上花费太多时间
+	const attemptsFactor = 10
+	count := int64(100)
+	justID := false
+	for i := 5; i < len(args); i++ {
+		switch option := toUpper(args[i]); {
+		case option == "COUNT" && i+1 < len(args):
+			n, ok := strictParseInt64(args[i+1])
+			if !ok || n < 1 || n > (1<<63-1)/attemptsFactor {
+				return reply.MakeErrReply("ERR COUNT must be > 0")
+			}
+			count = n
+			i++
+		case option == "JUSTID":
+			justID = true
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	nowMs := nowMilli()
+	attempts := count * attemptsFactor
+	claimed := make([]resp.Reply, 0)
+	deleted := make([][]byte, 0)
+	var toClaim, toDelete []*stream.PendingEntry
+	next := stream.MinID // 扫描结束时为 0-0
+	g.ForEachPending(start, stream.MaxID, func(pe *stream.PendingEntry) bool {
+		if attempts == 0 || count == 0 {
+			next = pe.ID
+			return false
+		}
+		attempts--
+		if _, inStream := s.Get(pe.ID); !inStream {
+			toDelete = append(toDelete, pe)
+			return true
+		}
+		if minIdle > 0 && nowMs-pe.DeliveryTime < minIdle {
+			return true
+		}
+		toClaim = append(toClaim, pe)
+		count--
+		return true
+	})
+	// 遍历结束之后再修改待确认消息列表
+	for _, pe := range toDelete {
+		g.Ack(pe.ID)
+		deleted = append(deleted, []byte(pe.ID.String()))
+	}
+	if len(toClaim) > 0 {
+		consumer := db.getOrCreateConsumer(key, g, consumerName, nowMs)
+		for _, pe := range toClaim {
+			g.Claim(pe, consumer, nowMs, !justID)
+			claimed = append(claimed, claimedReply(s, pe, justID))
+		}
+		consumer.ActiveTime = nowMs
+	} else if consumer, ok := g.Consumer(consumerName); ok {
+		consumer.SeenTime = nowMs
+	}
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeBulkReply([]byte(next.String())),
+		reply.MakeMultiRawReply(claimed),
+		reply.MakeMultiBulkReply(deleted),
+	})
+}
+
+/* ---- XINFO GROUPS/CONSUMERS ---- */
+
+// entriesReadReply 返回消费者组读取过的消息个数, 未知时返回 nil
+func entriesReadReply(g *stream.Group) resp.Reply {
+	if g.EntriesRead == stream.InvalidEntriesRead {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeIntReply(g.EntriesRead)
+}
+
+// lagReply 返回消费者组尚未读取的消息个数, 无法计算时返回 nil
+func lagReply(s *stream.Stream, g *stream.Group) resp.Reply {
+	lag, ok := s.Lag(g)
+	if !ok {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeIntReply(lag)
+}
+
+// xinfoGroups 实现 XINFO GROUPS key
+func xinfoGroups(db *DB, args [][]byte) resp.Reply {
+	s, errReply := db.getAsStream(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	groups := s.Groups()
+	replies := make([]resp.Reply, len(groups))
+	for i, g := range groups {
+		replies[i] = reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(g.Name)),
+			reply.MakeBulkReply([]byte("consumers")), reply.MakeIntReply(int64(g.ConsumerCount())),
+			reply.MakeBulkReply([]byte("pending")), reply.MakeIntReply(int64(g.PendingLen())),
+			reply.MakeBulkReply([]byte("last-delivered-id")), reply.MakeBulkReply([]byte(g.LastID.String())),
+			reply.MakeBulkReply([]byte("entries-read")), entriesReadReply(g),
+			reply.MakeBulkReply([]byte("lag")), lagReply(s, g),
+		})
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// xinfoConsumers 实现 XINFO CONSUMERS key group
+func xinfoConsumers(db *DB, args [][]byte) resp.Reply {
+	key, groupName := string(args[0]), string(args[1])
+	s, errReply := db.getAsStream(key)
+	if errReply != nil {
+		return errReply
+	}
+	if s == nil {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	g, ok := s.Group(groupName)
+	if !ok {
+		return reply.MakeErrReply("NOGROUP No such consumer group '" + groupName + "' for key name '" + key + "'")
+	}
+	nowMs := nowMilli()
+	consumers := g.Consumers()
+	replies := make([]resp.Reply, len(consumers))
+	for i, c := range consumers {
+		inactive := int64(-1)
+		if c.ActiveTime >= 0 {
+			inactive = nowMs - c.ActiveTime
+		}
+		replies[i] = reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(c.Name)),
+			reply.MakeBulkReply([]byte("pending")), reply.MakeIntReply(int64(c.PendingLen())),
+			reply.MakeBulkReply([]byte("idle")), reply.MakeIntReply(nowMs - c.SeenTime),
+			reply.MakeBulkReply([]byte("inactive")), reply.MakeIntReply(inactive),
+		})
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+// groupsFullReply 返回 XINFO STREAM FULL 中消费者组的详细信息, 每个待确认消息列表最多返回 count 条, count 为 0 时不限制
+func groupsFullReply(s *stream.Stream, count int64) resp.Reply {
+	groups := s.Groups()
+	replies := make([]resp.Reply, len(groups))
+	for i, g := range groups {
+		pending := make([]resp.Reply, 0)
+		g.ForEachPending(stream.MinID, stream.MaxID, func(pe *stream.PendingEntry) bool {
+			pending = append(pending, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply([]byte(pe.ID.String())),
+				reply.MakeBulkReply([]byte(pe.Consumer.Name)),
+				reply.MakeIntReply(pe.DeliveryTime),
+				reply.MakeIntReply(pe.DeliveryCount),
+			}))
+			return count <= 0 || int64(len(pending)) < count
+		})
+		consumers := make([]resp.Reply, 0, g.ConsumerCount())
+		for _, c := range g.Consumers() {
+			consumerPending := make([]resp.Reply, 0)
+			c.ForEachPending(stream.MinID, func(pe *stream.PendingEntry) bool {
+				consumerPending = append(consumerPending, reply.MakeMultiRawReply([]resp.Reply{
+					reply.MakeBulkReply([]byte(pe.ID.String())),
+					reply.MakeIntReply(pe.DeliveryTime),
+					reply.MakeIntReply(pe.DeliveryCount),
+				}))
+				return count <= 0 || int64(len(consumerPending)) < count
+			})
+			consumers = append(consumers, reply.MakeMultiRawReply([]resp.Reply{
+				reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(c.Name)),
+				reply.MakeBulkReply([]byte("seen-time")), reply.MakeIntReply(c.SeenTime),
+				reply.MakeBulkReply([]byte("active-time")), reply.MakeIntReply(c.ActiveTime),
+				reply.MakeBulkReply([]byte("pel-count")), reply.MakeIntReply(int64(c.PendingLen())),
+				reply.MakeBulkReply([]byte("pending")), reply.MakeMultiRawReply(consumerPending),
+			}))
+		}
+		replies[i] = reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("name")), reply.MakeBulkReply([]byte(g.Name)),
+			reply.MakeBulkReply([]byte("last-delivered-id")), reply.MakeBulkReply([]byte(g.LastID.String())),
+			reply.MakeBulkReply([]byte("entries-read")), entriesReadReply(g),
+			reply.MakeBulkReply([]byte("lag")), lagReply(s, g),
+			reply.MakeBulkReply([]byte("pel-count")), reply.MakeIntReply(int64(g.PendingLen())),
+			reply.MakeBulkReply([]byte("pending")), reply.MakeMultiRawReply(pending),
+			reply.MakeBulkReply([]byte("consumers")), reply.MakeMultiRawReply(consumers),
+		})
+	}
+	return reply.MakeMultiRawReply(replies)
+}
+
+func init() {
+	registerCommand("XGroup", execXGroup, -2, FlagWrite|FlagDenyOOM, 2, 2, 1)
+	registerSysCommand("XReadGroup", execXReadGroup, -7, FlagWrite|FlagBlocking, 0, 0, 0).
+		setKeysFunc(xreadGroupKeysGetter)
+	registerCommand("XAck", execXAck, -4, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("XPending", execXPending, -3, FlagReadOnly, 1, 1, 1)
+	registerCommand("XClaim", execXClaim, -6, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("XAutoClaim", execXAutoClaim, -6, FlagWrite|FlagFast, 1, 1, 1)
+}
@@ -0,0 +1,684 @@
+package database
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/LynchQ/my-go-redis/interface/database"
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// getAsString 返回 key 对应的字符串, key 不存在时返回 nil, 类型错误时返回 WrongTypeErrReply
+func (db *DB) getAsString(key string) ([]byte, resp.ErrorReply) {
+	entity, ok := db.GetEntity(key)
+	if !ok {
+		return nil, nil
+	}
+	bytes, ok := entity.Data.([]byte)
+	if !ok {
+		return nil, reply.MakeWrongTypeErrReply()
+	}
+	return bytes, nil
+}
+
+// execGet 返回 key 对应的字符串
+// GET key
+func execGet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	bytes, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if bytes == nil {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(bytes)
+}
+
+// SET 的写入策略
+const (
+	upsertPolicy = iota // 默认, 存在则更新, 不存在则插入
+	insertPolicy        // NX, 仅当 key 不存在时写入
+	updatePolicy        // XX, 仅当 key 存在时写入
+)
+
+// expireOption 是 SET/GETEX 等命令中与过期时间相关的选项
+type expireOption struct {
+	set        bool      // 是否指定了 EX/PX/EXAT/PXAT
+	expireTime time.Time // 过期时间
+	keepTTL    bool      // KEEPTTL
+	persist    bool      // PERSIST
+}
+
+// parseExpireArg 解析 EX/PX/EXAT/PXAT 选项的参数
+// 与 keyExpire 相同, 先换算为 unix 毫秒时间戳并检查溢出, 避免 time.Duration 溢出之后 key 被立即删除
+func parseExpireArg(option string, arg []byte, cmdName string) (time.Time, resp.ErrorReply) {
+	val, errReply := parseInt64(arg)
+	if errReply != nil {
+		return time.Time{}, errReply
+	}
+	invalid := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	if val <= 0 {
+		return time.Time{}, invalid
+	}
+	unit := int64(1)
+	if option == "EX" || option == "EXAT" {
+		unit = 1000
+	}
+	if val > math.MaxInt64/unit {
+		return time.Time{}, invalid
+	}
+	expireAt := val * unit
+	if option == "EX" || option == "PX" {
+		now := nowMilli()
+		if expireAt > math.MaxInt64-now {
+			return time.Time{}, invalid
+		}
+		expireAt += now
+	}
+	return time.UnixMilli(expireAt), nil
+}
+
+// applyExpireOption 在写入 key 之后根据选项更新过期时间
+func (db *DB) applyExpireOption(key string, opt *expireOption) {
+	if opt.set {
+		db.ExpireOrRemove(key, opt.expireTime)
+	} else if opt.persist {
+		if _, hasTTL := db.GetExpireTime(key); hasTTL {
+			db.Persist(key)
+			db.notify(notifyGeneric, "persist", key)
+		}
+	} else if !opt.keepTTL {
+		db.Persist(key)
+	}
+}
+
+// execSet 写入字符串
+// SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func execSet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	value := args[1]
+	policy := upsertPolicy
+	returnOld := false
+	opt := &expireOption{}
+
+	for i := 2; i < len(args); i++ {
+		option := toUpper(args[i])
+		switch option {
+		case "NX":
+			if policy == updatePolicy {
+				return reply.MakeSyntaxErrReply()
+			}
+			policy = insertPolicy
+		case "XX":
+			if policy == insertPolicy {
+				return reply.MakeSyntaxErrReply()
+			}
+			policy = updatePolicy
+		case "GET":
+			returnOld = true
+		case "KEEPTTL":
+			if opt.set {
+				return reply.MakeSyntaxErrReply()
+			}
+			opt.keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opt.set || opt.keepTTL || i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			expireTime, errReply := parseExpireArg(option, args[i+1], "set")
+			if errReply != nil {
+				return errReply
+			}
+			opt.set = true
+			opt.expireTime = expireTime
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	oldValue, errReply := db.getAsString(key)
+	if returnOld && errReply != nil {
+		return errReply
+	}
+	_, exists := db.GetEntity(key)
+
+	var result resp.Reply = reply.MakeOkReply()
+	if returnOld {
+		if oldValue == nil {
+			result = reply.MakeNullBulkReply()
+		} else {
+			result = reply.MakeBulkReply(oldValue)
+		}
+	}
+	if (policy == insertPolicy && exists) || (policy == updatePolicy && !exists) {
+		if returnOld {
+			return result
+		}
+		return reply.MakeNullBulkReply()
+	}
+
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "set", key)
+	db.applyExpireOption(key, opt)
+	return result
+}
+
+// execSetNX 仅当 key 不存在时写入字符串
+// SETNX key value
+func execSetNX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	if _, exists := db.GetEntity(key); exists {
+		return reply.MakeIntReply(0)
+	}
+	db.PutEntity(key, &database.DataEntity{Data: args[1]})
+	db.notify(notifyString, "set", key)
+	return reply.MakeIntReply(1)
+}
+
+// execSetEX 写入字符串并设置以秒为单位的过期时间
+// SETEX key seconds value
+func execSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithExpire(db, args, "EX", "setex")
+}
+
+// execPSetEX 写入字符串并设置以毫秒为单位的过期时间
+// PSETEX key milliseconds value
+func execPSetEX(db *DB, args [][]byte) resp.Reply {
+	return setWithExpire(db, args, "PX", "psetex")
+}
+
+func setWithExpire(db *DB, args [][]byte, option string, cmdName string) resp.Reply {
+	key := string(args[0])
+	expireTime, errReply := parseExpireArg(option, args[1], cmdName)
+	if errReply != nil {
+		return errReply
+	}
+	db.PutEntity(key, &database.DataEntity{Data: args[2]})
+	db.notify(notifyString, "set", key)
+	db.Expire(key, expireTime)
+	db.notify(notifyGeneric, "expire", key)
+	return reply.MakeOkReply()
+}
+
+// execGetSet 写入字符串并返回旧值
+// GETSET key value
+func execGetSet(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	db.PutEntity(key, &database.DataEntity{Data: args[1]})
+	db.Persist(key)
+	db.notify(notifyString, "set", key)
+	if old == nil {
+		return reply.MakeNullBulkReply()
+	}
+	return reply.MakeBulkReply(old)
+}
+
+// execGetDel 返回字符串并删除 key
+// GETDEL key
+func execGetDel(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if old == nil {
+		return reply.MakeNullBulkReply()
+	}
+	db.Remove(key)
+	db.notify(notifyGeneric, "del", key)
+	return reply.MakeBulkReply(old)
+}
+
+// execGetEX 返回字符串并修改过期时间
+// GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func execGetEX(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	opt := &expireOption{keepTTL: true}
+	for i := 1; i < len(args); i++ {
+		option := toUpper(args[i])
+		switch option {
+		case "PERSIST":
+			if opt.set {
+				return reply.MakeSyntaxErrReply()
+			}
+			opt.persist = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if opt.set || opt.persist || i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			expireTime, errReply := parseExpireArg(option, args[i+1], "getex")
+			if errReply != nil {
+				return errReply
+			}
+			opt.set = true
+			opt.expireTime = expireTime
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if value == nil {
+		return reply.MakeNullBulkReply()
+	}
+	db.applyExpireOption(key, opt)
+	return reply.MakeBulkReply(value)
+}
+
+// execAppend 在字符串末尾追加内容, 返回追加后的长度
+// APPEND key value
+func execAppend(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	if len(old)+len(args[1]) > maxStringLen {
+		return reply.MakeErrReply(errStringTooBig)
+	}
+	// 旧值可能正在被其它回复引用, 不能原地修改
+	value := make([]byte, 0, len(old)+len(args[1]))
+	value = append(value, old...)
+	value = append(value, args[1]...)
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "append", key)
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// execStrLen 返回字符串的长度
+// STRLEN key
+func execStrLen(db *DB, args [][]byte) resp.Reply {
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+	return reply.MakeIntReply(int64(len(value)))
+}
+
+// execGetRange 返回字符串的子串, start 和 end 均包含在内, 支持负数下标
+// GETRANGE key start end
+func execGetRange(db *DB, args [][]byte) resp.Reply {
+	start, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	end, errReply := parseInt64(args[2])
+	if errReply != nil {
+		return errReply
+	}
+	value, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return errReply
+	}
+
+	strLen := int64(len(value))
+	if start < 0 && end < 0 && start > end {
+		return reply.MakeBulkReply([]byte{})
+	}
+	if start < 0 {
+		start += strLen
+	}
+	if end < 0 {
+		end += strLen
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= strLen {
+		end = strLen - 1
+	}
+	if start > end || strLen == 0 {
+		return reply.MakeBulkReply([]byte{})
+	}
+	return reply.MakeBulkReply(value[start : end+1])
+}
+
+// execSetRange 从 offset 开始覆盖字符串, 不足的部分用 0 填充, 返回修改后的长度
+// SETRANGE key offset value
+func execSetRange(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	offset, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if offset < 0 {
+		return reply.MakeErrReply("ERR offset is out of range")
+	}
+	patch := args[2]
+	old, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	// 空的 value 不会修改或创建 key
+	if len(patch) == 0 {
+		return reply.MakeIntReply(int64(len(old)))
+	}
+	if offset+int64(len(patch)) > maxStringLen {
+		return reply.MakeErrReply(errStringTooBig)
+	}
+
+	size := int64(len(old))
+	if end := offset + int64(len(patch)); end > size {
+		size = end
+	}
+	value := make([]byte, size)
+	copy(value, old)
+	copy(value[offset:], patch)
+	db.PutEntity(key, &database.DataEntity{Data: value})
+	db.notify(notifyString, "setrange", key)
+	return reply.MakeIntReply(size)
+}
+
+// execMSet 写入多个字符串
+// MSET key value [key value ...]
+func execMSet(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.MakeArgNumErrReply("mset")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, &database.DataEntity{Data: args[i+1]})
+		db.Persist(key)
+		db.notify(notifyString, "set", key)
+	}
+	return reply.MakeOkReply()
+}
+
+// execMSetNX 仅当所有 key 都不存在时写入多个字符串
+// MSETNX key value [key value ...]
+func execMSetNX(db *DB, args [][]byte) resp.Reply {
+	if len(args)%2 != 0 {
+		return reply.MakeArgNumErrReply("msetnx")
+	}
+	for i := 0; i < len(args); i += 2 {
+		if _, exists := db.GetEntity(string(args[i])); exists {
+			return reply.MakeIntReply(0)
+		}
+	}
+	for i := 0; i < len(args); i += 2 {
+		key := string(args[i])
+		db.PutEntity(key, &database.DataEntity{Data: args[i+1]})
+		db.notify(notifyString, "set", key)
+	}
+	return reply.MakeIntReply(1)
+}
+
+// execMGet 返回多个 key 对应的字符串, 不存在或者不是字符串的 key 返回 nil
+// MGET key [key ...]
+func execMGet(db *DB, args [][]byte) resp.Reply {
+	result := make([][]byte, len(args))
+	for i, arg := range args {
+		value, errReply := db.getAsString(string(arg))
+		if errReply != nil {
+			continue
+		}
+		result[i] = value
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execLCS 返回两个字符串的最长公共子序列
+// LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func execLCS(db *DB, args [][]byte) resp.Reply {
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		switch toUpper(args[i]) {
+		case "LEN":
+			getLen = true
+		case "IDX":
+			getIdx = true
+		case "WITHMATCHLEN":
+			withMatchLen = true
+		case "MINMATCHLEN":
+			if i+1 >= len(args) {
+				return reply.MakeSyntaxErrReply()
+			}
+			val, errReply := parseInt64(args[i+1])
+			if errReply != nil {
+				return errReply
+			}
+			if val < 0 {
+				val = 0
+			}
+			minMatchLen = val
+			i++
+		default:
+			return reply.MakeSyntaxErrReply()
+		}
+	}
+
+	a, errReply := db.getAsString(string(args[0]))
+	if errReply != nil {
+		return reply.MakeErrReply("ERR The specified keys must contain string values")
+	}
+	b, errReply := db.getAsString(string(args[1]))
+	if errReply != nil {
+		return reply.MakeErrReply("ERR The specified keys must contain string values")
+	}
+	if getLen && getIdx {
+		return reply.MakeErrReply("ERR If you want both the length and indexes, please just use IDX.")
+	}
+
+	// dp[i][j] 是 a[:i] 和 b[:j] 的最长公共子序列长度
+	aLen, bLen := len(a), len(b)
+	width := bLen + 1
+	dp := make([]uint32, (aLen+1)*width)
+	for i := 1; i <= aLen; i++ {
+		for j := 1; j <= bLen; j++ {
+			if a[i-1] == b[j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j-1] + 1
+			} else if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				dp[i*width+j] = dp[(i-1)*width+j]
+			} else {
+				dp[i*width+j] = dp[i*width+j-1]
+			}
+		}
+	}
+	idx := int(dp[aLen*width+bLen])
+	if getLen {
+		return reply.MakeIntReply(int64(idx))
+	}
+
+	// 从末尾回溯, 得到公共子序列以及匹配的区间
+	result := make([]byte, idx)
+	matchLen := idx
+	var matches []resp.Reply
+	i, j := aLen, bLen
+	aStart, aEnd, bStart, bEnd := aLen, 0, 0, 0 // aStart == aLen 表示当前没有区间
+	for i > 0 && j > 0 {
+		emitRange := false
+		if a[i-1] == b[j-1] {
+			result[idx-1] = a[i-1]
+			if aStart == aLen {
+				aStart, aEnd = i-1, i-1
+				bStart, bEnd = j-1, j-1
+			} else if aStart == i && bStart == j {
+				// 与当前区间连续, 向前扩展
+				aStart--
+				bStart--
+			} else {
+				emitRange = true
+			}
+			if aStart == 0 || bStart == 0 {
+				emitRange = true
+			}
+			idx--
+			i--
+			j--
+		} else {
+			if dp[(i-1)*width+j] > dp[i*width+j-1] {
+				i--
+			} else {
+				j--
+			}
+			if aStart != aLen {
+				emitRange = true
+			}
+		}
+
+		if emitRange {
+			rangeLen := int64(aEnd - aStart + 1)
+			if getIdx && (minMatchLen == 0 || rangeLen >= minMatchLen) {
+				match := []resp.Reply{
+					makeIntPairReply(int64(aStart), int64(aEnd)),
+					makeIntPairReply(int64(bStart), int64(bEnd)),
+				}
+				if withMatchLen {
+					match = append(match, reply.MakeIntReply(rangeLen))
+				}
+				matches = append(matches, reply.MakeMultiRawReply(match))
+			}
+			aStart = aLen
+		}
+	}
+
+	if getIdx {
+		if matches == nil {
+			matches = []resp.Reply{}
+		}
+		return reply.MakeMultiRawReply([]resp.Reply{
+			reply.MakeBulkReply([]byte("matches")),
+			reply.MakeMultiRawReply(matches),
+			reply.MakeBulkReply([]byte("len")),
+			reply.MakeIntReply(int64(matchLen)),
+		})
+	}
+	return reply.MakeBulkReply(result)
+}
+
+func makeIntPairReply(a, b int64) resp.Reply {
+	return reply.MakeMultiRawReply([]resp.Reply{
+		reply.MakeIntReply(a),
+		reply.MakeIntReply(b),
+	})
+}
+
+// incrBy 将字符串表示的整数增加 delta, 保留 key 原有的过期时间
+func (db *DB) incrBy(key string, delta int64) resp.Reply {
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current int64
+	if value != nil {
+		var ok bool
+		current, ok = strictParseInt64(value)
+		if !ok {
+			return reply.MakeErrReply(errNotInteger)
+		}
+	}
+	if (delta < 0 && current < math.MinInt64-delta) || (delta > 0 && current > math.MaxInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	current += delta
+	db.PutEntity(key, &database.DataEntity{Data: []byte(strconv.FormatInt(current, 10))})
+	db.notify(notifyString, "incrby", key)
+	return reply.MakeIntReply(current)
+}
+
+// execIncr 将 key 的值加 1
+// INCR key
+func execIncr(db *DB, args [][]byte) resp.Reply {
+	return db.incrBy(string(args[0]), 1)
+}
+
+// execDecr 将 key 的值减 1
+// DECR key
+func execDecr(db *DB, args [][]byte) resp.Reply {
+	return db.incrBy(string(args[0]), -1)
+}
+
+// execIncrBy 将 key 的值增加 increment
+// INCRBY key increment
+func execIncrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	return db.incrBy(string(args[0]), delta)
+}
+
+// execDecrBy 将 key 的值减少 decrement
+// DECRBY key decrement
+func execDecrBy(db *DB, args [][]byte) resp.Reply {
+	delta, errReply := parseInt64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	if delta == math.MinInt64 {
+		return reply.MakeErrReply("ERR decrement would overflow")
+	}
+	return db.incrBy(string(args[0]), -delta)
+}
+
+// execIncrByFloat 将 key 的值增加浮点数 increment, 返回字符串形式的结果
+// INCRBYFLOAT key increment
+func execIncrByFloat(db *DB, args [][]byte) resp.Reply {
+	key := string(args[0])
+	delta, errReply := parseFloat64(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	value, errReply := db.getAsString(key)
+	if errReply != nil {
+		return errReply
+	}
+	var current float64
+	if value != nil {
+		current, errReply = parseFloat64(value)
+		if errReply != nil {
+			return errReply
+		}
+	}
+	if math.IsInf(current, 0) || math.IsInf(delta, 0) {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	sum, ok := incrLongDouble(value, args[1])
+	if !ok {
+		return reply.MakeErrReply("ERR increment would produce NaN or Infinity")
+	}
+	result := []byte(sum)
+	db.PutEntity(key, &database.DataEntity{Data: result})
+	db.notify(notifyString, "incrbyfloat", key)
+	return reply.MakeBulkReply(result)
+}
+
+func init() {
+	registerCommand("Get", execGet, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("Set", execSet, -3, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("SetNX", execSetNX, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("SetEX", execSetEX, 4, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("PSetEX", execPSetEX, 4, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("GetSet", execGetSet, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("GetDel", execGetDel, 2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("GetEX", execGetEX, -2, FlagWrite|FlagFast, 1, 1, 1)
+	registerCommand("Append", execAppend, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("StrLen", execStrLen, 2, FlagReadOnly|FlagFast, 1, 1, 1)
+	registerCommand("GetRange", execGetRange, 4, FlagReadOnly, 1, 1, 1)
+	registerCommand("SetRange", execSetRange, 4, FlagWrite|FlagDenyOOM, 1, 1, 1)
+	registerCommand("MSet", execMSet, -3, FlagWrite|FlagDenyOOM, 1, -1, 2)
+	registerCommand("MSetNX", execMSetNX, -3, FlagWrite|FlagDenyOOM, 1, -1, 2)
+	registerCommand("MGet", execMGet, -2, FlagReadOnly|FlagFast, 1, -1, 1)
+	registerCommand("LCS", execLCS, -3, FlagReadOnly, 1, 2, 1)
+	registerCommand("Incr", execIncr, 2, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("Decr", execDecr, 2, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("IncrBy", execIncrBy, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("DecrBy", execDecrBy, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+	registerCommand("IncrByFloat", execIncrByFloat, 3, FlagWrite|FlagDenyOOM|FlagFast, 1, 1, 1)
+}
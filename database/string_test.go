@@ -0,0 +1,38 @@
+package database
+
+import "testing"
+
+func TestIncrByFloat(t *testing.T) {
+	mdb := NewStandaloneDatabase()
+	defer mdb.Close()
+	c := &fakeConn{}
+
+	cases := []struct {
+		cmd  string
+		want string
+	}{
+		{"INCRBYFLOAT k 0.1", "$3\r\n0.1\r\n"},
+		{"INCRBYFLOAT k 0.2", "$3\r\n0.3\r\n"},
+		{"GET k", "$3\r\n0.3\r\n"},
+		{"SET k 10.50", "+OK\r\n"},
+		{"INCRBYFLOAT k 0.1", "$4\r\n10.6\r\n"},
+		{"INCRBYFLOAT k -10.6", "$1\r\n0\r\n"},
+		{"SET k 5.0e3", "+OK\r\n"},
+		{"INCRBYFLOAT k 2.0e2", "$4\r\n5200\r\n"},
+		{"INCRBYFLOAT k inf", "-ERR increment would produce NaN or Infinity\r\n"},
+		{"INCRBYFLOAT k abc", "-ERR value is not a valid float\r\n"},
+	}
+	for _, tc := range cases {
+		if got := string(mdb.Exec(c, toCmdLine(tc.cmd)).ToBytes()); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.cmd, got, tc.want)
+		}
+	}
+
+	// 重复累加时存储的值不会逐渐偏离
+	for i := 0; i < 10; i++ {
+		mdb.Exec(c, toCmdLine("INCRBYFLOAT c 0.1"))
+	}
+	if got := string(mdb.Exec(c, toCmdLine("GET c")).ToBytes()); got != "$1\r\n1\r\n" {
+		t.Errorf("ten increments of 0.1: got %q, want %q", got, "$1\r\n1\r\n")
+	}
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/LynchQ/my-go-redis/interface/resp"
+	"github.com/LynchQ/my-go-redis/resp/reply"
+)
+
+// 常用的错误信息, 与 redis 保持一致
+const (
+	errNotInteger   = "ERR value is not an integer or out of range"
+	errNotFloat     = "ERR value is not a valid float"
+	errStringTooBig = "ERR string exceeds maximum allowed size (proto-max-bulk-len)"
+)
+
+// maxStringLen 字符串的最大长度 512MB
+const maxStringLen = 512 * 1024 * 1024
+
+// parseInt64 解析整数参数
+func parseInt64(arg []byte) (int64, resp.ErrorReply) {
+	val, ok := strictParseInt64(arg)
+	if !ok {
+		return 0, reply.MakeErrReply(errNotInteger)
+	}
+	return val, nil
+}
+
+// strictParseInt64 与 redis 的 string2ll 一致, 不接受前导的 + 号、空格和 0
+func strictParseInt64(arg []byte) (int64, bool) {
+	if len(arg) == 0 || len(arg) > 20 {
+		return 0, false
+	}
+	digits := arg
+	if digits[0] == '-' {
+		digits = digits[1:]
+	}
+	if len(digits) == 0 || digits[0] < '0' || digits[0] > '9' || (digits[0] == '0' && len(arg) > 1) {
+		return 0, false
+	}
+	val, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return val, true
+}
+
+// parseFloat64 解析浮点数参数, 不接受 NaN
+func parseFloat64(arg []byte) (float64, resp.ErrorReply) {
+	str := string(arg)
+	val, err := strconv.ParseFloat(str, 64)
+	if err != nil || math.IsNaN(val) || strings.TrimSpace(str) != str {
+		return 0, reply.MakeErrReply(errNotFloat)
+	}
+	return val, nil
+}
+
+// formatFloat 按照 redis 的格式输出浮点数
+func formatFloat(val float64) string {
+	if math.IsInf(val, 1) {
+		return "inf"
+	}
+	if math.IsInf(val, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+// longDoublePrec 是 redis 计算 INCRBYFLOAT 等命令使用的 long double 的尾数位数
+const longDoublePrec = 64
+
+// incrLongDouble 与 redis 相同, 以 long double 精度计算 value + increment, 返回保存和回复使用的字符串
+// 两个参数都需要先经过 parseFloat64 检查, value 为 nil 时视为 0
+// 与 redis 的 ld2string(LD_STR_HUMAN) 相同, 结果保留 17 位小数后去掉末尾的 0, 这样 0.1 + 0.2 得到 0.3,
+// 重复累加时存储的值也不会像 float64 那样逐渐偏离
+// 结果无法用 float64 表示时 ok 为 false, 否则下一次累加时无法解析存储的值
+func incrLongDouble(value, increment []byte) (result string, ok bool) {
+	sum := new(big.Float).SetPrec(longDoublePrec)
+	if value != nil {
+		if _, _, err := sum.Parse(string(value), 0); err != nil {
+			return "", false
+		}
+	}
+	delta := new(big.Float).SetPrec(longDoublePrec)
+	if _, _, err := delta.Parse(string(increment), 0); err != nil {
+		return "", false
+	}
+	sum.Add(sum, delta)
+	if f, _ := sum.Float64(); math.IsInf(f, 0) {
+		return "", false
+	}
+	result = sum.Text('f', 17)
+	result = strings.TrimRight(result, "0")
+	result = strings.TrimSuffix(result, ".")
+	if result == "-0" {
+		result = "0"
+	}
+	return result, true
+}
+
+// formatScore 按照 redis 的格式输出有序集合的分值
+// 可以精确表示的整数输出为整数, 其它值使用最短的能够还原的表示
+func formatScore(score float64) string {
+	if math.IsInf(score, 0) {
+		return formatFloat(score)
+	}
+	if score == math.Trunc(score) && math.Abs(score) <= 1<<53 {
+		return strconv.FormatInt(int64(score), 10)
+	}
+	return strconv.FormatFloat(score, 'g', -1, 64)
+}
+
+// toUpper 将参数转换为大写字符串, 用于解析命令选项
+func toUpper(arg []byte) string {
+	return strings.ToUpper(string(arg))
+}
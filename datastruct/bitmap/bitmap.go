@@ -0,0 +1,105 @@
+package bitmap
+
+import "math/bits"
+
+/*
+ * 按照 redis 的位序操作字节切片: 第 0 位是第 0 个字节的最高位
+ */
+
+// GetBit 返回 offset 处的位, 超出长度的部分视为 0
+func GetBit(b []byte, offset int64) byte {
+	byteIndex := offset >> 3
+	if byteIndex >= int64(len(b)) {
+		return 0
+	}
+	bitIndex := 7 - uint(offset&7)
+	return (b[byteIndex] >> bitIndex) & 1
+}
+
+// SetBit 设置 offset 处的位, 调用者需要保证 b 的长度足够
+func SetBit(b []byte, offset int64, val byte) {
+	byteIndex := offset >> 3
+	bitIndex := 7 - uint(offset&7)
+	if val != 0 {
+		b[byteIndex] |= 1 << bitIndex
+	} else {
+		b[byteIndex] &^= 1 << bitIndex
+	}
+}
+
+// Grow 返回长度至少能容纳 bitOffset 的副本, 原切片不会被修改
+func Grow(b []byte, bitOffset int64) []byte {
+	size := int64(len(b))
+	if need := bitOffset>>3 + 1; need > size {
+		size = need
+	}
+	result := make([]byte, size)
+	copy(result, b)
+	return result
+}
+
+// CountBits 统计 [start, end] 位区间内 1 的个数, 区间两端都包含在内
+func CountBits(b []byte, start int64, end int64) int64 {
+	if start > end {
+		return 0
+	}
+	var count int64
+	firstByte, lastByte := start>>3, end>>3
+	for i := firstByte; i <= lastByte; i++ {
+		v := b[i]
+		if i == firstByte {
+			v &= 0xff >> uint(start&7)
+		}
+		if i == lastByte {
+			v &= 0xff << uint(7-end&7)
+		}
+		count += int64(bits.OnesCount8(v))
+	}
+	return count
+}
+
+// FindBit 返回 [start, end] 位区间内第一个值为 bit 的位置, 找不到时返回 -1
+func FindBit(b []byte, bit byte, start int64, end int64) int64 {
+	// 跳过整个字节都不满足的部分
+	var skip byte
+	if bit == 0 {
+		skip = 0xff
+	}
+	for pos := start; pos <= end; {
+		if pos&7 == 0 && pos+7 <= end && b[pos>>3] == skip {
+			pos += 8
+			continue
+		}
+		if GetBit(b, pos) == bit {
+			return pos
+		}
+		pos++
+	}
+	return -1
+}
+
+// GetUnsigned 读取从 offset 开始的 width 位无符号整数
+func GetUnsigned(b []byte, offset int64, width uint) uint64 {
+	var val uint64
+	for i := uint(0); i < width; i++ {
+		val = val<<1 | uint64(GetBit(b, offset+int64(i)))
+	}
+	return val
+}
+
+// GetSigned 读取从 offset 开始的 width 位有符号整数
+func GetSigned(b []byte, offset int64, width uint) int64 {
+	val := GetUnsigned(b, offset, width)
+	// 符号位扩展
+	if width < 64 && val&(1<<(width-1)) != 0 {
+		val |= ^uint64(0) << width
+	}
+	return int64(val)
+}
+
+// SetUnsigned 将 val 的低 width 位写入从 offset 开始的位置
+func SetUnsigned(b []byte, offset int64, width uint, val uint64) {
+	for i := uint(0); i < width; i++ {
+		SetBit(b, offset+int64(i), byte(val>>(width-1-i))&1)
+	}
+}
@@ -0,0 +1,407 @@
+package dict
+
+import (
+	"hash/maphash"
+	"math/bits"
+	"math/rand"
+	"time"
+)
+
+/*
+ * ChainedDict 是与 redis 的 dict 相同的拉链法哈希表, 桶的个数总是 2 的幂
+ * 相比 map 它暴露了桶的结构, 因此可以:
+ *   - 使用反向二进制游标遍历(SCAN), 在哈希表扩容或缩容之间保证遍历开始时存在且没有被删除的 key 至少被返回一次
+ *   - 随机选择一个桶进行抽样, 而不需要遍历
+ *   - 渐进式 rehash: 扩容或缩容时同时持有新旧两个哈希表, 每次写操作只迁移一个桶, 剩余的桶由定时任务迁移,
+ *     避免元素很多时一次性迁移所有元素造成的停顿
+ * 读操作不会执行 rehash, 因此 ConcurrentDict 可以在读锁下并发读取分段
+ */
+
+// minTableSize 是哈希表最小的桶个数
+const minTableSize = 4
+
+// minFillPercent 元素个数低于桶个数的这个百分比时缩容
+const minFillPercent = 10
+
+// emptyVisitsPerStep 是迁移一个桶时最多跳过的空桶个数的倍数, 避免一次 rehash 步骤耗时过长
+const emptyVisitsPerStep = 10
+
+type entry struct {
+	key  string
+	val  interface{}
+	next *entry
+}
+
+// ChainedDict 是拉链法哈希表, 不是线程安全的
+// 没有 rehash 时只使用 table[0]; rehash 期间 table[0] 中下标小于 rehashIdx 的桶已经迁移到 table[1], 新的元素写入 table[1]
+type ChainedDict struct {
+	table     [2][]*entry
+	used      [2]int
+	rehashIdx int // -1 表示没有在 rehash
+	seed      maphash.Seed
+}
+
+// MakeChained 创建 ChainedDict
+func MakeChained() *ChainedDict {
+	return &ChainedDict{
+		table:     [2][]*entry{make([]*entry, minTableSize)},
+		rehashIdx: -1,
+		seed:      maphash.MakeSeed(),
+	}
+}
+
+// hash 计算 key 的哈希值, 每个字典使用不同的种子
+func (dict *ChainedDict) hash(key string) uint64 {
+	var h maphash.Hash
+	h.SetSeed(dict.seed)
+	_, _ = h.WriteString(key)
+	return h.Sum64()
+}
+
+// isRehashing 判断是否正在 rehash
+func (dict *ChainedDict) isRehashing() bool {
+	return dict.rehashIdx >= 0
+}
+
+// find 返回 key 对应的节点, 不存在时返回 nil
+func (dict *ChainedDict) find(key string) *entry {
+	h := dict.hash(key)
+	for t := 0; t <= 1; t++ {
+		table := dict.table[t]
+		if len(table) == 0 {
+			break
+		}
+		for e := table[h&uint64(len(table)-1)]; e != nil; e = e.next {
+			if e.key == key {
+				return e
+			}
+		}
+		if !dict.isRehashing() {
+			break
+		}
+	}
+	return nil
+}
+
+// tableSizeFor 返回可以容纳 n 个元素的桶个数, 即不小于 n 的 2 的幂
+func tableSizeFor(n int) int {
+	size := minTableSize
+	for size < n {
+		size <<= 1
+	}
+	return size
+}
+
+// resize 开始将所有元素迁移到 size 个桶中, 字典为空时直接替换哈希表
+func (dict *ChainedDict) resize(size int) {
+	if dict.isRehashing() || size == len(dict.table[0]) {
+		return
+	}
+	if dict.used[0] == 0 {
+		dict.table[0] = make([]*entry, size)
+		return
+	}
+	dict.table[1] = make([]*entry, size)
+	dict.rehashIdx = 0
+}
+
+// rehash 最多迁移 n 个非空的桶, 返回是否仍然需要继续迁移
+func (dict *ChainedDict) rehash(n int) bool {
+	if !dict.isRehashing() {
+		return false
+	}
+	emptyVisits := n * emptyVisitsPerStep
+	mask := uint64(len(dict.table[1]) - 1)
+	for ; n > 0 && dict.used[0] > 0; n-- {
+		for dict.table[0][dict.rehashIdx] == nil {
+			dict.rehashIdx++
+			emptyVisits--
+			if emptyVisits == 0 {
+				return true
+			}
+		}
+		e := dict.table[0][dict.rehashIdx]
+		for e != nil {
+			next := e.next
+			i := dict.hash(e.key) & mask
+			e.next = dict.table[1][i]
+			dict.table[1][i] = e
+			dict.used[0]--
+			dict.used[1]++
+			e = next
+		}
+		dict.table[0][dict.rehashIdx] = nil
+		dict.rehashIdx++
+	}
+	if dict.used[0] > 0 {
+		return true
+	}
+	// 迁移完成, 新的哈希表成为 table[0]
+	dict.table[0], dict.used[0] = dict.table[1], dict.used[1]
+	dict.table[1], dict.used[1] = nil, 0
+	dict.rehashIdx = -1
+	return false
+}
+
+// rehashStep 在写操作时迁移一个桶
+func (dict *ChainedDict) rehashStep() {
+	dict.rehash(1)
+}
+
+// rehashBatch 是 Rehash 两次检查时间之间迁移的桶个数
+const rehashBatch = 100
+
+// Rehash 在 deadline 之前持续迁移, 返回是否执行了迁移, 供定时任务使用
+func (dict *ChainedDict) Rehash(deadline time.Time) bool {
+	if !dict.isRehashing() {
+		return false
+	}
+	for dict.rehash(rehashBatch) {
+		if !time.Now().Before(deadline) {
+			break
+		}
+	}
+	return true
+}
+
+// Get 返回 key 对应的值以及 key 是否存在
+func (dict *ChainedDict) Get(key string) (val interface{}, exists bool) {
+	e := dict.find(key)
+	if e == nil {
+		return nil, false
+	}
+	return e.val, true
+}
+
+// Len 返回字典中的元素个数
+func (dict *ChainedDict) Len() int {
+	return dict.used[0] + dict.used[1]
+}
+
+// insert 在 key 不存在时插入新的节点, 元素个数达到桶个数时扩容
+// rehash 期间不会再次扩容, 每次写操作迁移一个桶, 因此扩容引起的 rehash 在元素个数再次翻倍之前一定已经完成
+func (dict *ChainedDict) insert(key string, val interface{}) {
+	if !dict.isRehashing() && dict.used[0] >= len(dict.table[0]) {
+		dict.resize(tableSizeFor(dict.used[0] + 1))
+	}
+	t := 0
+	if dict.isRehashing() {
+		t = 1
+	}
+	table := dict.table[t]
+	i := dict.hash(key) & uint64(len(table)-1)
+	table[i] = &entry{key: key, val: val, next: table[i]}
+	dict.used[t]++
+}
+
+// Put 写入 key-value, 返回新插入的 key 的个数
+func (dict *ChainedDict) Put(key string, val interface{}) (result int) {
+	dict.rehashStep()
+	if e := dict.find(key); e != nil {
+		e.val = val
+		return 0
+	}
+	dict.insert(key, val)
+	return 1
+}
+
+// PutIfAbsent 仅当 key 不存在时写入, 返回更新的 key 的个数
+func (dict *ChainedDict) PutIfAbsent(key string, val interface{}) (result int) {
+	dict.rehashStep()
+	if dict.find(key) != nil {
+		return 0
+	}
+	dict.insert(key, val)
+	return 1
+}
+
+// PutIfExists 仅当 key 存在时写入, 返回更新的 key 的个数
+func (dict *ChainedDict) PutIfExists(key string, val interface{}) (result int) {
+	dict.rehashStep()
+	if e := dict.find(key); e != nil {
+		e.val = val
+		return 1
+	}
+	return 0
+}
+
+// Remove 删除 key, 返回删除的 key 的个数, 元素个数过少时缩容
+func (dict *ChainedDict) Remove(key string) (result int) {
+	dict.rehashStep()
+	h := dict.hash(key)
+	for t := 0; t <= 1; t++ {
+		table := dict.table[t]
+		if len(table) == 0 {
+			break
+		}
+		i := h & uint64(len(table)-1)
+		for prev, e := (*entry)(nil), table[i]; e != nil; prev, e = e, e.next {
+			if e.key != key {
+				continue
+			}
+			if prev == nil {
+				table[i] = e.next
+			} else {
+				prev.next = e.next
+			}
+			dict.used[t]--
+			size := len(dict.table[0])
+			if !dict.isRehashing() && size > minTableSize && dict.used[0]*100 < size*minFillPercent {
+				dict.resize(tableSizeFor(dict.used[0]))
+			}
+			return 1
+		}
+		if !dict.isRehashing() {
+			break
+		}
+	}
+	return 0
+}
+
+// ForEach 遍历字典, 遍历期间不能修改字典
+func (dict *ChainedDict) ForEach(consumer Consumer) {
+	for _, table := range dict.table {
+		for _, e := range table {
+			for ; e != nil; e = e.next {
+				if !consumer(e.key, e.val) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Keys 返回所有的 key
+func (dict *ChainedDict) Keys() []string {
+	result := make([]string, 0, dict.Len())
+	dict.ForEach(func(key string, val interface{}) bool {
+		result = append(result, key)
+		return true
+	})
+	return result
+}
+
+// TableSize 返回两张哈希表的桶的个数之和, 用于估算哈希表占用的内存
+func (dict *ChainedDict) TableSize() int {
+	return len(dict.table[0]) + len(dict.table[1])
+}
+
+// bucketCount 返回可能包含元素的桶的个数, rehash 期间 table[0] 中已经迁移的桶不计算在内
+func (dict *ChainedDict) bucketCount() int {
+	if !dict.isRehashing() {
+		return len(dict.table[0])
+	}
+	return len(dict.table[0]) - dict.rehashIdx + len(dict.table[1])
+}
+
+// bucketAt 返回 bucketCount 范围内第 i 个桶的链表头
+func (dict *ChainedDict) bucketAt(i int) *entry {
+	if !dict.isRehashing() {
+		return dict.table[0][i]
+	}
+	i += dict.rehashIdx
+	if i < len(dict.table[0]) {
+		return dict.table[0][i]
+	}
+	return dict.table[1][i-len(dict.table[0])]
+}
+
+// randomKey 随机选择一个非空的桶, 再从桶中随机选择一个 key, 调用者需要保证字典不为空
+// 元素个数不低于桶个数的 minFillPercent, 因此平均只需要尝试常数次
+func (dict *ChainedDict) randomKey() string {
+	var head *entry
+	for head == nil {
+		head = dict.bucketAt(rand.Intn(dict.bucketCount()))
+	}
+	n := 0
+	for e := head; e != nil; e = e.next {
+		n++
+	}
+	e := head
+	for i := rand.Intn(n); i > 0; i-- {
+		e = e.next
+	}
+	return e.key
+}
+
+// RandomKeys 随机返回 limit 个 key, 可能包含重复的 key, 字典为空时返回空切片
+// limit 可能远大于元素个数, 预分配的容量不超过元素个数
+func (dict *ChainedDict) RandomKeys(limit int) []string {
+	size := dict.Len()
+	if size == 0 {
+		return nil
+	}
+	if limit < size {
+		size = limit
+	}
+	result := make([]string, 0, size)
+	for len(result) < limit {
+		result = append(result, dict.randomKey())
+	}
+	return result
+}
+
+// RandomDistinctKeys 随机返回 limit 个不重复的 key
+// 与 redis 的 dictGetSomeKeys 相同, 从随机的桶开始依次收集连续的桶中的 key, 结果并不是均匀分布的
+func (dict *ChainedDict) RandomDistinctKeys(limit int) []string {
+	if limit >= dict.Len() {
+		return dict.Keys()
+	}
+	result := make([]string, 0, limit)
+	n := dict.bucketCount()
+	i := rand.Intn(n)
+	for len(result) < limit {
+		for e := dict.bucketAt(i); e != nil && len(result) < limit; e = e.next {
+			result = append(result, e.key)
+		}
+		i = (i + 1) % n
+	}
+	return result
+}
+
+// Clear 清空字典
+func (dict *ChainedDict) Clear() {
+	dict.table = [2][]*entry{make([]*entry, minTableSize)}
+	dict.used = [2]int{}
+	dict.rehashIdx = -1
+}
+
+// nextCursor 将不属于下标的高位置为 1, 颠倒后加 1 再颠倒回来, 即对颠倒后的下标加 1
+func nextCursor(cursor uint64, mask uint64) uint64 {
+	cursor |= ^mask
+	return bits.Reverse64(bits.Reverse64(cursor) + 1)
+}
+
+// Scan 遍历游标 cursor 对应的桶, 返回下一次遍历的游标, 返回 0 表示遍历结束
+// 游标的高位和低位颠倒之后递增, 因此扩容时已经遍历的桶分裂出的桶和缩容时合并的桶都不会被跳过, 但是可能返回重复的 key
+// rehash 期间与 redis 的 dictScan 相同, 先遍历较小的哈希表中游标对应的桶, 再遍历较大的哈希表中由这个桶分裂出的所有桶
+func (dict *ChainedDict) Scan(cursor uint64, consumer func(key string, val interface{})) uint64 {
+	if dict.Len() == 0 {
+		return 0
+	}
+	emit := func(e *entry) {
+		for ; e != nil; e = e.next {
+			consumer(e.key, e.val)
+		}
+	}
+	if !dict.isRehashing() {
+		mask := uint64(len(dict.table[0]) - 1)
+		emit(dict.table[0][cursor&mask])
+		return nextCursor(cursor, mask)
+	}
+
+	small, large := dict.table[0], dict.table[1]
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	smallMask, largeMask := uint64(len(small)-1), uint64(len(large)-1)
+	emit(small[cursor&smallMask])
+	for {
+		emit(large[cursor&largeMask])
+		cursor = nextCursor(cursor, largeMask)
+		// 较大的哈希表中由较小的哈希表的同一个桶分裂出的桶的低位相同, 只在高位上不同
+		if cursor&(smallMask^largeMask) == 0 {
+			return cursor
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package dict
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNextCursor(t *testing.T) {
+	// 8 个桶时反向二进制递增的顺序
+	expected := []uint64{4, 2, 6, 1, 5, 3, 7, 0}
+	cursor := uint64(0)
+	for i, next := range expected {
+		cursor = nextCursor(cursor, 7)
+		if cursor != next {
+			t.Fatalf("step %d: expected %d, actual %d", i, next, cursor)
+		}
+	}
+}
+
+// scanAll 使用游标遍历字典, 每次调用 Scan 之前执行 between, 返回每个 key 被返回的次数
+func scanAll(t *testing.T, d Dict, between func(step int)) map[string]int {
+	t.Helper()
+	seen := make(map[string]int)
+	cursor := uint64(0)
+	for step := 0; ; step++ {
+		if step > 1000000 {
+			t.Fatal("scan does not terminate")
+		}
+		if between != nil {
+			between(step)
+		}
+		cursor = d.Scan(cursor, func(key string, val interface{}) {
+			seen[key]++
+		})
+		if cursor == 0 {
+			return seen
+		}
+	}
+}
+
+func TestChainedScan(t *testing.T) {
+	d := MakeChained()
+	if cursor := d.Scan(0, func(string, interface{}) { t.Fatal("empty dict") }); cursor != 0 {
+		t.Fatalf("expected cursor 0, actual %d", cursor)
+	}
+	for i := 0; i < 1000; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	// 完成 rehash 之后, 没有修改时每个 key 恰好返回一次
+	d.Rehash(time.Now().Add(time.Second))
+	seen := scanAll(t, d, nil)
+	if len(seen) != 1000 {
+		t.Fatalf("expected 1000 keys, actual %d", len(seen))
+	}
+	for key, n := range seen {
+		if n != 1 {
+			t.Fatalf("key %s returned %d times", key, n)
+		}
+	}
+}
+
+func TestChainedScanWhileGrowing(t *testing.T) {
+	d := MakeChained()
+	for i := 0; i < 100; i++ {
+		d.Put("old:"+strconv.Itoa(i), i)
+	}
+	added := 0
+	seen := scanAll(t, d, func(step int) {
+		// 遍历期间插入大量元素, 哈希表多次扩容
+		for i := 0; i < 50 && added < 5000; i++ {
+			d.Put("new:"+strconv.Itoa(added), added)
+			added++
+		}
+	})
+	for i := 0; i < 100; i++ {
+		if seen["old:"+strconv.Itoa(i)] == 0 {
+			t.Fatalf("old:%d is not returned", i)
+		}
+	}
+}
+
+func TestChainedScanWhileShrinking(t *testing.T) {
+	d := MakeChained()
+	for i := 0; i < 5000; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	removed := 0
+	seen := scanAll(t, d, func(step int) {
+		// 遍历期间删除 100 之后的元素, 哈希表多次缩容
+		for i := 0; i < 50 && removed < 4900; i++ {
+			d.Remove(strconv.Itoa(100 + removed))
+			removed++
+		}
+	})
+	for i := 0; i < 100; i++ {
+		if seen[strconv.Itoa(i)] == 0 {
+			t.Fatalf("%d is not returned", i)
+		}
+	}
+	if d.Len() != 100 {
+		t.Fatalf("expected 100 keys, actual %d", d.Len())
+	}
+}
+
+// putUntilRehashing 插入元素直到开始 rehash, 返回插入的元素个数
+func putUntilRehashing(t *testing.T, d *ChainedDict, prefix string) int {
+	t.Helper()
+	for i := 0; i < 100000; i++ {
+		d.Put(prefix+strconv.Itoa(i), i)
+		if d.isRehashing() {
+			return i + 1
+		}
+	}
+	t.Fatal("rehash never starts")
+	return 0
+}
+
+func TestChainedIncrementalRehash(t *testing.T) {
+	d := MakeChained()
+	// 先完成若干次扩容, 使 rehash 需要迁移较多的桶
+	for i := 0; i < 1000; i++ {
+		d.Put("base:"+strconv.Itoa(i), i)
+	}
+	d.Rehash(time.Now().Add(time.Second))
+	n := putUntilRehashing(t, d, "k")
+	oldSize := len(d.table[0])
+	if len(d.table[1]) <= oldSize {
+		t.Fatalf("expected a larger table, old %d, new %d", oldSize, len(d.table[1]))
+	}
+
+	// 读操作不会迁移桶, rehash 期间所有的 key 都可以读取
+	rehashIdx := d.rehashIdx
+	for i := 0; i < 1000; i++ {
+		if val, ok := d.Get("base:" + strconv.Itoa(i)); !ok || val.(int) != i {
+			t.Fatalf("get base:%d during rehash: %v, %v", i, val, ok)
+		}
+	}
+	for i := 0; i < n; i++ {
+		if _, ok := d.Get("k" + strconv.Itoa(i)); !ok {
+			t.Fatalf("get k%d during rehash failed", i)
+		}
+	}
+	if d.rehashIdx != rehashIdx {
+		t.Fatal("read should not rehash")
+	}
+
+	// 每次写操作迁移一个桶
+	d.PutIfExists("missing", 0)
+	if d.rehashIdx <= rehashIdx {
+		t.Fatal("write should rehash one bucket")
+	}
+	if d.Len() != 1000+n {
+		t.Fatalf("expected %d keys, actual %d", 1000+n, d.Len())
+	}
+
+	if !d.Rehash(time.Now().Add(time.Second)) {
+		t.Fatal("Rehash should return true while rehashing")
+	}
+	if d.isRehashing() || d.table[1] != nil || d.used[1] != 0 {
+		t.Fatal("rehash should be finished")
+	}
+	if d.Rehash(time.Now().Add(time.Second)) {
+		t.Fatal("Rehash should return false when not rehashing")
+	}
+	if d.TableSize() != len(d.table[0]) || len(d.table[0]) < d.Len() {
+		t.Fatalf("wrong table size %d for %d keys", d.TableSize(), d.Len())
+	}
+}
+
+func TestChainedShrink(t *testing.T) {
+	d := MakeChained()
+	for i := 0; i < 4096; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	d.Rehash(time.Now().Add(time.Second))
+	size := len(d.table[0])
+	for i := 10; i < 4096; i++ {
+		d.Remove(strconv.Itoa(i))
+	}
+	d.Rehash(time.Now().Add(time.Second))
+	if len(d.table[0]) >= size {
+		t.Fatalf("table should shrink from %d, actual %d", size, len(d.table[0]))
+	}
+	// 缩容只在删除时检查, 缩容期间删除的元素由下一次删除触发再次缩容
+	d.Remove("9")
+	d.Rehash(time.Now().Add(time.Second))
+	if len(d.table[0]) != tableSizeFor(9) {
+		t.Fatalf("expected %d buckets, actual %d", tableSizeFor(9), len(d.table[0]))
+	}
+	for i := 0; i < 9; i++ {
+		if val, ok := d.Get(strconv.Itoa(i)); !ok || val.(int) != i {
+			t.Fatalf("get %d after shrink: %v, %v", i, val, ok)
+		}
+	}
+}
+
+// rehash 期间遍历需要同时遍历新旧两张哈希表, 不能遗漏 key
+func TestChainedScanWhileRehashing(t *testing.T) {
+	for _, grow := range []bool{true, false} {
+		d := MakeChained()
+		for i := 0; i < 2000; i++ {
+			d.Put(strconv.Itoa(i), i)
+		}
+		d.Rehash(time.Now().Add(time.Second))
+		if grow {
+			putUntilRehashing(t, d, "extra:")
+		} else {
+			for i := 100; i < 2000 && !d.isRehashing(); i++ {
+				d.Remove(strconv.Itoa(i))
+			}
+		}
+		if !d.isRehashing() {
+			t.Fatal("dict should be rehashing")
+		}
+		// 只读遍历, rehash 状态在遍历期间保持不变
+		expected := d.Keys()
+		seen := scanAll(t, d, nil)
+		for _, key := range expected {
+			if seen[key] == 0 {
+				t.Fatalf("grow %v: %s is not returned", grow, key)
+			}
+		}
+	}
+}
+
+func TestChainedRandomOps(t *testing.T) {
+	rand.Seed(1)
+	d := MakeChained()
+	model := make(map[string]int)
+	for i := 0; i < 200000; i++ {
+		key := strconv.Itoa(rand.Intn(5000))
+		switch rand.Intn(4) {
+		case 0:
+			_, exists := model[key]
+			if (d.Remove(key) == 1) != exists {
+				t.Fatalf("remove %s: expected %v", key, exists)
+			}
+			delete(model, key)
+		case 1:
+			if i%1000 == 0 {
+				d.Rehash(time.Now().Add(time.Millisecond))
+			}
+			fallthrough
+		default:
+			_, exists := model[key]
+			if (d.Put(key, i) == 1) == exists {
+				t.Fatalf("put %s: expected %v", key, !exists)
+			}
+			model[key] = i
+		}
+	}
+	if d.Len() != len(model) {
+		t.Fatalf("expected %d keys, actual %d", len(model), d.Len())
+	}
+	for key, expected := range model {
+		if val, ok := d.Get(key); !ok || val.(int) != expected {
+			t.Fatalf("get %s: expected %d, actual %v", key, expected, val)
+		}
+	}
+	if len(d.Keys()) != len(model) {
+		t.Fatalf("Keys returns %d keys", len(d.Keys()))
+	}
+}
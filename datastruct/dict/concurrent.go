@@ -0,0 +1,260 @@
+package dict
+
+import (
+	"math/bits"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * ConcurrentDict 将 key 按照哈希值分散到固定个数的分段中, 每个分段是一个带读写锁的 ChainedDict
+ * 不同分段的读写互不影响, 因此并发访问不同 key 的协程不会争用同一把锁
+ * SCAN 游标的低位是分段的下标, 高位是分段内 ChainedDict 的游标
+ */
+
+type shard struct {
+	mu sync.RWMutex
+	m  *ChainedDict
+}
+
+// ConcurrentDict 是线程安全的分段哈希表
+type ConcurrentDict struct {
+	table     []*shard
+	shardBits uint  // 分段下标在游标中占用的位数
+	count     int64 // 元素个数, 原子读写
+}
+
+// MakeConcurrent 创建 ConcurrentDict, 分段个数向上取整为 2 的幂
+func MakeConcurrent(shardCount int) *ConcurrentDict {
+	if shardCount < 1 {
+		shardCount = 1
+	}
+	shardBits := uint(bits.Len(uint(shardCount - 1)))
+	table := make([]*shard, 1<<shardBits)
+	for i := range table {
+		table[i] = &shard{m: MakeChained()}
+	}
+	return &ConcurrentDict{
+		table:     table,
+		shardBits: shardBits,
+	}
+}
+
+// fnv32 计算 key 的 FNV-1a 哈希值, 用于选择分段, 与分段内部使用的哈希函数无关
+func fnv32(key string) uint32 {
+	const prime32 = uint32(16777619)
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// getShard 返回 key 所在的分段
+func (dict *ConcurrentDict) getShard(key string) *shard {
+	return dict.table[fnv32(key)&uint32(len(dict.table)-1)]
+}
+
+// Get 返回 key 对应的值以及 key 是否存在
+func (dict *ConcurrentDict) Get(key string) (val interface{}, exists bool) {
+	s := dict.getShard(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.m.Get(key)
+}
+
+// Len 返回字典中的元素个数
+func (dict *ConcurrentDict) Len() int {
+	return int(atomic.LoadInt64(&dict.count))
+}
+
+func (dict *ConcurrentDict) addCount(delta int) {
+	atomic.AddInt64(&dict.count, int64(delta))
+}
+
+// Put 写入 key-value, 返回新插入的 key 的个数
+func (dict *ConcurrentDict) Put(key string, val interface{}) (result int) {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result = s.m.Put(key, val)
+	dict.addCount(result)
+	return result
+}
+
+// PutIfAbsent 仅当 key 不存在时写入, 返回更新的 key 的个数
+func (dict *ConcurrentDict) PutIfAbsent(key string, val interface{}) (result int) {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result = s.m.PutIfAbsent(key, val)
+	dict.addCount(result)
+	return result
+}
+
+// PutIfExists 仅当 key 存在时写入, 返回更新的 key 的个数
+func (dict *ConcurrentDict) PutIfExists(key string, val interface{}) (result int) {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.m.PutIfExists(key, val)
+}
+
+// Remove 删除 key, 返回删除的 key 的个数
+func (dict *ConcurrentDict) Remove(key string) (result int) {
+	s := dict.getShard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result = s.m.Remove(key)
+	dict.addCount(-result)
+	return result
+}
+
+// ForEach 依次遍历每个分段, consumer 在持有分段读锁的情况下调用, 因此不能修改字典
+// 遍历不是快照, 其它协程在遍历期间对尚未遍历的分段的修改是可见的
+func (dict *ConcurrentDict) ForEach(consumer Consumer) {
+	for _, s := range dict.table {
+		s.mu.RLock()
+		goOn := true
+		s.m.ForEach(func(key string, val interface{}) bool {
+			goOn = consumer(key, val)
+			return goOn
+		})
+		s.mu.RUnlock()
+		if !goOn {
+			return
+		}
+	}
+}
+
+// Keys 返回所有的 key
+func (dict *ConcurrentDict) Keys() []string {
+	result := make([]string, 0, dict.Len())
+	dict.ForEach(func(key string, val interface{}) bool {
+		result = append(result, key)
+		return true
+	})
+	return result
+}
+
+// randomKey 按照元素个数加权随机选择一个分段并从中随机选择一个 key, 使每个 key 被选中的概率相同
+// 字典为空时返回 false
+func (dict *ConcurrentDict) randomKey() (string, bool) {
+	for {
+		total := dict.Len()
+		if total <= 0 {
+			return "", false
+		}
+		r := rand.Intn(total)
+		for _, s := range dict.table {
+			s.mu.RLock()
+			n := s.m.Len()
+			if r < n {
+				keys := s.m.RandomKeys(1)
+				s.mu.RUnlock()
+				return keys[0], true
+			}
+			s.mu.RUnlock()
+			r -= n
+		}
+		// 选择期间有其它协程删除了 key, 重新选择
+	}
+}
+
+// RandomKeys 随机返回 limit 个 key, 可能包含重复的 key, 字典为空时返回空切片
+func (dict *ConcurrentDict) RandomKeys(limit int) []string {
+	result := make([]string, 0, limit)
+	for len(result) < limit {
+		key, ok := dict.randomKey()
+		if !ok {
+			break
+		}
+		result = append(result, key)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+// RandomDistinctKeys 随机返回 limit 个不重复的 key
+// 从随机的分段开始依次在连续的分段中抽样, 与 ChainedDict 相同, 结果并不是均匀分布的
+func (dict *ConcurrentDict) RandomDistinctKeys(limit int) []string {
+	if limit >= dict.Len() {
+		return dict.Keys()
+	}
+	result := make([]string, 0, limit)
+	mask := len(dict.table) - 1
+	start := rand.Intn(len(dict.table))
+	for i := 0; i < len(dict.table) && len(result) < limit; i++ {
+		s := dict.table[(start+i)&mask]
+		s.mu.RLock()
+		result = append(result, s.m.RandomDistinctKeys(limit-len(result))...)
+		s.mu.RUnlock()
+	}
+	return result
+}
+
+// Clear 清空字典
+func (dict *ConcurrentDict) Clear() {
+	for _, s := range dict.table {
+		s.mu.Lock()
+		dict.addCount(-s.m.Len())
+		s.m.Clear()
+		s.mu.Unlock()
+	}
+}
+
+// Scan 遍历游标 cursor 对应的分段中的一个桶, 返回下一次遍历的游标, 返回 0 表示遍历结束
+// 分段的个数固定, 一个分段遍历结束后跳过空的分段, 从下一个非空分段的游标 0 开始
+func (dict *ConcurrentDict) Scan(cursor uint64, consumer func(key string, val interface{})) uint64 {
+	mask := uint64(len(dict.table) - 1)
+	index := cursor & mask
+	s := dict.table[index]
+	s.mu.RLock()
+	next := s.m.Scan(cursor>>dict.shardBits, consumer)
+	s.mu.RUnlock()
+	if next != 0 {
+		return next<<dict.shardBits | index
+	}
+	for index++; index <= mask; index++ {
+		s := dict.table[index]
+		s.mu.RLock()
+		n := s.m.Len()
+		s.mu.RUnlock()
+		if n > 0 {
+			return index
+		}
+	}
+	return 0
+}
+
+// TableSize 返回所有分段的哈希表的桶的个数之和
+func (dict *ConcurrentDict) TableSize() int {
+	size := 0
+	for _, s := range dict.table {
+		s.mu.RLock()
+		size += s.m.TableSize()
+		s.mu.RUnlock()
+	}
+	return size
+}
+
+// Rehash 在 deadline 之前依次为正在 rehash 的分段迁移元素, 返回是否执行了迁移, 供定时任务使用
+func (dict *ConcurrentDict) Rehash(deadline time.Time) bool {
+	rehashed := false
+	for _, s := range dict.table {
+		if !time.Now().Before(deadline) {
+			break
+		}
+		s.mu.Lock()
+		if s.m.Rehash(deadline) {
+			rehashed = true
+		}
+		s.mu.Unlock()
+	}
+	return rehashed
+}
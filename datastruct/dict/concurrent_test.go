@@ -0,0 +1,112 @@
+package dict
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestMakeConcurrent(t *testing.T) {
+	for _, tt := range []struct{ shardCount, expected int }{{0, 1}, {1, 1}, {3, 4}, {16, 16}, {17, 32}} {
+		d := MakeConcurrent(tt.shardCount)
+		if len(d.table) != tt.expected {
+			t.Fatalf("MakeConcurrent(%d): expected %d shards, actual %d", tt.shardCount, tt.expected, len(d.table))
+		}
+	}
+}
+
+func TestConcurrentPutGetRemove(t *testing.T) {
+	d := MakeConcurrent(16)
+	const workers, keysPerWorker = 8, 1000
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < keysPerWorker; i++ {
+				key := strconv.Itoa(w) + ":" + strconv.Itoa(i)
+				if d.Put(key, i) != 1 {
+					t.Errorf("put %s should insert", key)
+				}
+				if d.PutIfAbsent(key, -1) != 0 {
+					t.Errorf("PutIfAbsent %s should not insert", key)
+				}
+				if val, ok := d.Get(key); !ok || val.(int) != i {
+					t.Errorf("get %s: %v, %v", key, val, ok)
+				}
+				// 删除一半的 key
+				if i%2 == 0 && d.Remove(key) != 1 {
+					t.Errorf("remove %s should succeed", key)
+				}
+			}
+		}(w)
+	}
+	// 并发读取不能与写入产生数据竞争
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			d.Len()
+			d.RandomKeys(5)
+			d.Scan(uint64(i), func(string, interface{}) {})
+		}
+	}()
+	wg.Wait()
+
+	if d.Len() != workers*keysPerWorker/2 {
+		t.Fatalf("expected %d keys, actual %d", workers*keysPerWorker/2, d.Len())
+	}
+	if len(d.Keys()) != d.Len() {
+		t.Fatalf("Keys returns %d keys, Len is %d", len(d.Keys()), d.Len())
+	}
+	if d.PutIfExists("0:1", 42) != 1 || d.PutIfExists("0:0", 42) != 0 {
+		t.Fatal("wrong PutIfExists result")
+	}
+	d.Clear()
+	if d.Len() != 0 {
+		t.Fatalf("expected empty dict, actual %d keys", d.Len())
+	}
+}
+
+func TestConcurrentScan(t *testing.T) {
+	d := MakeConcurrent(16)
+	for i := 0; i < 3000; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	seen := scanAll(t, d, nil)
+	if len(seen) != 3000 {
+		t.Fatalf("expected 3000 keys, actual %d", len(seen))
+	}
+
+	// 只有部分分段非空时跳过空的分段
+	d = MakeConcurrent(16)
+	d.Put("a", 1)
+	seen = scanAll(t, d, nil)
+	if len(seen) != 1 || seen["a"] == 0 {
+		t.Fatalf("wrong scan result %v", seen)
+	}
+}
+
+func TestConcurrentRandomKeys(t *testing.T) {
+	d := MakeConcurrent(16)
+	if keys := d.RandomKeys(3); len(keys) != 0 {
+		t.Fatalf("empty dict returns %v", keys)
+	}
+	for i := 0; i < 10; i++ {
+		d.Put(strconv.Itoa(i), i)
+	}
+	distinct := d.RandomDistinctKeys(20)
+	if len(distinct) != 10 {
+		t.Fatalf("expected 10 distinct keys, actual %d", len(distinct))
+	}
+	set := make(map[string]struct{})
+	for _, key := range distinct {
+		set[key] = struct{}{}
+	}
+	if len(set) != 10 {
+		t.Fatalf("duplicated keys in %v", distinct)
+	}
+	if keys := d.RandomKeys(30); len(keys) != 30 {
+		t.Fatalf("expected 30 keys, actual %d", len(keys))
+	}
+}
@@ -0,0 +1,21 @@
+package dict
+
+// Consumer 用于遍历字典, 返回 false 时停止遍历
+type Consumer func(key string, val interface{}) bool
+
+// Dict 是 key-value 数据结构的接口
+type Dict interface {
+	Get(key string) (val interface{}, exists bool)
+	Len() int
+	Put(key string, val interface{}) (result int)
+	PutIfAbsent(key string, val interface{}) (result int)
+	PutIfExists(key string, val interface{}) (result int)
+	Remove(key string) (result int)
+	ForEach(consumer Consumer)
+	Keys() []string
+	RandomKeys(limit int) []string
+	RandomDistinctKeys(limit int) []string
+	Clear()
+	// Scan 遍历游标对应的一部分元素, 返回下一次遍历的游标, 返回 0 表示遍历结束
+	Scan(cursor uint64, consumer func(key string, val interface{})) uint64
+}
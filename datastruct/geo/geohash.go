@@ -0,0 +1,255 @@
+package geo
+
+import "math"
+
+/*
+ * 与 redis 兼容的 geohash
+ * 经度和纬度分别被划分为 2^step 份, 两者的二进制位交错排列得到 geohash: 纬度在偶数位, 经度在奇数位
+ * 有序集合中使用 step 为 26 的 52 位 geohash 作为分值, 可以被 float64 精确表示
+ */
+
+// 经纬度的取值范围, 纬度受 EPSG:900913 / EPSG:3785 / OSGEO:41001 的限制
+const (
+	LongMin = -180.0
+	LongMax = 180.0
+	LatMin  = -85.05112878
+	LatMax  = 85.05112878
+)
+
+// StepMax 是有序集合中使用的精度, 2*StepMax 位的 geohash
+const StepMax = 26
+
+// Range 是一个坐标轴上的区间
+type Range struct {
+	Min, Max float64
+}
+
+// Area 是 geohash 对应的经纬度区域
+type Area struct {
+	Hash      Bits
+	Longitude Range
+	Latitude  Range
+}
+
+// Bits 是 2*Step 位的 geohash
+type Bits struct {
+	Bits uint64
+	Step uint8
+}
+
+// IsZero 判断 geohash 是否为空, 用于表示不需要搜索的区域
+func (hash Bits) IsZero() bool {
+	return hash.Bits == 0 && hash.Step == 0
+}
+
+// Neighbors 是 geohash 周围的 8 个区域
+type Neighbors struct {
+	North, East, West, South                   Bits
+	NorthEast, SouthEast, NorthWest, SouthWest Bits
+}
+
+var (
+	longRange = Range{Min: LongMin, Max: LongMax}
+	latRange  = Range{Min: LatMin, Max: LatMax}
+)
+
+/* ---- 编码和解码 ---- */
+
+// spread 将 v 的低 32 位分散到偶数位上
+func spread(v uint32) uint64 {
+	x := uint64(v)
+	x = (x | x<<16) & 0x0000ffff0000ffff
+	x = (x | x<<8) & 0x00ff00ff00ff00ff
+	x = (x | x<<4) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x<<2) & 0x3333333333333333
+	x = (x | x<<1) & 0x5555555555555555
+	return x
+}
+
+// squash 是 spread 的逆运算, 取出偶数位
+func squash(x uint64) uint32 {
+	x &= 0x5555555555555555
+	x = (x | x>>1) & 0x3333333333333333
+	x = (x | x>>2) & 0x0f0f0f0f0f0f0f0f
+	x = (x | x>>4) & 0x00ff00ff00ff00ff
+	x = (x | x>>8) & 0x0000ffff0000ffff
+	x = (x | x>>16) & 0x00000000ffffffff
+	return uint32(x)
+}
+
+// encode 在给定的经纬度区间内计算 geohash, 坐标超出范围时 ok 为 false
+func encode(longR Range, latR Range, longitude float64, latitude float64, step uint8) (hash Bits, ok bool) {
+	if step > 32 || step == 0 {
+		return Bits{}, false
+	}
+	if longitude > LongMax || longitude < LongMin || latitude > LatMax || latitude < LatMin {
+		return Bits{}, false
+	}
+	hash.Step = step
+	if latitude < latR.Min || latitude > latR.Max || longitude < longR.Min || longitude > longR.Max {
+		return hash, false
+	}
+	latOffset := (latitude - latR.Min) / (latR.Max - latR.Min)
+	longOffset := (longitude - longR.Min) / (longR.Max - longR.Min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+	hash.Bits = spread(uint32(latOffset)) | spread(uint32(longOffset))<<1
+	return hash, true
+}
+
+// Encode 计算经纬度对应的 geohash
+func Encode(longitude float64, latitude float64, step uint8) (Bits, bool) {
+	return encode(longRange, latRange, longitude, latitude, step)
+}
+
+// decode 返回 geohash 在给定的经纬度区间内对应的区域
+func decode(longR Range, latR Range, hash Bits) (Area, bool) {
+	if hash.IsZero() {
+		return Area{}, false
+	}
+	latOffset := squash(hash.Bits)
+	longOffset := squash(hash.Bits >> 1)
+	scale := float64(uint64(1) << hash.Step)
+	latScale := latR.Max - latR.Min
+	longScale := longR.Max - longR.Min
+	return Area{
+		Hash: hash,
+		Latitude: Range{
+			Min: latR.Min + (float64(latOffset)*1.0/scale)*latScale,
+			Max: latR.Min + ((float64(latOffset)+1)*1.0/scale)*latScale,
+		},
+		Longitude: Range{
+			Min: longR.Min + (float64(longOffset)*1.0/scale)*longScale,
+			Max: longR.Min + ((float64(longOffset)+1)*1.0/scale)*longScale,
+		},
+	}, true
+}
+
+// Decode 返回 geohash 对应的区域
+func Decode(hash Bits) (Area, bool) {
+	return decode(longRange, latRange, hash)
+}
+
+// Center 返回区域中心的经纬度
+func (area *Area) Center() (longitude float64, latitude float64) {
+	longitude = (area.Longitude.Min + area.Longitude.Max) / 2
+	longitude = math.Min(math.Max(longitude, LongMin), LongMax)
+	latitude = (area.Latitude.Min + area.Latitude.Max) / 2
+	latitude = math.Min(math.Max(latitude, LatMin), LatMax)
+	return longitude, latitude
+}
+
+// EncodeScore 计算经纬度对应的有序集合分值
+func EncodeScore(longitude float64, latitude float64) (float64, bool) {
+	hash, ok := Encode(longitude, latitude, StepMax)
+	if !ok {
+		return 0, false
+	}
+	return float64(hash.align52Bits()), true
+}
+
+// DecodeScore 返回有序集合分值对应的经纬度
+func DecodeScore(score float64) (longitude float64, latitude float64, ok bool) {
+	area, ok := Decode(Bits{Bits: uint64(score), Step: StepMax})
+	if !ok {
+		return 0, 0, false
+	}
+	longitude, latitude = area.Center()
+	return longitude, latitude, true
+}
+
+// align52Bits 将 geohash 左对齐到 52 位
+func (hash Bits) align52Bits() uint64 {
+	return hash.Bits << (52 - uint(hash.Step)*2)
+}
+
+// ScoreRange 返回 geohash 对应区域内的点的分值范围 [min, max)
+func (hash Bits) ScoreRange() (min float64, max float64) {
+	min = float64(hash.align52Bits())
+	hash.Bits++
+	max = float64(hash.align52Bits())
+	return min, max
+}
+
+// base32 是标准 geohash 使用的字符表
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// StandardHash 返回有序集合分值对应的 11 个字符的标准 geohash 字符串
+// 标准 geohash 的纬度范围是 [-90, 90], 需要重新编码; 52 位只能表示 10 个字符, 最后一个字符固定为 0
+func StandardHash(score float64) (string, bool) {
+	longitude, latitude, ok := DecodeScore(score)
+	if !ok {
+		return "", false
+	}
+	hash, ok := encode(Range{Min: -180, Max: 180}, Range{Min: -90, Max: 90}, longitude, latitude, StepMax)
+	if !ok {
+		return "", false
+	}
+	buf := make([]byte, 11)
+	for i := range buf {
+		idx := 0
+		if i < 10 {
+			idx = int(hash.Bits>>(52-uint(i+1)*5)) & 0x1f
+		}
+		buf[i] = base32[idx]
+	}
+	return string(buf), true
+}
+
+/* ---- 相邻区域 ---- */
+
+// moveX 沿经度方向移动 d 个区域
+func (hash *Bits) moveX(d int) {
+	if d == 0 {
+		return
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= 0xaaaaaaaaaaaaaaaa >> (64 - uint(hash.Step)*2)
+	hash.Bits = x | y
+}
+
+// moveY 沿纬度方向移动 d 个区域
+func (hash *Bits) moveY(d int) {
+	if d == 0 {
+		return
+	}
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - uint(hash.Step)*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= 0x5555555555555555 >> (64 - uint(hash.Step)*2)
+	hash.Bits = x | y
+}
+
+func (hash Bits) move(dx int, dy int) Bits {
+	hash.moveX(dx)
+	hash.moveY(dy)
+	return hash
+}
+
+// neighbors 返回周围的 8 个区域
+func (hash Bits) neighbors() Neighbors {
+	return Neighbors{
+		East:      hash.move(1, 0),
+		West:      hash.move(-1, 0),
+		South:     hash.move(0, -1),
+		North:     hash.move(0, 1),
+		NorthWest: hash.move(-1, 1),
+		SouthWest: hash.move(-1, -1),
+		NorthEast: hash.move(1, 1),
+		SouthEast: hash.move(1, -1),
+	}
+}
@@ -0,0 +1,179 @@
+package geo
+
+import "math"
+
+/*
+ * 按照半径或者矩形搜索时需要扫描的区域以及距离计算
+ */
+
+// EarthRadius 是地球半径, 单位为米, 与 redis 相同
+const EarthRadius = 6372797.560856
+
+// mercatorMax 是墨卡托投影的最大范围, 单位为米
+const mercatorMax = 20037726.37
+
+func degRad(ang float64) float64 {
+	return ang * (math.Pi / 180.0)
+}
+
+func radDeg(ang float64) float64 {
+	return ang / (math.Pi / 180.0)
+}
+
+// latDistance 返回经度相同的两个点之间的距离, 单位为米
+func latDistance(lat1 float64, lat2 float64) float64 {
+	return EarthRadius * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// Distance 使用 haversine 公式计算两个点之间的距离, 单位为米
+func Distance(long1 float64, lat1 float64, long2 float64, lat2 float64) float64 {
+	long1r := degRad(long1)
+	long2r := degRad(long2)
+	v := math.Sin((long2r - long1r) / 2)
+	// 经度相同时只需要计算纬度方向上的距离
+	if v == 0 {
+		return latDistance(lat1, lat2)
+	}
+	lat1r := degRad(lat1)
+	lat2r := degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2.0 * EarthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Shape 是搜索的范围, 以 (Longitude, Latitude) 为中心的圆或者矩形, 长度的单位为米
+type Shape struct {
+	Longitude float64
+	Latitude  float64
+	IsBox     bool
+	Radius    float64 // 圆的半径
+	Width     float64 // 矩形的宽度, 沿经度方向
+	Height    float64 // 矩形的高度, 沿纬度方向
+}
+
+// Contains 判断点是否在范围内, 在范围内时返回点到中心的距离
+func (shape *Shape) Contains(longitude float64, latitude float64) (float64, bool) {
+	if !shape.IsBox {
+		distance := Distance(shape.Longitude, shape.Latitude, longitude, latitude)
+		return distance, distance <= shape.Radius
+	}
+	// 纬度方向上的距离计算较快, 先检查
+	if latDistance(latitude, shape.Latitude) > shape.Height/2 {
+		return 0, false
+	}
+	if Distance(longitude, latitude, shape.Longitude, latitude) > shape.Width/2 {
+		return 0, false
+	}
+	return Distance(shape.Longitude, shape.Latitude, longitude, latitude), true
+}
+
+// boundingBox 返回包含范围的经纬度矩形
+func (shape *Shape) boundingBox() (minLong float64, minLat float64, maxLong float64, maxLat float64) {
+	height, width := shape.Radius, shape.Radius
+	if shape.IsBox {
+		height, width = shape.Height/2, shape.Width/2
+	}
+	latDelta := radDeg(height / EarthRadius)
+	longDeltaTop := radDeg(width / EarthRadius / math.Cos(degRad(shape.Latitude+latDelta)))
+	longDeltaBottom := radDeg(width / EarthRadius / math.Cos(degRad(shape.Latitude-latDelta)))
+	// 南北半球的方向相反, 选择不同的点作为经度的边界
+	if shape.Latitude < 0 {
+		minLong, maxLong = shape.Longitude-longDeltaBottom, shape.Longitude+longDeltaBottom
+	} else {
+		minLong, maxLong = shape.Longitude-longDeltaTop, shape.Longitude+longDeltaTop
+	}
+	return minLong, shape.Latitude - latDelta, maxLong, shape.Latitude + latDelta
+}
+
+// estimateSteps 根据搜索半径估算 geohash 的精度, 使得一个区域的大小不小于搜索半径
+func estimateSteps(rangeMeters float64, latitude float64) uint8 {
+	if rangeMeters == 0 {
+		return StepMax
+	}
+	step := 1
+	for rangeMeters < mercatorMax {
+		rangeMeters *= 2
+		step++
+	}
+	step -= 2 // 保证大多数情况下范围被包含在内
+	// 越靠近两极经线越密集, 需要更大的区域
+	if latitude > 66 || latitude < -66 {
+		step--
+		if latitude > 80 || latitude < -80 {
+			step--
+		}
+	}
+	if step < 1 {
+		step = 1
+	}
+	if step > StepMax {
+		step = StepMax
+	}
+	return uint8(step)
+}
+
+// SearchAreas 返回需要扫描的 geohash 区域: 中心所在的区域以及周围的 8 个区域, 去掉与范围不相交的区域和重复的区域
+// 返回的顺序为 中心、北、南、东、西、东北、西北、东南、西南
+func (shape *Shape) SearchAreas() []Bits {
+	minLong, minLat, maxLong, maxLat := shape.boundingBox()
+	radius := shape.Radius
+	if shape.IsBox {
+		// 中心到矩形顶点的距离
+		radius = math.Sqrt((shape.Width/2)*(shape.Width/2) + (shape.Height/2)*(shape.Height/2))
+	}
+	steps := estimateSteps(radius, shape.Latitude)
+	hash, _ := Encode(shape.Longitude, shape.Latitude, steps)
+	neighbors := hash.neighbors()
+	area, _ := Decode(hash)
+
+	// 搜索范围靠近区域的边界时, 相邻区域可能无法覆盖整个范围, 需要降低精度
+	north, _ := Decode(neighbors.North)
+	south, _ := Decode(neighbors.South)
+	east, _ := Decode(neighbors.East)
+	west, _ := Decode(neighbors.West)
+	decreaseStep := north.Latitude.Max < maxLat || south.Latitude.Min > minLat ||
+		east.Longitude.Max < maxLong || west.Longitude.Min > minLong
+	if steps > 1 && decreaseStep {
+		steps--
+		hash, _ = Encode(shape.Longitude, shape.Latitude, steps)
+		neighbors = hash.neighbors()
+		area, _ = Decode(hash)
+	}
+
+	// 去掉与搜索范围不相交的区域
+	if steps >= 2 {
+		if area.Latitude.Min < minLat {
+			neighbors.South, neighbors.SouthWest, neighbors.SouthEast = Bits{}, Bits{}, Bits{}
+		}
+		if area.Latitude.Max > maxLat {
+			neighbors.North, neighbors.NorthEast, neighbors.NorthWest = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Min < minLong {
+			neighbors.West, neighbors.SouthWest, neighbors.NorthWest = Bits{}, Bits{}, Bits{}
+		}
+		if area.Longitude.Max > maxLong {
+			neighbors.East, neighbors.SouthEast, neighbors.NorthEast = Bits{}, Bits{}, Bits{}
+		}
+	}
+
+	candidates := []Bits{
+		hash,
+		neighbors.North, neighbors.South, neighbors.East, neighbors.West,
+		neighbors.NorthEast, neighbors.NorthWest, neighbors.SouthEast, neighbors.SouthWest,
+	}
+	result := make([]Bits, 0, len(candidates))
+	lastProcessed := 0
+	for i, candidate := range candidates {
+		if candidate.IsZero() {
+			continue
+		}
+		// 半径很大时相邻的区域可能相同, 跳过与上一个扫描的区域相同的区域
+		// 与 redis 一致, 上一个区域是中心区域时不比较
+		if lastProcessed > 0 && candidate == candidates[lastProcessed] {
+			continue
+		}
+		result = append(result, candidate)
+		lastProcessed = i
+	}
+	return result
+}
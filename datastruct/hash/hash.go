@@ -0,0 +1,360 @@
+package hash
+
+import (
+	"encoding/binary"
+	"math/rand"
+
+	"github.com/LynchQ/my-go-redis/datastruct/dict"
+)
+
+/*
+ * Hash 有两种编码:
+ * listpack: 所有 field 和 value 依次紧凑地存储在一个字节切片中, 每一项由 uvarint 长度和内容组成
+ *           查找需要遍历, 适合元素较少的哈希表, 可以节省大量的指针和 map 的开销
+ * hashtable: 使用 dict.ChainedDict 存储, 元素较多或者元素较长时使用, 支持使用游标遍历
+ * 编码只会从 listpack 转换为 hashtable, 何时转换由调用者决定
+ * 两种编码都可以为 field 设置过期时间, 过期时间单独存储, 只有设置过过期时间的哈希表才会分配
+ */
+
+// 编码名称, 与 OBJECT ENCODING 的输出一致
+const (
+	EncodingListpack  = "listpack"
+	EncodingHashtable = "hashtable"
+)
+
+// Consumer 用于遍历哈希表, 返回 false 时停止遍历
+type Consumer func(field string, value string) bool
+
+// Hash 是 redis 的哈希表类型, 不是线程安全的
+type Hash struct {
+	lp []byte            // listpack 编码的数据, 使用 hashtable 编码时为 nil
+	n  int               // listpack 中 field 的个数
+	m  *dict.ChainedDict // hashtable 编码的数据, field -> value string, 使用 listpack 编码时为 nil
+
+	expires    map[string]int64 // field -> 过期时间的 unix 毫秒时间戳, 没有 field 设置过期时间时为 nil
+	nextExpire int64            // 最早的过期时间, 可能早于实际值, 为 0 表示没有过期时间
+}
+
+// Make 创建一个 listpack 编码的空哈希表
+func Make() *Hash {
+	return &Hash{lp: []byte{}}
+}
+
+// Encoding 返回当前的编码
+func (h *Hash) Encoding() string {
+	if h.m != nil {
+		return EncodingHashtable
+	}
+	return EncodingListpack
+}
+
+// IsCompact 判断是否使用 listpack 编码
+func (h *Hash) IsCompact() bool {
+	return h.m == nil
+}
+
+// Convert 转换为 hashtable 编码
+func (h *Hash) Convert() {
+	if h.m != nil {
+		return
+	}
+	m := dict.MakeChained()
+	h.ForEach(func(field string, value string) bool {
+		m.Put(field, value)
+		return true
+	})
+	h.m, h.lp, h.n = m, nil, 0
+}
+
+// Len 返回 field 的个数
+func (h *Hash) Len() int {
+	if h.m != nil {
+		return h.m.Len()
+	}
+	return h.n
+}
+
+// Copy 返回哈希表的副本, 使用相同的编码, 保留 field 的过期时间
+func (h *Hash) Copy() *Hash {
+	result := &Hash{n: h.n, nextExpire: h.nextExpire}
+	if h.m != nil {
+		result.m = dict.MakeChained()
+		h.m.ForEach(func(field string, value interface{}) bool {
+			result.m.Put(field, value)
+			return true
+		})
+	} else {
+		result.lp = append([]byte{}, h.lp...)
+	}
+	if h.expires != nil {
+		result.expires = make(map[string]int64, len(h.expires))
+		for field, expireAt := range h.expires {
+			result.expires[field] = expireAt
+		}
+	}
+	return result
+}
+
+// Get 返回 field 对应的 value
+func (h *Hash) Get(field string) (string, bool) {
+	if h.m != nil {
+		value, ok := h.m.Get(field)
+		if !ok {
+			return "", false
+		}
+		return value.(string), true
+	}
+	_, valueAt, end, ok := h.find(field)
+	if !ok {
+		return "", false
+	}
+	value, _ := readEntry(h.lp[valueAt:end])
+	return string(value), true
+}
+
+// Set 写入 field 并清除它的过期时间, 返回 field 是否是新插入的
+func (h *Hash) Set(field string, value string) bool {
+	h.Persist(field)
+	return h.SetKeepTTL(field, value)
+}
+
+// SetKeepTTL 写入 field 并保留它的过期时间, 返回 field 是否是新插入的
+func (h *Hash) SetKeepTTL(field string, value string) bool {
+	if h.m != nil {
+		return h.m.Put(field, value) > 0
+	}
+	_, valueAt, end, ok := h.find(field)
+	if !ok {
+		h.lp = appendEntry(h.lp, field)
+		h.lp = appendEntry(h.lp, value)
+		h.n++
+		return true
+	}
+	// 替换 value, 后面的数据需要整体移动
+	rest := h.lp[end:]
+	lp := make([]byte, 0, valueAt+binary.MaxVarintLen64+len(value)+len(rest))
+	lp = append(lp, h.lp[:valueAt]...)
+	lp = appendEntry(lp, value)
+	h.lp = append(lp, rest...)
+	return false
+}
+
+// Remove 删除 field, 返回 field 是否存在
+func (h *Hash) Remove(field string) bool {
+	h.Persist(field)
+	if h.m != nil {
+		return h.m.Remove(field) > 0
+	}
+	start, _, end, ok := h.find(field)
+	if !ok {
+		return false
+	}
+	h.lp = append(h.lp[:start], h.lp[end:]...)
+	h.n--
+	return true
+}
+
+// ForEach 遍历哈希表, listpack 编码时按照插入顺序遍历
+func (h *Hash) ForEach(consumer Consumer) {
+	if h.m != nil {
+		h.m.ForEach(func(field string, value interface{}) bool {
+			return consumer(field, value.(string))
+		})
+		return
+	}
+	for pos := 0; pos < len(h.lp); {
+		field, n := readEntry(h.lp[pos:])
+		pos += n
+		value, n := readEntry(h.lp[pos:])
+		pos += n
+		if !consumer(string(field), string(value)) {
+			return
+		}
+	}
+}
+
+// Scan 使用游标遍历哈希表, 返回下一次遍历的游标, 返回 0 表示遍历结束
+// listpack 编码时一次遍历所有元素
+func (h *Hash) Scan(cursor uint64, consumer func(field string, value string)) uint64 {
+	if h.m != nil {
+		return h.m.Scan(cursor, func(field string, value interface{}) {
+			consumer(field, value.(string))
+		})
+	}
+	h.ForEach(func(field string, value string) bool {
+		consumer(field, value)
+		return true
+	})
+	return 0
+}
+
+// Pair 是一对 field 和 value
+type Pair struct {
+	Field string
+	Value string
+}
+
+// Pairs 返回所有的 field 和 value
+func (h *Hash) Pairs() []Pair {
+	result := make([]Pair, 0, h.Len())
+	h.ForEach(func(field string, value string) bool {
+		result = append(result, Pair{Field: field, Value: value})
+		return true
+	})
+	return result
+}
+
+// RandomField 随机返回一个 field, 哈希表为空时返回空字符串
+// hashtable 编码时直接从字典中抽样, 不需要复制所有的元素
+func (h *Hash) RandomField() string {
+	if h.m != nil {
+		if h.m.Len() == 0 {
+			return ""
+		}
+		return h.m.RandomKeys(1)[0]
+	}
+	if h.n == 0 {
+		return ""
+	}
+	i := rand.Intn(h.n)
+	var result string
+	h.ForEach(func(field string, value string) bool {
+		if i == 0 {
+			result = field
+			return false
+		}
+		i--
+		return true
+	})
+	return result
+}
+
+// RandomPairs 随机返回 limit 个元素, 可能包含重复的元素
+// limit 可能远大于元素个数, 预分配的容量不超过元素个数
+func (h *Hash) RandomPairs(limit int) []Pair {
+	size := h.Len()
+	if size == 0 {
+		return nil
+	}
+	if limit < size {
+		size = limit
+	}
+	result := make([]Pair, 0, size)
+	if h.m != nil {
+		for _, field := range h.m.RandomKeys(limit) {
+			value, _ := h.m.Get(field)
+			result = append(result, Pair{Field: field, Value: value.(string)})
+		}
+		return result
+	}
+	all := h.Pairs()
+	for len(result) < limit {
+		result = append(result, all[rand.Intn(len(all))])
+	}
+	return result
+}
+
+// RandomDistinctPairs 随机返回最多 limit 个不重复的元素
+func (h *Hash) RandomDistinctPairs(limit int) []Pair {
+	all := h.Pairs()
+	if limit >= len(all) {
+		return all
+	}
+	// 部分 Fisher-Yates 洗牌
+	for i := 0; i < limit; i++ {
+		j := i + rand.Intn(len(all)-i)
+		all[i], all[j] = all[j], all[i]
+	}
+	return all[:limit]
+}
+
+/* ---- 过期时间 ---- */
+
+// SetExpire 设置 field 的过期时间, 调用者需要保证 field 存在
+func (h *Hash) SetExpire(field string, expireAt int64) {
+	if h.expires == nil {
+		h.expires = make(map[string]int64)
+	}
+	h.expires[field] = expireAt
+	if h.nextExpire == 0 || expireAt < h.nextExpire {
+		h.nextExpire = expireAt
+	}
+}
+
+// GetExpire 返回 field 的过期时间, 没有设置过期时间时 ok 为 false
+func (h *Hash) GetExpire(field string) (expireAt int64, ok bool) {
+	expireAt, ok = h.expires[field]
+	return
+}
+
+// Persist 清除 field 的过期时间, 返回 field 是否设置了过期时间
+func (h *Hash) Persist(field string) bool {
+	if _, ok := h.expires[field]; !ok {
+		return false
+	}
+	delete(h.expires, field)
+	if len(h.expires) == 0 {
+		h.expires, h.nextExpire = nil, 0
+	}
+	return true
+}
+
+// HasExpires 判断是否有 field 设置了过期时间
+func (h *Hash) HasExpires() bool {
+	return len(h.expires) > 0
+}
+
+// RemoveExpired 删除过期时间不晚于 now 的 field, 返回删除的个数
+// 没有 field 到期时不需要遍历
+func (h *Hash) RemoveExpired(now int64) int {
+	if h.nextExpire == 0 || now < h.nextExpire {
+		return 0
+	}
+	var expired []string
+	var next int64
+	for field, expireAt := range h.expires {
+		if expireAt <= now {
+			expired = append(expired, field)
+		} else if next == 0 || expireAt < next {
+			next = expireAt
+		}
+	}
+	for _, field := range expired {
+		h.Remove(field)
+	}
+	if h.expires != nil {
+		h.nextExpire = next
+	}
+	return len(expired)
+}
+
+/* ---- listpack ---- */
+
+// find 在 listpack 中查找 field, 返回 field 的起始位置、value 的起始位置以及 value 的结束位置
+func (h *Hash) find(field string) (start int, valueAt int, end int, found bool) {
+	for pos := 0; pos < len(h.lp); {
+		f, n := readEntry(h.lp[pos:])
+		valueAt = pos + n
+		_, m := readEntry(h.lp[valueAt:])
+		end = valueAt + m
+		if string(f) == field {
+			return pos, valueAt, end, true
+		}
+		pos = end
+	}
+	return 0, 0, 0, false
+}
+
+// appendEntry 在 lp 末尾追加一项
+func appendEntry(lp []byte, s string) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(s)))
+	lp = append(lp, buf[:n]...)
+	return append(lp, s...)
+}
+
+// readEntry 读取 b 开头的一项, 返回内容以及这一项占用的字节数
+func readEntry(b []byte) ([]byte, int) {
+	size, n := binary.Uvarint(b)
+	return b[n : n+int(size)], n + int(size)
+}
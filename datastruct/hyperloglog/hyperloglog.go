@@ -0,0 +1,580 @@
+package hyperloglog
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+/*
+ * 与 redis 字节兼容的 HyperLogLog, 以字符串的形式存储
+ *
+ * 头部 16 字节:
+ * +------+---+-----+----------+
+ * | HYLL | E | N/U | Cardin.  |
+ * +------+---+-----+----------+
+ * 4 字节的魔数 "HYLL", 1 字节的编码, 3 字节未使用, 8 字节小端序的基数缓存, 最高位为 1 表示缓存失效
+ *
+ * 稠密编码: 16384 个 6 位的寄存器, 从每个字节的低位开始存储, 共 12288 字节
+ * 稀疏编码: 对寄存器进行游程编码, 有三种操作码:
+ *   ZERO  00xxxxxx           连续 xxxxxx+1 个寄存器为 0, 最多 64 个
+ *   XZERO 01xxxxxx yyyyyyyy  连续 xxxxxxyyyyyyyy+1 个寄存器为 0, 最多 16384 个
+ *   VAL   1vvvvvxx           连续 xx+1 个寄存器的值为 vvvvv+1, 值最大为 32, 最多 4 个
+ * 稀疏编码的长度超过 sparseMaxBytes 或者寄存器的值超过 32 时转换为稠密编码
+ */
+
+const (
+	precision     = 14
+	registerCount = 1 << precision // 寄存器个数
+	indexMask     = registerCount - 1
+	registerBits  = 6
+	registerMax   = 1<<registerBits - 1
+	// 哈希值中用于计算连续 0 个数的位数
+	hashBits = 64 - precision
+
+	headerSize = 16
+	denseSize  = headerSize + (registerCount*registerBits+7)/8
+
+	encodingDense  = 0
+	encodingSparse = 1
+
+	sparseValMaxValue = 32
+	sparseValMaxLen   = 4
+	sparseZeroMaxLen  = 64
+	sparseXZeroMaxLen = 16384
+
+	alphaInf = 0.721347520444481703680 // 0.5/ln(2)
+)
+
+// 编码名称, 与 PFDEBUG ENCODING 的输出一致
+const (
+	EncodingDense  = "dense"
+	EncodingSparse = "sparse"
+)
+
+// ErrCorrupted 表示 HyperLogLog 的数据已经损坏
+var ErrCorrupted = errors.New("INVALIDOBJ Corrupted HLL object detected")
+
+var magic = []byte("HYLL")
+
+/* ---- 头部 ---- */
+
+// New 返回空的 HyperLogLog, 使用稀疏编码
+func New() []byte {
+	b := make([]byte, headerSize, headerSize+2)
+	copy(b, magic)
+	b[4] = encodingSparse
+	return appendXZero(b, registerCount)
+}
+
+// IsValid 判断字符串是否是合法的 HyperLogLog, 只检查头部和长度, 不检查稀疏编码的内容
+func IsValid(b []byte) bool {
+	if len(b) < headerSize || string(b[:4]) != string(magic) {
+		return false
+	}
+	switch b[4] {
+	case encodingDense:
+		return len(b) == denseSize
+	case encodingSparse:
+		return true
+	}
+	return false
+}
+
+// Encoding 返回编码名称, 调用者需要保证 b 是合法的 HyperLogLog
+func Encoding(b []byte) string {
+	if b[4] == encodingDense {
+		return EncodingDense
+	}
+	return EncodingSparse
+}
+
+// CachedCount 返回缓存的基数, 缓存失效时 ok 为 false
+func CachedCount(b []byte) (card uint64, ok bool) {
+	if b[15]&0x80 != 0 {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint64(b[8:headerSize]), true
+}
+
+// SetCachedCount 缓存基数
+func SetCachedCount(b []byte, card uint64) {
+	binary.LittleEndian.PutUint64(b[8:headerSize], card)
+}
+
+// invalidateCache 使缓存的基数失效
+func invalidateCache(b []byte) {
+	b[15] |= 0x80
+}
+
+/* ---- 哈希 ---- */
+
+// murmurHash64A 是 redis 使用的 64 位 MurmurHash2, 按照小端序读取数据
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+	n := len(key) &^ 7
+	for i := 0; i < n; i += 8 {
+		k := binary.LittleEndian.Uint64(key[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	tail := key[n:]
+	switch len(tail) {
+	case 7:
+		h ^= uint64(tail[6]) << 48
+		fallthrough
+	case 6:
+		h ^= uint64(tail[5]) << 40
+		fallthrough
+	case 5:
+		h ^= uint64(tail[4]) << 32
+		fallthrough
+	case 4:
+		h ^= uint64(tail[3]) << 24
+		fallthrough
+	case 3:
+		h ^= uint64(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint64(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint64(tail[0])
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// patLen 返回元素对应的寄存器, 以及哈希值剩余部分中从低位开始第一个 1 的位置(从 1 开始计数)
+func patLen(element []byte) (index int, count uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index = int(hash & indexMask)
+	hash >>= precision
+	// 保证循环能够结束, 此时 count 最大为 hashBits+1
+	hash |= 1 << hashBits
+	count = 1
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+/* ---- 稠密编码 ---- */
+
+// denseGet 返回稠密编码中第 index 个寄存器的值, registers 不包括头部
+func denseGet(registers []byte, index int) uint8 {
+	bytePos := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	b0 := uint(registers[bytePos])
+	var b1 uint
+	if bytePos+1 < len(registers) {
+		b1 = uint(registers[bytePos+1])
+	}
+	return uint8((b0>>fb | b1<<(8-fb)) & registerMax)
+}
+
+// denseSet 设置稠密编码中第 index 个寄存器的值
+func denseSet(registers []byte, index int, val uint8) {
+	bytePos := index * registerBits / 8
+	fb := uint(index * registerBits & 7)
+	v := uint(val)
+	registers[bytePos] &^= byte(registerMax << fb)
+	registers[bytePos] |= byte(v << fb)
+	if bytePos+1 < len(registers) {
+		registers[bytePos+1] &^= byte(registerMax >> (8 - fb))
+		registers[bytePos+1] |= byte(v >> (8 - fb))
+	}
+}
+
+// denseUpdate 在 count 大于寄存器的值时更新寄存器, 返回是否更新
+func denseUpdate(registers []byte, index int, count uint8) bool {
+	if denseGet(registers, index) >= count {
+		return false
+	}
+	denseSet(registers, index, count)
+	return true
+}
+
+/* ---- 稀疏编码 ---- */
+
+func isZero(op byte) bool {
+	return op&0xc0 == 0
+}
+
+func isXZero(op byte) bool {
+	return op&0xc0 == 0x40
+}
+
+func zeroLen(op byte) int {
+	return int(op&0x3f) + 1
+}
+
+func xzeroLen(op0 byte, op1 byte) int {
+	return (int(op0&0x3f)<<8 | int(op1)) + 1
+}
+
+func valValue(op byte) uint8 {
+	return (op>>2)&0x1f + 1
+}
+
+func valLen(op byte) int {
+	return int(op&0x3) + 1
+}
+
+func makeVal(val uint8, length int) byte {
+	return 0x80 | (val-1)<<2 | byte(length-1)
+}
+
+func appendZero(b []byte, length int) []byte {
+	return append(b, byte(length-1))
+}
+
+func appendXZero(b []byte, length int) []byte {
+	length--
+	return append(b, 0x40|byte(length>>8), byte(length))
+}
+
+// appendZeros 追加表示 length 个 0 的操作码, length 较大时使用 XZERO
+func appendZeros(b []byte, length int) []byte {
+	if length > sparseZeroMaxLen {
+		return appendXZero(b, length)
+	}
+	return appendZero(b, length)
+}
+
+// opSpan 返回操作码覆盖的寄存器个数和操作码的长度
+func opSpan(ops []byte, p int) (span int, opLen int) {
+	switch op := ops[p]; {
+	case isZero(op):
+		return zeroLen(op), 1
+	case isXZero(op):
+		if p+1 >= len(ops) {
+			return 0, 2
+		}
+		return xzeroLen(op, ops[p+1]), 2
+	default:
+		return valLen(op), 1
+	}
+}
+
+// sparseForEach 按照寄存器的顺序遍历稀疏编码中的每个操作码, fn 的参数为起始寄存器、寄存器个数和值
+// 操作码覆盖的寄存器总数不等于 registerCount 时返回 ErrCorrupted
+func sparseForEach(ops []byte, fn func(first int, span int, val uint8)) error {
+	index := 0
+	for p := 0; p < len(ops); {
+		span, opLen := opSpan(ops, p)
+		if span == 0 {
+			return ErrCorrupted
+		}
+		var val uint8
+		if !isZero(ops[p]) && !isXZero(ops[p]) {
+			val = valValue(ops[p])
+			if index+span > registerCount {
+				return ErrCorrupted
+			}
+		}
+		fn(index, span, val)
+		index += span
+		p += opLen
+	}
+	if index != registerCount {
+		return ErrCorrupted
+	}
+	return nil
+}
+
+// sparseToDense 将稀疏编码转换为稠密编码, 保留基数缓存
+func sparseToDense(b []byte) ([]byte, error) {
+	dense := make([]byte, denseSize)
+	copy(dense, b[:headerSize])
+	dense[4] = encodingDense
+	registers := dense[headerSize:]
+	err := sparseForEach(b[headerSize:], func(first int, span int, val uint8) {
+		if val == 0 {
+			return
+		}
+		for i := first; i < first+span; i++ {
+			denseSet(registers, i, val)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dense, nil
+}
+
+// sparseSet 在 count 大于寄存器的值时更新稀疏编码中的寄存器, 返回新的字符串以及是否更新
+// 需要时转换为稠密编码
+func sparseSet(b []byte, index int, count uint8, sparseMaxBytes int) ([]byte, bool, error) {
+	if count > sparseValMaxValue {
+		return promote(b, index, count)
+	}
+
+	// 第一步: 找到覆盖寄存器的操作码
+	ops := b[headerSize:]
+	first, span, opLen := 0, 0, 0
+	p, prev := 0, -1
+	for p < len(ops) {
+		span, opLen = opSpan(ops, p)
+		if index <= first+span-1 {
+			break
+		}
+		prev = p
+		p += opLen
+		first += span
+	}
+	if span == 0 || p >= len(ops) {
+		return nil, false, ErrCorrupted
+	}
+	op := ops[p]
+
+	// 第二步: 修改操作码
+	// 值已经不小于 count 时不需要修改; 只覆盖一个寄存器的 VAL 或 ZERO 直接替换为新的 VAL
+	// 其它情况需要将操作码拆分为最多三个操作码, 例如 XZERO 拆分为 XZERO-VAL-XZERO
+	updatedInPlace := false
+	switch {
+	case !isZero(op) && !isXZero(op):
+		if valValue(op) >= count {
+			return b, false, nil
+		}
+		if span == 1 {
+			ops[p] = makeVal(count, 1)
+			updatedInPlace = true
+		}
+	case isZero(op) && span == 1:
+		ops[p] = makeVal(count, 1)
+		updatedInPlace = true
+	}
+
+	if !updatedInPlace {
+		last := first + span - 1
+		seq := make([]byte, 0, 5)
+		if isZero(op) || isXZero(op) {
+			if index != first {
+				seq = appendZeros(seq, index-first)
+			}
+			seq = append(seq, makeVal(count, 1))
+			if index != last {
+				seq = appendZeros(seq, last-index)
+			}
+		} else {
+			val := valValue(op)
+			if index != first {
+				seq = append(seq, makeVal(val, index-first))
+			}
+			seq = append(seq, makeVal(count, 1))
+			if index != last {
+				seq = append(seq, makeVal(val, last-index))
+			}
+		}
+
+		// 第三步: 用新的操作码序列替换原来的操作码
+		delta := len(seq) - opLen
+		if delta > 0 && len(ops)+delta > sparseMaxBytes {
+			return promote(b, index, count)
+		}
+		next := p + opLen
+		result := make([]byte, 0, len(b)+delta)
+		result = append(result, b[:headerSize+p]...)
+		result = append(result, seq...)
+		result = append(result, ops[next:]...)
+		b = result
+		ops = b[headerSize:]
+	}
+
+	// 第四步: 从前一个操作码开始, 合并最多 5 个操作码范围内相邻的值相同的 VAL
+	if prev < 0 {
+		prev = 0
+	}
+	end := len(ops)
+	p = prev
+	for scan := 5; p < end && scan > 0; scan-- {
+		if isXZero(ops[p]) {
+			p += 2
+			continue
+		}
+		if isZero(ops[p]) {
+			p++
+			continue
+		}
+		if p+1 < end && !isZero(ops[p+1]) && !isXZero(ops[p+1]) {
+			v1, v2 := valValue(ops[p]), valValue(ops[p+1])
+			if v1 == v2 {
+				length := valLen(ops[p]) + valLen(ops[p+1])
+				if length <= sparseValMaxLen {
+					ops[p+1] = makeVal(v1, length)
+					copy(ops[p:], ops[p+1:end])
+					end--
+					// 合并之后不移动 p, 尝试与右边的 VAL 继续合并
+					continue
+				}
+			}
+		}
+		p++
+	}
+	b = b[:headerSize+end]
+	invalidateCache(b)
+	return b, true, nil
+}
+
+// promote 将稀疏编码转换为稠密编码之后设置寄存器, 寄存器一定会被更新
+func promote(b []byte, index int, count uint8) ([]byte, bool, error) {
+	dense, err := sparseToDense(b)
+	if err != nil {
+		return nil, false, err
+	}
+	denseUpdate(dense[headerSize:], index, count)
+	invalidateCache(dense)
+	return dense, true, nil
+}
+
+/* ---- 对外接口 ---- */
+
+// Add 添加元素, 返回新的字符串以及是否有寄存器被更新
+// b 可能被原地修改; 稀疏编码超过 sparseMaxBytes 字节时转换为稠密编码
+func Add(b []byte, element []byte, sparseMaxBytes int) ([]byte, bool, error) {
+	index, count := patLen(element)
+	if b[4] == encodingDense {
+		if !denseUpdate(b[headerSize:], index, count) {
+			return b, false, nil
+		}
+		invalidateCache(b)
+		return b, true, nil
+	}
+	return sparseSet(b, index, count, sparseMaxBytes)
+}
+
+// Registers 是展开的寄存器, 每个寄存器占一个字节, 用于合并多个 HyperLogLog
+type Registers [registerCount]uint8
+
+// Merge 将 b 合并到 registers 中, 每个寄存器取最大值
+func (registers *Registers) Merge(b []byte) error {
+	if b[4] == encodingDense {
+		dense := b[headerSize:]
+		for i := range registers {
+			if val := denseGet(dense, i); val > registers[i] {
+				registers[i] = val
+			}
+		}
+		return nil
+	}
+	return sparseForEach(b[headerSize:], func(first int, span int, val uint8) {
+		for i := first; i < first+span; i++ {
+			if val > registers[i] {
+				registers[i] = val
+			}
+		}
+	})
+}
+
+// Count 估算基数
+func (registers *Registers) Count() uint64 {
+	var histogram [64]int
+	for _, val := range registers {
+		histogram[val]++
+	}
+	return estimate(&histogram)
+}
+
+// Store 将寄存器中的非 0 值写入 b, 返回新的字符串
+// 寄存器只会增大, 所以 b 中的寄存器不能大于 registers 中对应的值; 需要时转换为稠密编码
+func (registers *Registers) Store(b []byte, sparseMaxBytes int) ([]byte, error) {
+	var err error
+	for i, val := range registers {
+		if val == 0 {
+			continue
+		}
+		if b[4] == encodingDense {
+			denseUpdate(b[headerSize:], i, val)
+			continue
+		}
+		b, _, err = sparseSet(b, i, val, sparseMaxBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+	invalidateCache(b)
+	return b, nil
+}
+
+// ToDense 将 b 转换为稠密编码, 已经是稠密编码时直接返回
+func ToDense(b []byte) ([]byte, error) {
+	if b[4] == encodingDense {
+		return b, nil
+	}
+	return sparseToDense(b)
+}
+
+// Count 估算基数, 不使用也不更新缓存
+func Count(b []byte) (uint64, error) {
+	var histogram [64]int
+	if b[4] == encodingDense {
+		dense := b[headerSize:]
+		for i := 0; i < registerCount; i++ {
+			histogram[denseGet(dense, i)]++
+		}
+	} else {
+		err := sparseForEach(b[headerSize:], func(first int, span int, val uint8) {
+			histogram[val] += span
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return estimate(&histogram), nil
+}
+
+// estimate 根据寄存器值的分布估算基数
+// 参见 Otmar Ertl, "New cardinality estimation algorithms for HyperLogLog sketches", arXiv:1702.01284
+func estimate(histogram *[64]int) uint64 {
+	m := float64(registerCount)
+	z := m * tau((m-float64(histogram[hashBits+1]))/m)
+	for j := hashBits; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * sigma(float64(histogram[0])/m)
+	return uint64(math.Round(alphaInf * m * m / z))
+}
+
+func sigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func tau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package hyperloglog
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// registersOf 展开 HyperLogLog 的寄存器
+func registersOf(t *testing.T, b []byte) *Registers {
+	t.Helper()
+	registers := &Registers{}
+	if err := registers.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	return registers
+}
+
+func TestNew(t *testing.T) {
+	// 与 redis 中空的 HyperLogLog 相同: 稀疏编码, 一个覆盖所有寄存器的 XZERO
+	expected := append([]byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00"), 0x7f, 0xff)
+	b := New()
+	if !bytes.Equal(b, expected) {
+		t.Fatalf("expected %q, actual %q", expected, b)
+	}
+	if !IsValid(b) || Encoding(b) != EncodingSparse {
+		t.Fatal("new HyperLogLog should be a valid sparse HyperLogLog")
+	}
+	if count, err := Count(b); err != nil || count != 0 {
+		t.Fatalf("expected 0, actual %d, %v", count, err)
+	}
+}
+
+func TestDenseGetSet(t *testing.T) {
+	rand.Seed(1)
+	registers := make([]byte, denseSize-headerSize)
+	expected := make([]uint8, registerCount)
+	for i := 0; i < 100000; i++ {
+		index := rand.Intn(registerCount)
+		val := uint8(rand.Intn(registerMax + 1))
+		denseSet(registers, index, val)
+		expected[index] = val
+	}
+	for i, val := range expected {
+		if actual := denseGet(registers, i); actual != val {
+			t.Fatalf("register %d: expected %d, actual %d", i, val, actual)
+		}
+	}
+}
+
+// 稀疏编码和稠密编码中添加相同的元素, 每一步是否更新以及最终的寄存器都必须相同
+func TestSparseMatchesDense(t *testing.T) {
+	sparse := New()
+	dense, err := ToDense(New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20000; i++ {
+		element := []byte("element:" + strconv.Itoa(i%15000))
+		var sparseUpdated, denseUpdated bool
+		// 足够大的 sparseMaxBytes, 保证不会因为长度转换为稠密编码
+		sparse, sparseUpdated, err = Add(sparse, element, math.MaxInt32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dense, denseUpdated, err = Add(dense, element, math.MaxInt32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sparseUpdated != denseUpdated {
+			t.Fatalf("element %s: sparse updated %v, dense updated %v", element, sparseUpdated, denseUpdated)
+		}
+		if i%1000 == 0 {
+			if err := sparseForEach(sparse[headerSize:], func(int, int, uint8) {}); err != nil {
+				t.Fatalf("invalid sparse encoding after %d elements", i)
+			}
+		}
+	}
+	if Encoding(sparse) != EncodingSparse {
+		t.Fatal("sparse HyperLogLog should not be promoted")
+	}
+	if *registersOf(t, sparse) != *registersOf(t, dense) {
+		t.Fatal("sparse and dense registers are different")
+	}
+	sparseCount, err := Count(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	denseCount, err := Count(dense)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparseCount != denseCount {
+		t.Fatalf("sparse count %d, dense count %d", sparseCount, denseCount)
+	}
+
+	// 转换为稠密编码之后寄存器不变
+	converted, err := ToDense(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(converted[headerSize:], dense[headerSize:]) {
+		t.Fatal("converted registers are different")
+	}
+}
+
+func TestPromote(t *testing.T) {
+	b := New()
+	var err error
+	for i := 0; i < 5000 && Encoding(b) == EncodingSparse; i++ {
+		b, _, err = Add(b, []byte(strconv.Itoa(i)), 3000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if Encoding(b) == EncodingSparse && len(b)-headerSize > 3000 {
+			t.Fatalf("sparse encoding has %d bytes", len(b)-headerSize)
+		}
+	}
+	if Encoding(b) != EncodingDense || len(b) != denseSize {
+		t.Fatal("HyperLogLog should be promoted to dense encoding")
+	}
+
+	// 寄存器的值超过 32 时必须转换为稠密编码
+	b = New()
+	b, updated, err := sparseSet(b, 100, sparseValMaxValue+1, math.MaxInt32)
+	if err != nil || !updated {
+		t.Fatalf("expected updated, actual %v, %v", updated, err)
+	}
+	if Encoding(b) != EncodingDense || denseGet(b[headerSize:], 100) != sparseValMaxValue+1 {
+		t.Fatal("register greater than 32 should promote to dense encoding")
+	}
+}
+
+func TestCountError(t *testing.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		b := New()
+		var err error
+		for i := 0; i < n; i++ {
+			b, _, err = Add(b, []byte("key:"+strconv.Itoa(i)), 3000)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		count, err := Count(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 标准误差约为 0.81%, 这里允许 5%
+		if relErr := math.Abs(float64(count)-float64(n)) / float64(n); relErr > 0.05 {
+			t.Fatalf("n = %d, count = %d, error %.4f", n, count, relErr)
+		}
+	}
+}
+
+func TestMergeAndStore(t *testing.T) {
+	a, b := New(), New()
+	var err error
+	for i := 0; i < 3000; i++ {
+		a, _, err = Add(a, []byte(strconv.Itoa(i)), 3000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _, err = Add(b, []byte(strconv.Itoa(i+2000)), 3000)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registers := &Registers{}
+	if err := registers.Merge(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := registers.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	count := registers.Count()
+	if relErr := math.Abs(float64(count)-5000) / 5000; relErr > 0.05 {
+		t.Fatalf("union count %d", count)
+	}
+
+	for _, dest := range [][]byte{New(), a} {
+		stored, err := registers.Store(dest, 3000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *registersOf(t, stored) != *registers {
+			t.Fatal("stored registers are different")
+		}
+		if _, ok := CachedCount(stored); ok {
+			t.Fatal("cached count should be invalidated")
+		}
+	}
+}
+
+func TestCachedCount(t *testing.T) {
+	b := New()
+	SetCachedCount(b, 42)
+	if count, ok := CachedCount(b); !ok || count != 42 {
+		t.Fatalf("expected 42, actual %d, %v", count, ok)
+	}
+	b, updated, err := Add(b, []byte("a"), 3000)
+	if err != nil || !updated {
+		t.Fatalf("expected updated, actual %v, %v", updated, err)
+	}
+	if _, ok := CachedCount(b); ok {
+		t.Fatal("cached count should be invalidated after update")
+	}
+}
+
+func TestCorrupted(t *testing.T) {
+	// XZERO 只覆盖 100 个寄存器
+	b := New()[:headerSize]
+	b = appendXZero(b, 100)
+	if _, err := Count(b); err != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, actual %v", err)
+	}
+	if _, err := ToDense(b); err != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, actual %v", err)
+	}
+	// 寄存器超出稀疏编码覆盖的范围
+	if _, _, err := sparseSet(b, 200, 1, 3000); err != ErrCorrupted {
+		t.Fatalf("expected ErrCorrupted, actual %v", err)
+	}
+	if IsValid([]byte("HYLL")) || IsValid(append([]byte("HYLL\x00"), make([]byte, 11)...)) {
+		t.Fatal("truncated HyperLogLog should be invalid")
+	}
+}
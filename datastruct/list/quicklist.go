@@ -0,0 +1,383 @@
+package list
+
+import "bytes"
+
+// EncodingQuicklist 编码名称, 与 OBJECT ENCODING 的输出一致
+const EncodingQuicklist = "quicklist"
+
+// pageSize 每个节点最多存储的元素个数
+// 节点内插入或删除元素需要移动 O(pageSize) 个元素, 与链表长度无关
+const pageSize = 128
+
+// node 是 QuickList 的节点, 存储一段连续的元素
+type node struct {
+	prev *node
+	next *node
+	vals [][]byte
+}
+
+// QuickList 是由多个节点组成的双向链表, 每个节点存储一段元素
+// 相比普通链表节省了指针的开销, 相比数组在头部插入时不需要移动所有元素
+type QuickList struct {
+	head *node
+	tail *node
+	size int
+}
+
+// NewQuickList 创建 QuickList
+func NewQuickList() *QuickList {
+	return &QuickList{}
+}
+
+// Encoding 返回当前的编码
+func (ql *QuickList) Encoding() string {
+	return EncodingQuicklist
+}
+
+// Len 返回元素个数
+func (ql *QuickList) Len() int {
+	return ql.size
+}
+
+// Copy 返回链表的副本, 元素的内容不会被修改, 因此与原链表共享
+func (ql *QuickList) Copy() *QuickList {
+	result := NewQuickList()
+	ql.ForEach(func(i int, val []byte) bool {
+		result.PushBack(val)
+		return true
+	})
+	return result
+}
+
+// PushBack 在尾部添加元素
+func (ql *QuickList) PushBack(val []byte) {
+	ql.size++
+	if ql.tail == nil || len(ql.tail.vals) >= pageSize {
+		n := &node{vals: make([][]byte, 0, pageSize)}
+		ql.linkAfter(ql.tail, n)
+	}
+	ql.tail.vals = append(ql.tail.vals, val)
+}
+
+// PushFront 在头部添加元素
+func (ql *QuickList) PushFront(val []byte) {
+	ql.size++
+	if ql.head == nil || len(ql.head.vals) >= pageSize {
+		n := &node{vals: make([][]byte, 0, pageSize)}
+		ql.linkBefore(ql.head, n)
+	}
+	h := ql.head
+	h.vals = append(h.vals, nil)
+	copy(h.vals[1:], h.vals)
+	h.vals[0] = val
+}
+
+// PopFront 删除并返回头部元素, 链表为空时返回 nil
+func (ql *QuickList) PopFront() []byte {
+	if ql.size == 0 {
+		return nil
+	}
+	return ql.head.removeAt(ql, 0)
+}
+
+// PopBack 删除并返回尾部元素, 链表为空时返回 nil
+func (ql *QuickList) PopBack() []byte {
+	if ql.size == 0 {
+		return nil
+	}
+	return ql.tail.removeAt(ql, len(ql.tail.vals)-1)
+}
+
+// Get 返回下标为 index 的元素, 调用者需要保证下标合法
+func (ql *QuickList) Get(index int) []byte {
+	return ql.find(index).Get()
+}
+
+// Set 修改下标为 index 的元素, 调用者需要保证下标合法
+func (ql *QuickList) Set(index int, val []byte) {
+	ql.find(index).Set(val)
+}
+
+// Insert 在下标 index 之前插入元素, index == Len() 时添加到尾部
+func (ql *QuickList) Insert(index int, val []byte) {
+	if index == ql.size {
+		ql.PushBack(val)
+		return
+	}
+	ql.find(index).insertBefore(val)
+}
+
+// Remove 删除并返回下标为 index 的元素, 调用者需要保证下标合法
+func (ql *QuickList) Remove(index int) []byte {
+	return ql.find(index).Remove()
+}
+
+// Range 返回 [start, stop) 区间内的元素
+func (ql *QuickList) Range(start int, stop int) [][]byte {
+	if start < 0 || stop > ql.size || start >= stop {
+		return [][]byte{}
+	}
+	result := make([][]byte, 0, stop-start)
+	iter := ql.find(start)
+	for i := start; i < stop; i++ {
+		result = append(result, iter.Get())
+		iter.Next()
+	}
+	return result
+}
+
+// Trim 只保留 [start, stop) 区间内的元素
+func (ql *QuickList) Trim(start int, stop int) {
+	if start < 0 {
+		start = 0
+	}
+	if stop > ql.size {
+		stop = ql.size
+	}
+	if start >= stop {
+		*ql = QuickList{}
+		return
+	}
+	for ql.size > stop {
+		ql.PopBack()
+	}
+	for i := 0; i < start; i++ {
+		ql.PopFront()
+	}
+}
+
+// RemoveByVal 从头部开始删除最多 count 个等于 val 的元素, count <= 0 时删除所有, 返回删除的个数
+func (ql *QuickList) RemoveByVal(val []byte, count int) int {
+	removed := 0
+	iter := ql.Iterator(0)
+	for !iter.AtEnd() && (count <= 0 || removed < count) {
+		if bytes.Equal(iter.Get(), val) {
+			iter.Remove()
+			removed++
+		} else {
+			iter.Next()
+		}
+	}
+	return removed
+}
+
+// ReverseRemoveByVal 从尾部开始删除最多 count 个等于 val 的元素, 返回删除的个数
+func (ql *QuickList) ReverseRemoveByVal(val []byte, count int) int {
+	removed := 0
+	iter := ql.Iterator(ql.size - 1)
+	for !iter.AtEnd() && (count <= 0 || removed < count) {
+		if bytes.Equal(iter.Get(), val) {
+			iter.Remove()
+			removed++
+			// 删除后迭代器指向下一个元素, 需要回退
+			if iter.AtEnd() {
+				iter = ql.Iterator(ql.size - 1)
+				continue
+			}
+		}
+		iter.Prev()
+	}
+	return removed
+}
+
+// ForEach 从头部开始遍历, consumer 返回 false 时停止
+func (ql *QuickList) ForEach(consumer func(i int, val []byte) bool) {
+	i := 0
+	for n := ql.head; n != nil; n = n.next {
+		for _, val := range n.vals {
+			if !consumer(i, val) {
+				return
+			}
+			i++
+		}
+	}
+}
+
+// ReverseForEach 从尾部开始遍历, consumer 返回 false 时停止
+func (ql *QuickList) ReverseForEach(consumer func(i int, val []byte) bool) {
+	i := ql.size - 1
+	for n := ql.tail; n != nil; n = n.prev {
+		for j := len(n.vals) - 1; j >= 0; j-- {
+			if !consumer(i, n.vals[j]) {
+				return
+			}
+			i--
+		}
+	}
+}
+
+// linkAfter 将节点 n 插入到 at 之后, at 为 nil 时作为唯一的节点
+func (ql *QuickList) linkAfter(at *node, n *node) {
+	if at == nil {
+		ql.head, ql.tail = n, n
+		return
+	}
+	n.prev, n.next = at, at.next
+	if at.next != nil {
+		at.next.prev = n
+	} else {
+		ql.tail = n
+	}
+	at.next = n
+}
+
+// linkBefore 将节点 n 插入到 at 之前, at 为 nil 时作为唯一的节点
+func (ql *QuickList) linkBefore(at *node, n *node) {
+	if at == nil {
+		ql.head, ql.tail = n, n
+		return
+	}
+	n.prev, n.next = at.prev, at
+	if at.prev != nil {
+		at.prev.next = n
+	} else {
+		ql.head = n
+	}
+	at.prev = n
+}
+
+// unlink 从链表中移除节点 n
+func (ql *QuickList) unlink(n *node) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		ql.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		ql.tail = n.prev
+	}
+	n.prev, n.next = nil, nil
+}
+
+// removeAt 删除节点中下标为 offset 的元素, 节点为空时将其从链表中移除
+func (n *node) removeAt(ql *QuickList, offset int) []byte {
+	val := n.vals[offset]
+	copy(n.vals[offset:], n.vals[offset+1:])
+	n.vals[len(n.vals)-1] = nil
+	n.vals = n.vals[:len(n.vals)-1]
+	ql.size--
+	if len(n.vals) == 0 {
+		ql.unlink(n)
+	}
+	return val
+}
+
+/* ---- Iterator ---- */
+
+// Iterator 是 QuickList 的迭代器
+type Iterator struct {
+	ql     *QuickList
+	node   *node
+	offset int // 元素在节点中的下标
+}
+
+// Iterator 返回指向下标 index 的迭代器, 下标越界时迭代器处于结束状态
+func (ql *QuickList) Iterator(index int) *Iterator {
+	if index < 0 || index >= ql.size {
+		return &Iterator{ql: ql}
+	}
+	return ql.find(index)
+}
+
+// find 从距离较近的一端开始查找下标为 index 的元素
+func (ql *QuickList) find(index int) *Iterator {
+	if index < ql.size/2 {
+		n := ql.head
+		for index >= len(n.vals) {
+			index -= len(n.vals)
+			n = n.next
+		}
+		return &Iterator{ql: ql, node: n, offset: index}
+	}
+	n := ql.tail
+	back := ql.size - 1 - index
+	for back >= len(n.vals) {
+		back -= len(n.vals)
+		n = n.prev
+	}
+	return &Iterator{ql: ql, node: n, offset: len(n.vals) - 1 - back}
+}
+
+// AtEnd 判断迭代器是否已经越界
+func (iter *Iterator) AtEnd() bool {
+	return iter.node == nil
+}
+
+// Get 返回当前元素
+func (iter *Iterator) Get() []byte {
+	return iter.node.vals[iter.offset]
+}
+
+// Set 修改当前元素
+func (iter *Iterator) Set(val []byte) {
+	iter.node.vals[iter.offset] = val
+}
+
+// Next 移动到下一个元素, 越界时返回 false
+func (iter *Iterator) Next() bool {
+	if iter.node == nil {
+		return false
+	}
+	if iter.offset+1 < len(iter.node.vals) {
+		iter.offset++
+		return true
+	}
+	iter.node = iter.node.next
+	iter.offset = 0
+	return iter.node != nil
+}
+
+// Prev 移动到上一个元素, 越界时返回 false
+func (iter *Iterator) Prev() bool {
+	if iter.node == nil {
+		return false
+	}
+	if iter.offset > 0 {
+		iter.offset--
+		return true
+	}
+	iter.node = iter.node.prev
+	if iter.node != nil {
+		iter.offset = len(iter.node.vals) - 1
+	}
+	return iter.node != nil
+}
+
+// Remove 删除当前元素并返回, 之后迭代器指向原来的下一个元素
+func (iter *Iterator) Remove() []byte {
+	n := iter.node
+	next := n.next
+	val := n.removeAt(iter.ql, iter.offset)
+	if len(n.vals) == 0 {
+		iter.node, iter.offset = next, 0
+	} else if iter.offset >= len(n.vals) {
+		iter.node, iter.offset = next, 0
+	}
+	return val
+}
+
+// insertBefore 在当前元素之前插入元素, 节点已满时将节点一分为二
+func (iter *Iterator) insertBefore(val []byte) {
+	n := iter.node
+	if len(n.vals) >= pageSize {
+		half := len(n.vals) / 2
+		right := &node{vals: make([][]byte, 0, pageSize)}
+		right.vals = append(right.vals, n.vals[half:]...)
+		for i := half; i < len(n.vals); i++ {
+			n.vals[i] = nil
+		}
+		n.vals = n.vals[:half]
+		iter.ql.linkAfter(n, right)
+		if iter.offset >= half {
+			n = right
+			iter.node = right
+			iter.offset -= half
+		}
+	}
+	n.vals = append(n.vals, nil)
+	copy(n.vals[iter.offset+1:], n.vals[iter.offset:])
+	n.vals[iter.offset] = val
+	iter.ql.size++
+}
@@ -0,0 +1,125 @@
+package set
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+/*
+ * intset 是有序的整数集合, 与 redis 的 intset 相同:
+ * 所有元素使用相同的宽度(2、4 或 8 字节)按照小端序连续存储在一个字节切片中,
+ * 插入的元素超出当前宽度的范围时将整个集合升级到更大的宽度, 不会降级
+ */
+
+type intset struct {
+	width    int    // 每个元素占用的字节数
+	contents []byte // 按照从小到大的顺序存储的元素
+}
+
+func makeIntset() *intset {
+	return &intset{width: 2}
+}
+
+// widthOf 返回存储 val 需要的最小宽度
+func widthOf(val int64) int {
+	if val < math.MinInt32 || val > math.MaxInt32 {
+		return 8
+	}
+	if val < math.MinInt16 || val > math.MaxInt16 {
+		return 4
+	}
+	return 2
+}
+
+// len 返回元素个数
+func (is *intset) len() int {
+	return len(is.contents) / is.width
+}
+
+// get 返回下标为 i 的元素
+func (is *intset) get(i int) int64 {
+	b := is.contents[i*is.width:]
+	switch is.width {
+	case 2:
+		return int64(int16(binary.LittleEndian.Uint16(b)))
+	case 4:
+		return int64(int32(binary.LittleEndian.Uint32(b)))
+	default:
+		return int64(binary.LittleEndian.Uint64(b))
+	}
+}
+
+// set 将下标为 i 的元素修改为 val, 调用者需要保证宽度足够
+func (is *intset) set(i int, val int64) {
+	b := is.contents[i*is.width:]
+	switch is.width {
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(val))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(val))
+	default:
+		binary.LittleEndian.PutUint64(b, uint64(val))
+	}
+}
+
+// search 返回第一个不小于 val 的元素的下标, 以及该元素是否等于 val
+func (is *intset) search(val int64) (int, bool) {
+	n := is.len()
+	i := sort.Search(n, func(i int) bool {
+		return is.get(i) >= val
+	})
+	return i, i < n && is.get(i) == val
+}
+
+// has 判断 val 是否在集合中
+func (is *intset) has(val int64) bool {
+	if widthOf(val) > is.width {
+		return false
+	}
+	_, found := is.search(val)
+	return found
+}
+
+// upgrade 将集合升级到 width 宽度
+func (is *intset) upgrade(width int) {
+	upgraded := &intset{width: width, contents: make([]byte, is.len()*width)}
+	for i := 0; i < is.len(); i++ {
+		upgraded.set(i, is.get(i))
+	}
+	*is = *upgraded
+}
+
+// add 添加元素, 返回元素是否是新插入的
+func (is *intset) add(val int64) bool {
+	if w := widthOf(val); w > is.width {
+		is.upgrade(w)
+	}
+	i, found := is.search(val)
+	if found {
+		return false
+	}
+	is.contents = append(is.contents, make([]byte, is.width)...)
+	copy(is.contents[(i+1)*is.width:], is.contents[i*is.width:])
+	is.set(i, val)
+	return true
+}
+
+// remove 删除元素, 返回元素是否存在
+func (is *intset) remove(val int64) bool {
+	if widthOf(val) > is.width {
+		return false
+	}
+	i, found := is.search(val)
+	if !found {
+		return false
+	}
+	is.removeAt(i)
+	return true
+}
+
+// removeAt 删除下标为 i 的元素
+func (is *intset) removeAt(i int) {
+	copy(is.contents[i*is.width:], is.contents[(i+1)*is.width:])
+	is.contents = is.contents[:len(is.contents)-is.width]
+}
@@ -0,0 +1,209 @@
+package set
+
+import (
+	"math/rand"
+	"strconv"
+
+	"github.com/LynchQ/my-go-redis/datastruct/dict"
+)
+
+/*
+ * Set 有两种编码:
+ * intset: 所有元素都是整数时使用有序的整数数组, 节省内存
+ * hashtable: 使用 dict.ChainedDict 存储, 添加非整数元素或者元素个数超过限制时转换, 支持使用游标遍历
+ * 编码只会从 intset 转换为 hashtable, 元素个数的限制由调用者检查
+ */
+
+// 编码名称, 与 OBJECT ENCODING 的输出一致
+const (
+	EncodingIntset    = "intset"
+	EncodingHashtable = "hashtable"
+)
+
+// Consumer 用于遍历集合, 返回 false 时停止遍历
+type Consumer func(member string) bool
+
+// Set 是 redis 的集合类型, 不是线程安全的
+type Set struct {
+	is *intset           // intset 编码的数据, 使用 hashtable 编码时为 nil
+	m  *dict.ChainedDict // hashtable 编码的数据, 值为 nil, 使用 intset 编码时为 nil
+}
+
+// Make 创建一个 intset 编码的空集合
+func Make() *Set {
+	return &Set{is: makeIntset()}
+}
+
+// parseInteger 解析可以使用 intset 存储的整数, 只接受规范的十进制表示, 例如不接受 "+1" 和 "01"
+func parseInteger(member string) (int64, bool) {
+	val, err := strconv.ParseInt(member, 10, 64)
+	if err != nil || strconv.FormatInt(val, 10) != member {
+		return 0, false
+	}
+	return val, true
+}
+
+// Encoding 返回当前的编码
+func (s *Set) Encoding() string {
+	if s.is != nil {
+		return EncodingIntset
+	}
+	return EncodingHashtable
+}
+
+// IsIntset 判断是否使用 intset 编码
+func (s *Set) IsIntset() bool {
+	return s.is != nil
+}
+
+// Convert 转换为 hashtable 编码
+func (s *Set) Convert() {
+	if s.is == nil {
+		return
+	}
+	m := dict.MakeChained()
+	for i := 0; i < s.is.len(); i++ {
+		m.Put(strconv.FormatInt(s.is.get(i), 10), nil)
+	}
+	s.m, s.is = m, nil
+}
+
+// Len 返回元素个数
+func (s *Set) Len() int {
+	if s.is != nil {
+		return s.is.len()
+	}
+	return s.m.Len()
+}
+
+// Copy 返回集合的副本, 使用相同的编码
+func (s *Set) Copy() *Set {
+	if s.is != nil {
+		return &Set{is: &intset{width: s.is.width, contents: append([]byte{}, s.is.contents...)}}
+	}
+	m := dict.MakeChained()
+	s.m.ForEach(func(member string, val interface{}) bool {
+		m.Put(member, nil)
+		return true
+	})
+	return &Set{m: m}
+}
+
+// Add 添加元素, 返回元素是否是新插入的
+// 使用 intset 编码时添加非整数元素会转换为 hashtable 编码
+func (s *Set) Add(member string) bool {
+	if s.is != nil {
+		if val, ok := parseInteger(member); ok {
+			return s.is.add(val)
+		}
+		s.Convert()
+	}
+	return s.m.PutIfAbsent(member, nil) > 0
+}
+
+// Remove 删除元素, 返回元素是否存在
+func (s *Set) Remove(member string) bool {
+	if s.is != nil {
+		val, ok := parseInteger(member)
+		return ok && s.is.remove(val)
+	}
+	return s.m.Remove(member) > 0
+}
+
+// Has 判断元素是否在集合中
+func (s *Set) Has(member string) bool {
+	if s.is != nil {
+		val, ok := parseInteger(member)
+		return ok && s.is.has(val)
+	}
+	_, exists := s.m.Get(member)
+	return exists
+}
+
+// ForEach 遍历集合, intset 编码时按照从小到大的顺序遍历
+func (s *Set) ForEach(consumer Consumer) {
+	if s.is != nil {
+		for i := 0; i < s.is.len(); i++ {
+			if !consumer(strconv.FormatInt(s.is.get(i), 10)) {
+				return
+			}
+		}
+		return
+	}
+	s.m.ForEach(func(member string, val interface{}) bool {
+		return consumer(member)
+	})
+}
+
+// Scan 使用游标遍历集合, 返回下一次遍历的游标, 返回 0 表示遍历结束
+// intset 编码时一次遍历所有元素
+func (s *Set) Scan(cursor uint64, consumer func(member string)) uint64 {
+	if s.is != nil {
+		s.ForEach(func(member string) bool {
+			consumer(member)
+			return true
+		})
+		return 0
+	}
+	return s.m.Scan(cursor, func(member string, val interface{}) {
+		consumer(member)
+	})
+}
+
+// Members 返回所有的元素
+func (s *Set) Members() []string {
+	result := make([]string, 0, s.Len())
+	s.ForEach(func(member string) bool {
+		result = append(result, member)
+		return true
+	})
+	return result
+}
+
+// RandomMember 随机返回一个元素, 集合为空时返回空字符串
+func (s *Set) RandomMember() string {
+	if s.is != nil {
+		if s.is.len() == 0 {
+			return ""
+		}
+		return strconv.FormatInt(s.is.get(rand.Intn(s.is.len())), 10)
+	}
+	if s.m.Len() == 0 {
+		return ""
+	}
+	return s.m.RandomKeys(1)[0]
+}
+
+// RandomMembers 随机返回 limit 个元素, 可能包含重复的元素
+// limit 可能远大于元素个数, 预分配的容量不超过元素个数
+func (s *Set) RandomMembers(limit int) []string {
+	size := s.Len()
+	if size == 0 {
+		return nil
+	}
+	if s.is != nil {
+		if limit < size {
+			size = limit
+		}
+		result := make([]string, 0, size)
+		for len(result) < limit {
+			result = append(result, strconv.FormatInt(s.is.get(rand.Intn(s.is.len())), 10))
+		}
+		return result
+	}
+	return s.m.RandomKeys(limit)
+}
+
+// RandomDistinctMembers 随机返回最多 limit 个不重复的元素
+func (s *Set) RandomDistinctMembers(limit int) []string {
+	all := s.Members()
+	if limit >= len(all) {
+		return all
+	}
+	// 部分 Fisher-Yates 洗牌
+	for i := 0; i < limit; i++ {
+		j := i + rand.Intn(len(all)-i)
+		all[i], all[j] = all[j], all[i]
+	}
+	return all[:limit]
+}
@@ -0,0 +1,128 @@
+package sortedset
+
+import (
+	"errors"
+	"math"
+	"strconv"
+)
+
+/*
+ * Border 是范围查询的边界, 分为按照分值的 ScoreBorder 和按照字典序的 LexBorder
+ */
+
+// Border 是范围查询的边界
+type Border interface {
+	// less 判断 element 是否满足以当前边界为下界的条件
+	less(element *Element) bool
+	// greater 判断 element 是否满足以当前边界为上界的条件
+	greater(element *Element) bool
+	// isEmptyRange 判断以当前边界为下界、max 为上界的区间是否一定为空
+	isEmptyRange(max Border) bool
+}
+
+// ScoreBorder 是按照分值的边界, 例如 1.5、(1.5、-inf、+inf
+type ScoreBorder struct {
+	Value   float64
+	Exclude bool
+}
+
+// 无穷大和无穷小的分值边界
+var (
+	NegativeInfScore Border = &ScoreBorder{Value: math.Inf(-1)}
+	PositiveInfScore Border = &ScoreBorder{Value: math.Inf(1)}
+)
+
+func (border *ScoreBorder) less(element *Element) bool {
+	if border.Exclude {
+		return border.Value < element.Score
+	}
+	return border.Value <= element.Score
+}
+
+func (border *ScoreBorder) greater(element *Element) bool {
+	if border.Exclude {
+		return border.Value > element.Score
+	}
+	return border.Value >= element.Score
+}
+
+func (border *ScoreBorder) isEmptyRange(max Border) bool {
+	maxBorder := max.(*ScoreBorder)
+	return border.Value > maxBorder.Value ||
+		(border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude))
+}
+
+// ParseScoreBorder 解析分值边界, 以 ( 开头表示不包含边界本身
+func ParseScoreBorder(s string) (Border, error) {
+	border := &ScoreBorder{}
+	if len(s) > 0 && s[0] == '(' {
+		border.Exclude = true
+		s = s[1:]
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, errors.New("ERR min or max is not a float")
+	}
+	border.Value = value
+	return border, nil
+}
+
+// LexBorder 是按照字典序的边界, 例如 [a、(a、-、+
+type LexBorder struct {
+	Value   string
+	Exclude bool
+	Inf     int // -1 表示 -, 1 表示 +, 0 表示普通的边界
+}
+
+func (border *LexBorder) less(element *Element) bool {
+	switch border.Inf {
+	case -1:
+		return true
+	case 1:
+		return false
+	}
+	if border.Exclude {
+		return border.Value < element.Member
+	}
+	return border.Value <= element.Member
+}
+
+func (border *LexBorder) greater(element *Element) bool {
+	switch border.Inf {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	if border.Exclude {
+		return border.Value > element.Member
+	}
+	return border.Value >= element.Member
+}
+
+func (border *LexBorder) isEmptyRange(max Border) bool {
+	maxBorder := max.(*LexBorder)
+	if border.Inf == 1 || maxBorder.Inf == -1 {
+		return true
+	}
+	if border.Inf == -1 || maxBorder.Inf == 1 {
+		return false
+	}
+	return border.Value > maxBorder.Value ||
+		(border.Value == maxBorder.Value && (border.Exclude || maxBorder.Exclude))
+}
+
+// ParseLexBorder 解析字典序边界, 必须以 [ 或 ( 开头, 或者是 - 和 +
+func ParseLexBorder(s string) (Border, error) {
+	switch {
+	case s == "-":
+		return &LexBorder{Inf: -1}, nil
+	case s == "+":
+		return &LexBorder{Inf: 1}, nil
+	case len(s) > 0 && s[0] == '(':
+		return &LexBorder{Value: s[1:], Exclude: true}, nil
+	case len(s) > 0 && s[0] == '[':
+		return &LexBorder{Value: s[1:]}, nil
+	}
+	return nil, errors.New("ERR min or max not valid string range item")
+}
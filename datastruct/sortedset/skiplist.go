@@ -0,0 +1,288 @@
+package sortedset
+
+import "math/rand"
+
+/*
+ * 跳表, 与 redis 的 zskiplist 相同
+ * 元素按照 (score, member) 排序, 每一层的指针记录跨越的元素个数(span), 因此可以在 O(log(N)) 内计算排名
+ */
+
+const (
+	maxLevel    = 32
+	probability = 0.25
+)
+
+// Element 是有序集合中的元素
+type Element struct {
+	Member string
+	Score  float64
+}
+
+// level 是节点中的一层
+type level struct {
+	forward *node // 这一层的下一个节点
+	span    int64 // 到下一个节点跨越的元素个数
+}
+
+// node 是跳表的节点
+type node struct {
+	Element
+	backward *node
+	level    []*level
+}
+
+type skiplist struct {
+	header *node
+	tail   *node
+	length int64
+	level  int
+}
+
+func makeNode(lvl int, score float64, member string) *node {
+	n := &node{
+		Element: Element{Score: score, Member: member},
+		level:   make([]*level, lvl),
+	}
+	for i := range n.level {
+		n.level[i] = &level{}
+	}
+	return n
+}
+
+func makeSkiplist() *skiplist {
+	return &skiplist{
+		level:  1,
+		header: makeNode(maxLevel, 0, ""),
+	}
+}
+
+// randomLevel 返回新节点的层数, 每增加一层的概率为 1/4
+func randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && rand.Float64() < probability {
+		lvl++
+	}
+	return lvl
+}
+
+// before 判断 (score, member) 是否排在节点 n 之后
+func (n *node) before(score float64, member string) bool {
+	return n.Score < score || (n.Score == score && n.Member < member)
+}
+
+// insert 插入元素, 调用者需要保证 member 不存在
+func (sl *skiplist) insert(member string, score float64) *node {
+	update := make([]*node, maxLevel) // 每一层中新节点的前一个节点
+	rank := make([]int64, maxLevel)   // 每一层中 update 节点的排名
+
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	lvl := randomLevel()
+	if lvl > sl.level {
+		for i := sl.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = sl.header
+			update[i].level[i].span = sl.length
+		}
+		sl.level = lvl
+	}
+
+	x = makeNode(lvl, score, member)
+	for i := 0; i < lvl; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	// 新节点没有到达的层, 跨越的元素多了一个
+	for i := lvl; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != sl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+	return x
+}
+
+// removeNode 删除节点 x, update 是每一层中 x 的前一个节点
+func (sl *skiplist) removeNode(x *node, update []*node) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.header.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// remove 删除元素, 返回元素是否存在
+func (sl *skiplist) remove(member string, score float64) bool {
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x != nil && x.Score == score && x.Member == member {
+		sl.removeNode(x, update)
+		return true
+	}
+	return false
+}
+
+// getRank 返回元素的排名, 从 1 开始, 元素不存在时返回 0
+func (sl *skiplist) getRank(member string, score float64) int64 {
+	var rank int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil &&
+			(x.level[i].forward.before(score, member) ||
+				(x.level[i].forward.Score == score && x.level[i].forward.Member == member)) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != sl.header && x.Member == member {
+			return rank
+		}
+	}
+	return 0
+}
+
+// getByRank 返回排名为 rank 的节点, 排名从 1 开始
+func (sl *skiplist) getByRank(rank int64) *node {
+	var traversed int64
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// hasInRange 判断跳表中是否有元素在 [min, max] 中
+func (sl *skiplist) hasInRange(min Border, max Border) bool {
+	if min.isEmptyRange(max) {
+		return false
+	}
+	if sl.tail == nil || !min.less(&sl.tail.Element) {
+		return false
+	}
+	first := sl.header.level[0].forward
+	return first != nil && max.greater(&first.Element)
+}
+
+// firstInRange 返回 [min, max] 中的第一个节点
+func (sl *skiplist) firstInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !min.less(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !max.greater(&x.Element) {
+		return nil
+	}
+	return x
+}
+
+// lastInRange 返回 [min, max] 中的最后一个节点
+func (sl *skiplist) lastInRange(min Border, max Border) *node {
+	if !sl.hasInRange(min, max) {
+		return nil
+	}
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && max.greater(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+	}
+	if !min.less(&x.Element) {
+		return nil
+	}
+	return x
+}
+
+// removeRange 删除 [min, max] 中的元素, 返回被删除的元素
+func (sl *skiplist) removeRange(min Border, max Border) []*Element {
+	var removed []*Element
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !min.less(&x.level[i].forward.Element) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	for x != nil && max.greater(&x.Element) {
+		next := x.level[0].forward
+		removed = append(removed, &x.Element)
+		sl.removeNode(x, update)
+		x = next
+	}
+	return removed
+}
+
+// removeRangeByRank 删除排名在 [start, stop] 中的元素, 排名从 1 开始, 返回被删除的元素
+func (sl *skiplist) removeRangeByRank(start int64, stop int64) []*Element {
+	var removed []*Element
+	var traversed int64
+	update := make([]*node, maxLevel)
+	x := sl.header
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span < start {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	traversed++
+	x = x.level[0].forward
+	for x != nil && traversed <= stop {
+		next := x.level[0].forward
+		removed = append(removed, &x.Element)
+		sl.removeNode(x, update)
+		x = next
+		traversed++
+	}
+	return removed
+}
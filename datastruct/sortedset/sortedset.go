@@ -0,0 +1,267 @@
+package sortedset
+
+import (
+	"math/rand"
+
+	"github.com/LynchQ/my-go-redis/datastruct/dict"
+)
+
+// EncodingSkiplist 编码名称, 与 OBJECT ENCODING 的输出一致
+const EncodingSkiplist = "skiplist"
+
+// SortedSet 是 redis 的有序集合类型, 由字典和跳表组成, 不是线程安全的
+// 字典用于 O(1) 查找元素的分值以及使用游标遍历, 跳表用于按照分值排序、计算排名和范围查询
+type SortedSet struct {
+	dict     *dict.ChainedDict // member -> *Element
+	skiplist *skiplist
+}
+
+// Make 创建空的有序集合
+func Make() *SortedSet {
+	return &SortedSet{
+		dict:     dict.MakeChained(),
+		skiplist: makeSkiplist(),
+	}
+}
+
+// Encoding 返回当前的编码
+func (sortedSet *SortedSet) Encoding() string {
+	return EncodingSkiplist
+}
+
+// Len 返回元素个数
+func (sortedSet *SortedSet) Len() int64 {
+	return int64(sortedSet.dict.Len())
+}
+
+// Copy 返回有序集合的副本
+func (sortedSet *SortedSet) Copy() *SortedSet {
+	result := Make()
+	sortedSet.ForEach(func(element *Element) bool {
+		result.Add(element.Member, element.Score)
+		return true
+	})
+	return result
+}
+
+// Add 添加元素或者更新元素的分值, 返回元素是否是新插入的
+func (sortedSet *SortedSet) Add(member string, score float64) bool {
+	element, exists := sortedSet.Get(member)
+	if exists {
+		if element.Score == score {
+			return false
+		}
+		sortedSet.skiplist.remove(member, element.Score)
+	}
+	n := sortedSet.skiplist.insert(member, score)
+	sortedSet.dict.Put(member, &n.Element)
+	return !exists
+}
+
+// Get 返回元素
+func (sortedSet *SortedSet) Get(member string) (*Element, bool) {
+	element, exists := sortedSet.dict.Get(member)
+	if !exists {
+		return nil, false
+	}
+	return element.(*Element), true
+}
+
+// Remove 删除元素, 返回元素是否存在
+func (sortedSet *SortedSet) Remove(member string) bool {
+	element, exists := sortedSet.Get(member)
+	if !exists {
+		return false
+	}
+	sortedSet.skiplist.remove(member, element.Score)
+	sortedSet.dict.Remove(member)
+	return true
+}
+
+// GetRank 返回元素的排名, 从 0 开始, desc 为 true 时按照分值从大到小排名
+func (sortedSet *SortedSet) GetRank(member string, desc bool) (int64, bool) {
+	element, exists := sortedSet.Get(member)
+	if !exists {
+		return 0, false
+	}
+	rank := sortedSet.skiplist.getRank(member, element.Score)
+	if desc {
+		return sortedSet.skiplist.length - rank, true
+	}
+	return rank - 1, true
+}
+
+// ForEachByRank 按照排名遍历 [start, stop) 中的元素, 排名从 0 开始, consumer 返回 false 时停止
+// 调用者需要保证 0 <= start <= stop <= Len()
+func (sortedSet *SortedSet) ForEachByRank(start int64, stop int64, desc bool, consumer func(element *Element) bool) {
+	if start >= stop {
+		return
+	}
+	var n *node
+	if desc {
+		n = sortedSet.skiplist.getByRank(sortedSet.skiplist.length - start)
+	} else {
+		n = sortedSet.skiplist.getByRank(start + 1)
+	}
+	for i := start; i < stop && n != nil; i++ {
+		if !consumer(&n.Element) {
+			return
+		}
+		if desc {
+			n = n.backward
+		} else {
+			n = n.level[0].forward
+		}
+	}
+}
+
+// RangeByRank 返回排名在 [start, stop) 中的元素, 排名从 0 开始
+func (sortedSet *SortedSet) RangeByRank(start int64, stop int64, desc bool) []*Element {
+	result := make([]*Element, 0, stop-start)
+	sortedSet.ForEachByRank(start, stop, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RangeCount 返回 [min, max] 中的元素个数
+func (sortedSet *SortedSet) RangeCount(min Border, max Border) int64 {
+	first := sortedSet.skiplist.firstInRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.lastInRange(min, max)
+	firstRank := sortedSet.skiplist.getRank(first.Member, first.Score)
+	lastRank := sortedSet.skiplist.getRank(last.Member, last.Score)
+	return lastRank - firstRank + 1
+}
+
+// ForEachInRange 遍历 [min, max] 中的元素, 跳过前 offset 个, limit 小于 0 时不限制个数
+// desc 为 true 时从 max 开始遍历, consumer 返回 false 时停止
+func (sortedSet *SortedSet) ForEachInRange(min Border, max Border, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+	var n *node
+	if desc {
+		n = sortedSet.skiplist.lastInRange(min, max)
+	} else {
+		n = sortedSet.skiplist.firstInRange(min, max)
+	}
+	next := func() {
+		if desc {
+			n = n.backward
+		} else {
+			n = n.level[0].forward
+		}
+	}
+	for ; n != nil && offset > 0; offset-- {
+		next()
+	}
+	for ; n != nil && limit != 0; limit-- {
+		if !min.less(&n.Element) || !max.greater(&n.Element) {
+			return
+		}
+		if !consumer(&n.Element) {
+			return
+		}
+		next()
+	}
+}
+
+// Range 返回 [min, max] 中的元素, 参数与 ForEachInRange 相同
+func (sortedSet *SortedSet) Range(min Border, max Border, offset int64, limit int64, desc bool) []*Element {
+	result := make([]*Element, 0)
+	sortedSet.ForEachInRange(min, max, offset, limit, desc, func(element *Element) bool {
+		result = append(result, element)
+		return true
+	})
+	return result
+}
+
+// RemoveRange 删除 [min, max] 中的元素, 返回删除的个数
+func (sortedSet *SortedSet) RemoveRange(min Border, max Border) int64 {
+	removed := sortedSet.skiplist.removeRange(min, max)
+	for _, element := range removed {
+		sortedSet.dict.Remove(element.Member)
+	}
+	return int64(len(removed))
+}
+
+// RemoveByRank 删除排名在 [start, stop) 中的元素, 排名从 0 开始, 返回删除的个数
+func (sortedSet *SortedSet) RemoveByRank(start int64, stop int64) int64 {
+	if start >= stop {
+		return 0
+	}
+	removed := sortedSet.skiplist.removeRangeByRank(start+1, stop)
+	for _, element := range removed {
+		sortedSet.dict.Remove(element.Member)
+	}
+	return int64(len(removed))
+}
+
+// PopMin 删除并返回分值最小的 count 个元素
+func (sortedSet *SortedSet) PopMin(count int64) []*Element {
+	if count > sortedSet.Len() {
+		count = sortedSet.Len()
+	}
+	removed := sortedSet.skiplist.removeRangeByRank(1, count)
+	for _, element := range removed {
+		sortedSet.dict.Remove(element.Member)
+	}
+	return removed
+}
+
+// PopMax 删除并返回分值最大的 count 个元素, 按照分值从大到小的顺序
+func (sortedSet *SortedSet) PopMax(count int64) []*Element {
+	if count > sortedSet.Len() {
+		count = sortedSet.Len()
+	}
+	result := sortedSet.RangeByRank(0, count, true)
+	for _, element := range result {
+		sortedSet.Remove(element.Member)
+	}
+	return result
+}
+
+// ForEach 按照分值从小到大遍历所有元素, consumer 返回 false 时停止
+func (sortedSet *SortedSet) ForEach(consumer func(element *Element) bool) {
+	sortedSet.ForEachByRank(0, sortedSet.Len(), false, consumer)
+}
+
+// Scan 使用游标遍历有序集合, 返回下一次遍历的游标, 返回 0 表示遍历结束
+func (sortedSet *SortedSet) Scan(cursor uint64, consumer func(element *Element)) uint64 {
+	return sortedSet.dict.Scan(cursor, func(member string, val interface{}) {
+		consumer(val.(*Element))
+	})
+}
+
+// RandomElements 随机返回 limit 个元素, 可能包含重复的元素
+// limit 可能远大于元素个数, 预分配的容量不超过元素个数
+func (sortedSet *SortedSet) RandomElements(limit int) []*Element {
+	length := sortedSet.skiplist.length
+	if length == 0 {
+		return nil
+	}
+	size := limit
+	if int64(size) > length {
+		size = int(length)
+	}
+	result := make([]*Element, 0, size)
+	for len(result) < limit {
+		result = append(result, &sortedSet.skiplist.getByRank(rand.Int63n(length)+1).Element)
+	}
+	return result
+}
+
+// RandomDistinctElements 随机返回最多 limit 个不重复的元素
+func (sortedSet *SortedSet) RandomDistinctElements(limit int) []*Element {
+	all := sortedSet.RangeByRank(0, sortedSet.Len(), false)
+	if limit >= len(all) {
+		return all
+	}
+	// 部分 Fisher-Yates 洗牌
+	for i := 0; i < limit; i++ {
+		j := i + rand.Intn(len(all)-i)
+		all[i], all[j] = all[j], all[i]
+	}
+	return all[:limit]
+}
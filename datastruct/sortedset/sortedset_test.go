@@ -0,0 +1,269 @@
+package sortedset
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// checkSkiplist 检查跳表的结构: 每一层都有序, span 与第 0 层的距离一致, backward 和 tail 正确
+func checkSkiplist(t *testing.T, sl *skiplist) {
+	t.Helper()
+	// 第 0 层中每个节点的排名
+	ranks := make(map[*node]int64)
+	var prev *node
+	var rank int64
+	for x := sl.header.level[0].forward; x != nil; x = x.level[0].forward {
+		rank++
+		ranks[x] = rank
+		if x.backward != prev {
+			t.Fatalf("wrong backward of %s", x.Member)
+		}
+		if prev != nil && !prev.before(x.Score, x.Member) {
+			t.Fatalf("%s is not before %s", prev.Member, x.Member)
+		}
+		prev = x
+	}
+	if rank != sl.length {
+		t.Fatalf("length is %d, but there are %d nodes", sl.length, rank)
+	}
+	if sl.tail != prev {
+		t.Fatal("wrong tail")
+	}
+	for i := 0; i < sl.level; i++ {
+		x := sl.header
+		for x.level[i].forward != nil {
+			next := x.level[i].forward
+			if ranks[next]-ranks[x] != x.level[i].span {
+				t.Fatalf("wrong span at level %d from rank %d", i, ranks[x])
+			}
+			x = next
+		}
+	}
+}
+
+// sortedElements 返回按照分值和成员排序的元素, 用于和跳表比较
+func sortedElements(m map[string]float64) []*Element {
+	elements := make([]*Element, 0, len(m))
+	for member, score := range m {
+		elements = append(elements, &Element{Member: member, Score: score})
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].Score != elements[j].Score {
+			return elements[i].Score < elements[j].Score
+		}
+		return elements[i].Member < elements[j].Member
+	})
+	return elements
+}
+
+func assertElements(t *testing.T, actual []*Element, expected []*Element) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d elements, actual %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if *actual[i] != *expected[i] {
+			t.Fatalf("element %d: expected %v, actual %v", i, *expected[i], *actual[i])
+		}
+	}
+}
+
+func TestSortedSetRandomOps(t *testing.T) {
+	rand.Seed(1)
+	zset := Make()
+	model := make(map[string]float64)
+	for i := 0; i < 5000; i++ {
+		member := "m" + strconv.Itoa(rand.Intn(500))
+		// 分值范围较小, 保证有很多分值相同、按照成员排序的元素
+		score := float64(rand.Intn(50))
+		if rand.Intn(3) == 0 {
+			_, exists := model[member]
+			if zset.Remove(member) != exists {
+				t.Fatalf("remove %s: expected %v", member, exists)
+			}
+			delete(model, member)
+		} else {
+			_, exists := model[member]
+			if zset.Add(member, score) == exists {
+				t.Fatalf("add %s: expected %v", member, !exists)
+			}
+			model[member] = score
+		}
+		if i%500 == 0 {
+			checkSkiplist(t, zset.skiplist)
+		}
+	}
+	checkSkiplist(t, zset.skiplist)
+	if zset.Len() != int64(len(model)) {
+		t.Fatalf("expected len %d, actual %d", len(model), zset.Len())
+	}
+
+	expected := sortedElements(model)
+	assertElements(t, zset.RangeByRank(0, zset.Len(), false), expected)
+	for i, element := range expected {
+		rank, ok := zset.GetRank(element.Member, false)
+		if !ok || rank != int64(i) {
+			t.Fatalf("rank of %s: expected %d, actual %d", element.Member, i, rank)
+		}
+		rank, _ = zset.GetRank(element.Member, true)
+		if rank != int64(len(expected)-1-i) {
+			t.Fatalf("desc rank of %s: expected %d, actual %d", element.Member, len(expected)-1-i, rank)
+		}
+	}
+	reversed := zset.RangeByRank(0, zset.Len(), true)
+	for i := range reversed {
+		if *reversed[i] != *expected[len(expected)-1-i] {
+			t.Fatalf("desc element %d is %v", i, *reversed[i])
+		}
+	}
+}
+
+func TestSortedSetRangeByScore(t *testing.T) {
+	zset := Make()
+	model := make(map[string]float64)
+	for i := 0; i < 100; i++ {
+		member := "m" + strconv.Itoa(i)
+		zset.Add(member, float64(i/2))
+		model[member] = float64(i / 2)
+	}
+	all := sortedElements(model)
+	inRange := func(min, max float64, minExclude, maxExclude bool) []*Element {
+		var result []*Element
+		for _, element := range all {
+			if (element.Score > min || !minExclude && element.Score == min) &&
+				(element.Score < max || !maxExclude && element.Score == max) {
+				result = append(result, element)
+			}
+		}
+		return result
+	}
+	tests := []struct {
+		min, max string
+	}{
+		{"10", "20"}, {"(10", "20"}, {"10", "(20"}, {"(10", "(11"}, {"(10", "(10"},
+		{"-inf", "+inf"}, {"-inf", "3"}, {"45", "+inf"}, {"60", "+inf"}, {"20", "10"}, {"10", "10"},
+	}
+	for _, tt := range tests {
+		min, err := ParseScoreBorder(tt.min)
+		if err != nil {
+			t.Fatal(err)
+		}
+		max, err := ParseScoreBorder(tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		minBorder, maxBorder := min.(*ScoreBorder), max.(*ScoreBorder)
+		expected := inRange(minBorder.Value, maxBorder.Value, minBorder.Exclude, maxBorder.Exclude)
+		assertElements(t, zset.Range(min, max, 0, -1, false), expected)
+		if count := zset.RangeCount(min, max); count != int64(len(expected)) {
+			t.Fatalf("[%s, %s]: expected count %d, actual %d", tt.min, tt.max, len(expected), count)
+		}
+		// LIMIT offset count
+		if len(expected) > 3 {
+			assertElements(t, zset.Range(min, max, 2, 1, false), expected[2:3])
+			desc := zset.Range(min, max, 1, 2, true)
+			assertElements(t, desc, []*Element{expected[len(expected)-2], expected[len(expected)-3]})
+		}
+	}
+}
+
+func TestSortedSetRangeByLex(t *testing.T) {
+	zset := Make()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		zset.Add(member, 0)
+	}
+	tests := []struct {
+		min, max string
+		expected string
+	}{
+		{"-", "+", "abcde"},
+		{"[b", "[d", "bcd"},
+		{"(b", "[d", "cd"},
+		{"(b", "(d", "c"},
+		{"[c", "+", "cde"},
+		{"-", "(c", "ab"},
+		{"(e", "+", ""},
+		{"[d", "[b", ""},
+	}
+	for _, tt := range tests {
+		min, err := ParseLexBorder(tt.min)
+		if err != nil {
+			t.Fatal(err)
+		}
+		max, err := ParseLexBorder(tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := ""
+		for _, element := range zset.Range(min, max, 0, -1, false) {
+			actual += element.Member
+		}
+		if actual != tt.expected {
+			t.Fatalf("[%s, %s]: expected %q, actual %q", tt.min, tt.max, tt.expected, actual)
+		}
+	}
+}
+
+func TestSortedSetRemoveRange(t *testing.T) {
+	zset := Make()
+	for i := 0; i < 100; i++ {
+		zset.Add("m"+strconv.Itoa(i), float64(i))
+	}
+	min, _ := ParseScoreBorder("(10")
+	max, _ := ParseScoreBorder("20")
+	if removed := zset.RemoveRange(min, max); removed != 10 {
+		t.Fatalf("expected 10 removed, actual %d", removed)
+	}
+	checkSkiplist(t, zset.skiplist)
+	if _, ok := zset.Get("m15"); ok {
+		t.Fatal("m15 should be removed")
+	}
+	if _, ok := zset.Get("m10"); !ok {
+		t.Fatal("m10 should not be removed")
+	}
+
+	if removed := zset.RemoveByRank(0, 5); removed != 5 {
+		t.Fatalf("expected 5 removed, actual %d", removed)
+	}
+	checkSkiplist(t, zset.skiplist)
+	first := zset.RangeByRank(0, 1, false)[0]
+	if first.Member != "m5" {
+		t.Fatalf("expected m5, actual %s", first.Member)
+	}
+
+	popped := zset.PopMax(3)
+	if len(popped) != 3 || popped[0].Member != "m99" || popped[2].Member != "m97" {
+		t.Fatalf("wrong PopMax result %v", popped)
+	}
+	popped = zset.PopMin(2)
+	if len(popped) != 2 || popped[0].Member != "m5" || popped[1].Member != "m6" {
+		t.Fatalf("wrong PopMin result %v", popped)
+	}
+	checkSkiplist(t, zset.skiplist)
+	if zset.Len() != 100-10-5-3-2 {
+		t.Fatalf("wrong len %d", zset.Len())
+	}
+}
+
+func TestSortedSetRandomElements(t *testing.T) {
+	zset := Make()
+	for i := 0; i < 10; i++ {
+		zset.Add("m"+strconv.Itoa(i), float64(i))
+	}
+	distinct := zset.RandomDistinctElements(20)
+	if len(distinct) != 10 {
+		t.Fatalf("expected 10 distinct elements, actual %d", len(distinct))
+	}
+	seen := make(map[string]struct{})
+	for _, element := range distinct {
+		if _, ok := seen[element.Member]; ok {
+			t.Fatalf("duplicated element %s", element.Member)
+		}
+		seen[element.Member] = struct{}{}
+	}
+	if elements := zset.RandomElements(30); len(elements) != 30 {
+		t.Fatalf("expected 30 elements, actual %d", len(elements))
+	}
+}
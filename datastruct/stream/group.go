@@ -0,0 +1,335 @@
+package stream
+
+import "sort"
+
+/*
+ * 消费者组
+ * 每个消费者组记录最后投递的 ID 和待确认消息列表(PEL, pending entries list)
+ * 消息投递给消费者之后进入消费者组和消费者的 PEL, 直到被 XACK 确认; 两个 PEL 共享同一个 PendingEntry
+ */
+
+// InvalidEntriesRead 表示消费者组读取过的消息个数未知
+const InvalidEntriesRead = -1
+
+// PendingEntry 是已经投递但是尚未确认的消息
+type PendingEntry struct {
+	ID            ID
+	Consumer      *Consumer
+	DeliveryTime  int64 // 最后一次投递的时间, unix 毫秒
+	DeliveryCount int64 // 投递次数
+}
+
+// Consumer 是消费者组中的消费者
+type Consumer struct {
+	Name       string
+	SeenTime   int64 // 最后一次尝试读取或认领消息的时间, unix 毫秒
+	ActiveTime int64 // 最后一次成功读取或认领消息的时间, -1 表示从未成功
+	pending    *radixTree
+}
+
+// PendingLen 返回消费者的待确认消息个数
+func (c *Consumer) PendingLen() int {
+	return c.pending.size
+}
+
+// ForEachPending 按照 ID 从小到大遍历 ID 不小于 start 的待确认消息, consumer 返回 false 时停止
+func (c *Consumer) ForEachPending(start ID, consumer func(pe *PendingEntry) bool) {
+	c.pending.ascend(start.key(), func(value interface{}) bool {
+		return consumer(value.(*PendingEntry))
+	})
+}
+
+// Group 是 stream 的消费者组
+type Group struct {
+	Name        string
+	LastID      ID    // 最后投递给消费者的 ID
+	EntriesRead int64 // 读取过的消息个数, InvalidEntriesRead 表示未知
+	pending     *radixTree
+	consumers   map[string]*Consumer
+}
+
+// PendingLen 返回消费者组的待确认消息个数
+func (g *Group) PendingLen() int {
+	return g.pending.size
+}
+
+// GetPending 返回 ID 对应的待确认消息
+func (g *Group) GetPending(id ID) (*PendingEntry, bool) {
+	value, ok := g.pending.get(id.key())
+	if !ok {
+		return nil, false
+	}
+	return value.(*PendingEntry), true
+}
+
+// ForEachPending 按照 ID 从小到大遍历 ID 在 [start, end] 中的待确认消息, consumer 返回 false 时停止
+func (g *Group) ForEachPending(start ID, end ID, consumer func(pe *PendingEntry) bool) {
+	if end.Less(start) {
+		return
+	}
+	g.pending.ascend(start.key(), func(value interface{}) bool {
+		pe := value.(*PendingEntry)
+		if end.Less(pe.ID) {
+			return false
+		}
+		return consumer(pe)
+	})
+}
+
+// Consumer 返回消费者
+func (g *Group) Consumer(name string) (*Consumer, bool) {
+	c, ok := g.consumers[name]
+	return c, ok
+}
+
+// CreateConsumer 创建消费者, 消费者已经存在时返回 false
+func (g *Group) CreateConsumer(name string, nowMs int64) (*Consumer, bool) {
+	if c, ok := g.consumers[name]; ok {
+		return c, false
+	}
+	c := &Consumer{
+		Name:       name,
+		SeenTime:   nowMs,
+		ActiveTime: -1,
+		pending:    makeRadixTree(),
+	}
+	g.consumers[name] = c
+	return c, true
+}
+
+// DeleteConsumer 删除消费者以及它的待确认消息, 返回删除的待确认消息个数, 消费者不存在时 ok 为 false
+func (g *Group) DeleteConsumer(name string) (pending int, ok bool) {
+	c, ok := g.consumers[name]
+	if !ok {
+		return 0, false
+	}
+	c.pending.ascend(nil, func(value interface{}) bool {
+		g.pending.remove(value.(*PendingEntry).ID.key())
+		return true
+	})
+	delete(g.consumers, name)
+	return c.pending.size, true
+}
+
+// Consumers 返回按照名称排序的消费者
+func (g *Group) Consumers() []*Consumer {
+	result := make([]*Consumer, 0, len(g.consumers))
+	for _, c := range g.consumers {
+		result = append(result, c)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// ConsumerCount 返回消费者个数
+func (g *Group) ConsumerCount() int {
+	return len(g.consumers)
+}
+
+// Ack 确认消息, 将消息从待确认消息列表中删除, 返回消息是否在待确认消息列表中
+func (g *Group) Ack(id ID) bool {
+	pe, ok := g.GetPending(id)
+	if !ok {
+		return false
+	}
+	g.pending.remove(id.key())
+	pe.Consumer.pending.remove(id.key())
+	return true
+}
+
+// deliver 将消息投递给消费者, 消息已经在待确认消息列表中时转移给消费者并重置投递次数
+func (g *Group) deliver(id ID, c *Consumer, nowMs int64) {
+	if pe, ok := g.GetPending(id); ok {
+		pe.Consumer.pending.remove(id.key())
+		pe.Consumer = c
+		pe.DeliveryTime = nowMs
+		pe.DeliveryCount = 1
+		c.pending.insert(id.key(), pe)
+		return
+	}
+	pe := &PendingEntry{ID: id, Consumer: c, DeliveryTime: nowMs, DeliveryCount: 1}
+	g.pending.insert(id.key(), pe)
+	c.pending.insert(id.key(), pe)
+}
+
+// Claim 将待确认消息转移给消费者, 设置投递时间, incrCount 为 true 时增加投递次数
+func (g *Group) Claim(pe *PendingEntry, c *Consumer, deliveryTime int64, incrCount bool) {
+	if pe.Consumer != c {
+		pe.Consumer.pending.remove(pe.ID.key())
+		pe.Consumer = c
+		c.pending.insert(pe.ID.key(), pe)
+	}
+	pe.DeliveryTime = deliveryTime
+	if incrCount {
+		pe.DeliveryCount++
+	}
+}
+
+// AddPending 将不在待确认消息列表中的消息加入消费者的待确认消息列表, 用于 XCLAIM 的 FORCE 选项
+func (g *Group) AddPending(id ID, c *Consumer, deliveryTime int64) *PendingEntry {
+	pe := &PendingEntry{ID: id, Consumer: c, DeliveryTime: deliveryTime}
+	g.pending.insert(id.key(), pe)
+	c.pending.insert(id.key(), pe)
+	return pe
+}
+
+/* ---- Stream 上的消费者组操作 ---- */
+
+// CreateGroup 创建消费者组, 消费者组已经存在时返回 false
+func (s *Stream) CreateGroup(name string, lastID ID, entriesRead int64) (*Group, bool) {
+	if s.groups == nil {
+		s.groups = make(map[string]*Group)
+	}
+	if g, ok := s.groups[name]; ok {
+		return g, false
+	}
+	g := &Group{
+		Name:        name,
+		LastID:      lastID,
+		EntriesRead: entriesRead,
+		pending:     makeRadixTree(),
+		consumers:   make(map[string]*Consumer),
+	}
+	s.groups[name] = g
+	return g, true
+}
+
+// Group 返回消费者组
+func (s *Stream) Group(name string) (*Group, bool) {
+	g, ok := s.groups[name]
+	return g, ok
+}
+
+// DestroyGroup 删除消费者组, 返回消费者组是否存在
+func (s *Stream) DestroyGroup(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// Groups 返回按照名称排序的消费者组
+func (s *Stream) Groups() []*Group {
+	result := make([]*Group, 0, len(s.groups))
+	for _, g := range s.groups {
+		result = append(result, g)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// GroupCount 返回消费者组个数
+func (s *Stream) GroupCount() int {
+	return len(s.groups)
+}
+
+// copy 返回消费者组的副本, 待确认消息同样在消费者组和消费者之间共享
+func (g *Group) copy() *Group {
+	result := &Group{
+		Name:        g.Name,
+		LastID:      g.LastID,
+		EntriesRead: g.EntriesRead,
+		pending:     makeRadixTree(),
+		consumers:   make(map[string]*Consumer, len(g.consumers)),
+	}
+	for name, c := range g.consumers {
+		result.consumers[name] = &Consumer{
+			Name:       c.Name,
+			SeenTime:   c.SeenTime,
+			ActiveTime: c.ActiveTime,
+			pending:    makeRadixTree(),
+		}
+	}
+	g.pending.ascend(nil, func(value interface{}) bool {
+		pe := *value.(*PendingEntry)
+		pe.Consumer = result.consumers[pe.Consumer.Name]
+		result.pending.insert(pe.ID.key(), &pe)
+		pe.Consumer.pending.insert(pe.ID.key(), &pe)
+		return true
+	})
+	return result
+}
+
+// ReadGroup 将 ID 大于消费者组的 LastID 的最多 count 条消息投递给消费者, count 小于等于 0 时不限制
+// noAck 为 true 时消息不进入待确认消息列表
+func (s *Stream) ReadGroup(g *Group, c *Consumer, count int64, noAck bool, nowMs int64) []*Entry {
+	start, ok := g.LastID.Incr()
+	if !ok {
+		return nil
+	}
+	entries := s.Range(start, MaxID, count, false)
+	for _, entry := range entries {
+		if g.EntriesRead != InvalidEntriesRead && !s.rangeHasTombstones(entry.ID) {
+			g.EntriesRead++
+		} else if s.entriesAdded > 0 {
+			g.EntriesRead = s.estimateEntriesRead(entry.ID)
+		}
+		g.LastID = entry.ID
+		if !noAck {
+			g.deliver(entry.ID, c, nowMs)
+		}
+	}
+	if len(entries) > 0 {
+		c.ActiveTime = nowMs
+	}
+	return entries
+}
+
+// Lag 返回消费者组尚未读取的消息个数, 无法计算时 ok 为 false
+func (s *Stream) Lag(g *Group) (lag int64, ok bool) {
+	if s.entriesAdded == 0 {
+		return 0, true
+	}
+	if g.EntriesRead != InvalidEntriesRead && !s.rangeHasTombstones(g.LastID) {
+		return s.entriesAdded - g.EntriesRead, true
+	}
+	entriesRead := s.estimateEntriesRead(g.LastID)
+	if entriesRead == InvalidEntriesRead {
+		return 0, false
+	}
+	return s.entriesAdded - entriesRead, true
+}
+
+// rangeHasTombstones 判断 ID 不小于 start 的范围内是否可能有被 XDEL 删除的消息
+func (s *Stream) rangeHasTombstones(start ID) bool {
+	if s.Len() == 0 || s.maxDeletedID == MinID {
+		return false
+	}
+	return !s.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead 估算从第一条消息读到 id 为止读取过的消息个数, 无法估算时返回 InvalidEntriesRead
+func (s *Stream) estimateEntriesRead(id ID) int64 {
+	if s.entriesAdded == 0 {
+		return 0
+	}
+	cmpLast := id.Compare(s.lastID)
+	if s.Len() == 0 && cmpLast <= 0 {
+		return s.entriesAdded
+	}
+	if cmpLast == 0 {
+		return s.entriesAdded
+	}
+	if cmpLast > 0 {
+		return InvalidEntriesRead
+	}
+	firstID := MinID
+	if first := s.FirstEntry(); first != nil {
+		firstID = first.ID
+	}
+	// 没有删除过消息, 或者被删除的消息都在第一条消息之前时, 才能根据消息个数计算
+	if s.maxDeletedID == MinID || s.maxDeletedID.Less(firstID) {
+		switch id.Compare(firstID) {
+		case -1:
+			return s.entriesAdded - s.Len()
+		case 0:
+			return s.entriesAdded - s.Len() + 1
+		}
+	}
+	return InvalidEntriesRead
+}
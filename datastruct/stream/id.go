@@ -0,0 +1,115 @@
+package stream
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ID 是消息的 ID, 由毫秒时间戳和同一毫秒内的序号组成, 格式为 <ms>-<seq>
+type ID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+// 最小和最大的 ID
+var (
+	MinID = ID{}
+	MaxID = ID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+// ErrInvalidID 是 ID 格式错误时返回的错误
+var ErrInvalidID = errors.New("ERR Invalid stream ID specified as stream command argument")
+
+// String 返回 <ms>-<seq> 格式的 ID
+func (id ID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Compare 比较两个 ID, id 小于、等于、大于 other 时分别返回 -1、0、1
+func (id ID) Compare(other ID) int {
+	switch {
+	case id.Ms < other.Ms:
+		return -1
+	case id.Ms > other.Ms:
+		return 1
+	case id.Seq < other.Seq:
+		return -1
+	case id.Seq > other.Seq:
+		return 1
+	}
+	return 0
+}
+
+// Less 判断 id 是否小于 other
+func (id ID) Less(other ID) bool {
+	return id.Compare(other) < 0
+}
+
+// Incr 返回下一个 ID, id 已经是最大的 ID 时 ok 为 false
+func (id ID) Incr() (next ID, ok bool) {
+	if id == MaxID {
+		return id, false
+	}
+	if id.Seq == math.MaxUint64 {
+		return ID{Ms: id.Ms + 1}, true
+	}
+	return ID{Ms: id.Ms, Seq: id.Seq + 1}, true
+}
+
+// Decr 返回上一个 ID, id 已经是最小的 ID 时 ok 为 false
+func (id ID) Decr() (prev ID, ok bool) {
+	if id == MinID {
+		return id, false
+	}
+	if id.Seq == 0 {
+		return ID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	}
+	return ID{Ms: id.Ms, Seq: id.Seq - 1}, true
+}
+
+// key 返回大端序编码的 ID, 字节序与 ID 的大小顺序一致, 用作基数树的 key
+func (id ID) key() []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf, id.Ms)
+	binary.BigEndian.PutUint64(buf[8:], id.Seq)
+	return buf
+}
+
+// ParseID 解析 <ms>-<seq> 或者 <ms> 格式的 ID, 省略 seq 时使用 missingSeq
+func ParseID(s string, missingSeq uint64) (ID, error) {
+	ms, seq := s, ""
+	hasSeq := false
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		ms, seq, hasSeq = s[:i], s[i+1:], true
+	}
+	id := ID{Seq: missingSeq}
+	var err error
+	id.Ms, err = strconv.ParseUint(ms, 10, 64)
+	if err != nil {
+		return ID{}, ErrInvalidID
+	}
+	if hasSeq {
+		id.Seq, err = strconv.ParseUint(seq, 10, 64)
+		if err != nil {
+			return ID{}, ErrInvalidID
+		}
+	}
+	return id, nil
+}
+
+// ParseAddID 解析 XADD 的 ID, 可以是 <ms>-<seq>、<ms> 或者 <ms>-*
+// 格式为 <ms>-* 时 seqGiven 为 false, 序号由 Stream.Add 自动生成
+func ParseAddID(s string) (id ID, seqGiven bool, err error) {
+	if ms := strings.TrimSuffix(s, "-*"); ms != s {
+		id.Ms, err = strconv.ParseUint(ms, 10, 64)
+		if err != nil {
+			return ID{}, false, ErrInvalidID
+		}
+		return id, false, nil
+	}
+	id, err = ParseID(s, 0)
+	return id, true, err
+}
@@ -0,0 +1,217 @@
+package stream
+
+import (
+	"bytes"
+	"sort"
+)
+
+/*
+ * 压缩前缀的基数树(radix tree), 与 redis 的 rax 类似, 用于按照 ID 的顺序存储消息和待确认消息
+ * 所有 key 的长度相同(16 字节的大端序 ID), 因此不存在一个 key 是另一个 key 的前缀的情况, 值只保存在叶子节点中
+ * 同一毫秒内以及相邻毫秒内生成的 ID 有很长的公共前缀, 这些前缀只保存一次
+ */
+
+// radixNode 是基数树的节点
+type radixNode struct {
+	prefix   []byte       // 从父节点到当前节点的边上的字节, 根节点为空
+	children []*radixNode // 按照 prefix[0] 排序
+	value    interface{}  // 仅叶子节点有值, 不能为 nil
+}
+
+type radixTree struct {
+	root  *radixNode
+	size  int
+	nodes int // 节点个数, 包括根节点
+}
+
+func makeRadixTree() *radixTree {
+	return &radixTree{
+		root:  &radixNode{},
+		nodes: 1,
+	}
+}
+
+// commonPrefixLen 返回 a 和 b 的公共前缀的长度
+func commonPrefixLen(a []byte, b []byte) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+// childIndex 返回以 c 开头的子节点在 children 中的位置, 以及该子节点是否存在
+func (n *radixNode) childIndex(c byte) (int, bool) {
+	i := sort.Search(len(n.children), func(i int) bool {
+		return n.children[i].prefix[0] >= c
+	})
+	return i, i < len(n.children) && n.children[i].prefix[0] == c
+}
+
+// insertChild 在 children 的第 i 个位置插入子节点
+func (n *radixNode) insertChild(i int, child *radixNode) {
+	n.children = append(n.children, nil)
+	copy(n.children[i+1:], n.children[i:])
+	n.children[i] = child
+}
+
+// insert 插入或者替换 key 对应的值, 返回 key 是否是新插入的
+func (tree *radixTree) insert(key []byte, value interface{}) bool {
+	n := tree.root
+	for {
+		i, found := n.childIndex(key[0])
+		if !found {
+			leaf := &radixNode{prefix: append([]byte(nil), key...), value: value}
+			n.insertChild(i, leaf)
+			tree.size++
+			tree.nodes++
+			return true
+		}
+		child := n.children[i]
+		common := commonPrefixLen(child.prefix, key)
+		if common == len(child.prefix) {
+			key = key[common:]
+			if len(key) == 0 {
+				child.value = value
+				return false
+			}
+			n = child
+			continue
+		}
+		// 分裂子节点: 公共前缀成为新的中间节点
+		mid := &radixNode{prefix: child.prefix[:common:common]}
+		child.prefix = child.prefix[common:]
+		leaf := &radixNode{prefix: append([]byte(nil), key[common:]...), value: value}
+		if leaf.prefix[0] < child.prefix[0] {
+			mid.children = []*radixNode{leaf, child}
+		} else {
+			mid.children = []*radixNode{child, leaf}
+		}
+		n.children[i] = mid
+		tree.size++
+		tree.nodes += 2
+		return true
+	}
+}
+
+// get 返回 key 对应的值
+func (tree *radixTree) get(key []byte) (interface{}, bool) {
+	n := tree.root
+	for len(key) > 0 {
+		i, found := n.childIndex(key[0])
+		if !found {
+			return nil, false
+		}
+		child := n.children[i]
+		if !bytes.HasPrefix(key, child.prefix) {
+			return nil, false
+		}
+		key = key[len(child.prefix):]
+		n = child
+	}
+	return n.value, n.value != nil
+}
+
+// remove 删除 key, 返回 key 是否存在
+// 删除后没有子节点的节点会被删除, 只有一个子节点的节点会与子节点合并, 保持前缀压缩
+func (tree *radixTree) remove(key []byte) bool {
+	path := []*radixNode{tree.root} // 从根节点到叶子节点的路径
+	n := tree.root
+	for len(key) > 0 {
+		i, found := n.childIndex(key[0])
+		if !found {
+			return false
+		}
+		child := n.children[i]
+		if !bytes.HasPrefix(key, child.prefix) {
+			return false
+		}
+		key = key[len(child.prefix):]
+		n = child
+		path = append(path, n)
+	}
+	if n.value == nil {
+		return false
+	}
+	n.value = nil
+	tree.size--
+
+	// 从下往上删除没有子节点的节点
+	for len(path) > 1 {
+		child := path[len(path)-1]
+		parent := path[len(path)-2]
+		if len(child.children) > 0 {
+			break
+		}
+		i, _ := parent.childIndex(child.prefix[0])
+		parent.children = append(parent.children[:i], parent.children[i+1:]...)
+		tree.nodes--
+		path = path[:len(path)-1]
+		if len(parent.children) > 0 {
+			break
+		}
+	}
+	// 合并只剩一个子节点的中间节点
+	if last := path[len(path)-1]; last != tree.root && len(last.children) == 1 {
+		only := last.children[0]
+		last.prefix = append(append([]byte(nil), last.prefix...), only.prefix...)
+		last.children = only.children
+		last.value = only.value
+		tree.nodes--
+	}
+	return true
+}
+
+// ascend 按照 key 从小到大遍历不小于 from 的值, from 为 nil 时从头开始, consumer 返回 false 时停止
+func (tree *radixTree) ascend(from []byte, consumer func(value interface{}) bool) {
+	tree.root.ascend(nil, from, consumer)
+}
+
+func (n *radixNode) ascend(path []byte, from []byte, consumer func(value interface{}) bool) bool {
+	if from != nil {
+		// 当前子树的所有 key 都以 path 开头
+		cmp := bytes.Compare(path, from[:len(path)])
+		if cmp < 0 {
+			return true
+		}
+		if cmp > 0 {
+			from = nil
+		}
+	}
+	if n.value != nil {
+		return consumer(n.value)
+	}
+	for _, child := range n.children {
+		if !child.ascend(append(path[:len(path):len(path)], child.prefix...), from, consumer) {
+			return false
+		}
+	}
+	return true
+}
+
+// descend 按照 key 从大到小遍历不大于 from 的值, from 为 nil 时从末尾开始, consumer 返回 false 时停止
+func (tree *radixTree) descend(from []byte, consumer func(value interface{}) bool) {
+	tree.root.descend(nil, from, consumer)
+}
+
+func (n *radixNode) descend(path []byte, from []byte, consumer func(value interface{}) bool) bool {
+	if from != nil {
+		cmp := bytes.Compare(path, from[:len(path)])
+		if cmp > 0 {
+			return true
+		}
+		if cmp < 0 {
+			from = nil
+		}
+	}
+	if n.value != nil {
+		return consumer(n.value)
+	}
+	for i := len(n.children) - 1; i >= 0; i-- {
+		child := n.children[i]
+		if !child.descend(append(path[:len(path):len(path)], child.prefix...), from, consumer) {
+			return false
+		}
+	}
+	return true
+}
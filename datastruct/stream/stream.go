@@ -0,0 +1,230 @@
+package stream
+
+import "errors"
+
+// EncodingStream 编码名称, 与 OBJECT ENCODING 的输出一致
+const EncodingStream = "stream"
+
+// Add 返回的错误
+var (
+	ErrIDTooSmall = errors.New("ERR The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrExhausted  = errors.New("ERR The stream has exhausted the last possible ID, unable to add more items")
+)
+
+// Entry 是 stream 中的一条消息
+type Entry struct {
+	ID     ID
+	Fields []string // field1, value1, field2, value2, ...
+}
+
+// Stream 是 redis 的 stream 类型, 消息按照 ID 的顺序存储在基数树中, 不是线程安全的
+type Stream struct {
+	entries      *radixTree
+	lastID       ID    // 最后生成的 ID, 删除消息不会改变它
+	maxDeletedID ID    // XDEL 删除的最大的 ID
+	entriesAdded int64 // 添加过的消息总数
+	groups       map[string]*Group
+}
+
+// Make 创建空的 stream
+func Make() *Stream {
+	return &Stream{
+		entries: makeRadixTree(),
+	}
+}
+
+// Encoding 返回当前的编码
+func (s *Stream) Encoding() string {
+	return EncodingStream
+}
+
+// Len 返回消息个数
+func (s *Stream) Len() int64 {
+	return int64(s.entries.size)
+}
+
+// Copy 返回 stream 的副本, 包括消费者组; 消息不会被修改, 因此与原 stream 共享
+func (s *Stream) Copy() *Stream {
+	result := &Stream{
+		entries:      makeRadixTree(),
+		lastID:       s.lastID,
+		maxDeletedID: s.maxDeletedID,
+		entriesAdded: s.entriesAdded,
+	}
+	s.entries.ascend(nil, func(value interface{}) bool {
+		entry := value.(*Entry)
+		result.entries.insert(entry.ID.key(), entry)
+		return true
+	})
+	if s.groups != nil {
+		result.groups = make(map[string]*Group, len(s.groups))
+		for name, g := range s.groups {
+			result.groups[name] = g.copy()
+		}
+	}
+	return result
+}
+
+// LastID 返回最后生成的 ID
+func (s *Stream) LastID() ID {
+	return s.lastID
+}
+
+// MaxDeletedID 返回被删除的最大的 ID
+func (s *Stream) MaxDeletedID() ID {
+	return s.maxDeletedID
+}
+
+// EntriesAdded 返回添加过的消息总数, 包括已经被删除的消息
+func (s *Stream) EntriesAdded() int64 {
+	return s.entriesAdded
+}
+
+// RadixTreeStats 返回基数树中 key 和节点的个数
+func (s *Stream) RadixTreeStats() (keys int, nodes int) {
+	return s.entries.size, s.entries.nodes
+}
+
+// FirstEntry 返回第一条消息, stream 为空时返回 nil
+func (s *Stream) FirstEntry() *Entry {
+	var first *Entry
+	s.entries.ascend(nil, func(value interface{}) bool {
+		first = value.(*Entry)
+		return false
+	})
+	return first
+}
+
+// LastEntry 返回最后一条消息, stream 为空时返回 nil
+func (s *Stream) LastEntry() *Entry {
+	var last *Entry
+	s.entries.descend(nil, func(value interface{}) bool {
+		last = value.(*Entry)
+		return false
+	})
+	return last
+}
+
+// Get 返回 ID 对应的消息
+func (s *Stream) Get(id ID) (*Entry, bool) {
+	value, ok := s.entries.get(id.key())
+	if !ok {
+		return nil, false
+	}
+	return value.(*Entry), true
+}
+
+// Add 添加消息, 返回消息的 ID
+// idGiven 为 false 时根据当前时间 nowMs 生成 ID; seqGiven 为 false 时使用 id.Ms, 由 stream 生成序号
+// 新的 ID 必须大于 LastID
+func (s *Stream) Add(id ID, idGiven bool, seqGiven bool, nowMs uint64, fields []string) (ID, error) {
+	if s.lastID == MaxID {
+		return ID{}, ErrExhausted
+	}
+	switch {
+	case !idGiven:
+		if nowMs > s.lastID.Ms {
+			id = ID{Ms: nowMs}
+		} else {
+			id, _ = s.lastID.Incr()
+		}
+	case !seqGiven:
+		if id.Ms == s.lastID.Ms {
+			next, ok := s.lastID.Incr()
+			if !ok || next.Ms != id.Ms {
+				return ID{}, ErrIDTooSmall
+			}
+			id = next
+		}
+	}
+	if id.Compare(s.lastID) <= 0 {
+		return ID{}, ErrIDTooSmall
+	}
+	s.entries.insert(id.key(), &Entry{ID: id, Fields: fields})
+	s.lastID = id
+	s.entriesAdded++
+	return id, nil
+}
+
+// Delete 删除消息, 返回消息是否存在
+func (s *Stream) Delete(id ID) bool {
+	if !s.entries.remove(id.key()) {
+		return false
+	}
+	if s.maxDeletedID.Less(id) {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// ForEachInRange 遍历 ID 在 [start, end] 中的消息, desc 为 true 时从 end 开始遍历, consumer 返回 false 时停止
+func (s *Stream) ForEachInRange(start ID, end ID, desc bool, consumer func(entry *Entry) bool) {
+	if end.Less(start) {
+		return
+	}
+	if desc {
+		s.entries.descend(end.key(), func(value interface{}) bool {
+			entry := value.(*Entry)
+			if entry.ID.Less(start) {
+				return false
+			}
+			return consumer(entry)
+		})
+		return
+	}
+	s.entries.ascend(start.key(), func(value interface{}) bool {
+		entry := value.(*Entry)
+		if end.Less(entry.ID) {
+			return false
+		}
+		return consumer(entry)
+	})
+}
+
+// Range 返回 ID 在 [start, end] 中的最多 count 条消息, count 小于等于 0 时不限制
+func (s *Stream) Range(start ID, end ID, count int64, desc bool) []*Entry {
+	var result []*Entry
+	s.ForEachInRange(start, end, desc, func(entry *Entry) bool {
+		result = append(result, entry)
+		return count <= 0 || int64(len(result)) < count
+	})
+	return result
+}
+
+// trim 从头开始删除满足 shouldRemove 的消息, 最多删除 limit 条, limit 小于等于 0 时不限制
+func (s *Stream) trim(limit int64, shouldRemove func(entry *Entry) bool) int64 {
+	var removed []ID
+	s.entries.ascend(nil, func(value interface{}) bool {
+		entry := value.(*Entry)
+		if (limit > 0 && int64(len(removed)) >= limit) || !shouldRemove(entry) {
+			return false
+		}
+		removed = append(removed, entry.ID)
+		return true
+	})
+	for _, id := range removed {
+		s.entries.remove(id.key())
+	}
+	return int64(len(removed))
+}
+
+// TrimByMaxLen 删除最早的消息直到消息个数不超过 maxLen, 最多删除 limit 条, 返回删除的个数
+func (s *Stream) TrimByMaxLen(maxLen int64, limit int64) int64 {
+	excess := s.Len() - maxLen
+	if excess <= 0 {
+		return 0
+	}
+	if limit <= 0 || limit > excess {
+		limit = excess
+	}
+	return s.trim(limit, func(entry *Entry) bool {
+		return true
+	})
+}
+
+// TrimByMinID 删除 ID 小于 minID 的消息, 最多删除 limit 条, 返回删除的个数
+func (s *Stream) TrimByMinID(minID ID, limit int64) int64 {
+	return s.trim(limit, func(entry *Entry) bool {
+		return entry.ID.Less(minID)
+	})
+}
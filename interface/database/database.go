@@ -15,4 +15,11 @@ type Database interface {
 // DataEntity存储绑定到键的数据，包括字符串、列表、哈希、集合等
 type DataEntity struct {
 	Data interface{}
+
+	// 访问信息, 由 DB 在读写 key 时维护, 用于 OBJECT IDLETIME/FREQ
+	AccessTime int64 // 最后一次访问的时间, unix 毫秒
+	Freq       uint8 // 对数访问计数器, 仅在使用 LFU 淘汰策略时维护
+
+	// 已经计入 DB 已用内存的估算大小, 单位为字节, 用于 maxmemory
+	Memory int64
 }
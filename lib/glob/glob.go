@@ -0,0 +1,144 @@
+package glob
+
+/*
+ * 与 redis 的 stringmatchlen 相同的 glob 匹配, 供 KEYS、SCAN MATCH、PSUBSCRIBE 等使用
+ *   *      匹配任意个字符, 包括 '/'
+ *   ?      匹配一个字符
+ *   [abc]  匹配括号中的一个字符, [^abc] 匹配不在括号中的字符, [a-z] 匹配范围内的字符, 范围的两端可以颠倒
+ *   \x     匹配字符 x 本身, 在括号中同样可以使用
+ * 与 path.Match 不同, 格式错误的模式不会返回错误: 没有闭合的 '[' 匹配到模式的末尾, 结尾的 '\' 匹配它本身
+ */
+
+// maxNesting 是 '*' 的最大嵌套层数, 防止恶意构造的模式占用过多的栈空间
+const maxNesting = 1000
+
+// Match 判断 s 是否匹配模式 pattern
+func Match(pattern string, s string) bool {
+	skipLongerMatches := false
+	return match(pattern, s, false, &skipLongerMatches, 0)
+}
+
+// MatchNoCase 判断 s 是否匹配模式 pattern, 忽略 ASCII 字母的大小写
+func MatchNoCase(pattern string, s string) bool {
+	skipLongerMatches := false
+	return match(pattern, s, true, &skipLongerMatches, 0)
+}
+
+// toLower 将 ASCII 大写字母转换为小写
+func toLower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+func equal(a byte, b byte, nocase bool) bool {
+	if nocase {
+		return toLower(a) == toLower(b)
+	}
+	return a == b
+}
+
+// match 是 stringmatchlen 的实现
+// 当模式中某个 '*' 之后的部分无法从字符串的任何位置开始匹配时, 设置 skipLongerMatches,
+// 此时前面的 '*' 匹配更长的子串也不可能成功, 可以立即返回, 避免 "a*a*a*a*b" 这样的模式导致指数级的回溯
+func match(pattern string, s string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > maxNesting {
+		return false
+	}
+	// at 返回模式中下标为 i 的字符, 越界时返回 0, 与 C 字符串的结尾相同
+	at := func(i int) byte {
+		if i < len(pattern) {
+			return pattern[i]
+		}
+		return 0
+	}
+
+	p, i := 0, 0
+	for p < len(pattern) && i < len(s) {
+		switch pattern[p] {
+		case '*':
+			// 连续的 '*' 等同于一个
+			for at(p+1) == '*' {
+				p++
+			}
+			if p == len(pattern)-1 {
+				return true
+			}
+			for ; i < len(s); i++ {
+				if match(pattern[p+1:], s[i:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+			}
+			*skipLongerMatches = true
+			return false
+		case '?':
+			i++
+		case '[':
+			p++
+			not := at(p) == '^'
+			if not {
+				p++
+			}
+			matched := false
+			for {
+				if at(p) == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == s[i] {
+						matched = true
+					}
+				} else if at(p) == ']' {
+					break
+				} else if p >= len(pattern) {
+					// 没有闭合的 '[', 回退一个字符使得循环结束之后的 p++ 指向模式的末尾
+					p--
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], s[i]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLower(start), toLower(end), toLower(c)
+					}
+					p += 2
+					if c >= start && c <= end {
+						matched = true
+					}
+				} else if equal(pattern[p], s[i], nocase) {
+					matched = true
+				}
+				p++
+			}
+			if not {
+				matched = !matched
+			}
+			if !matched {
+				return false
+			}
+			i++
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p++
+			}
+			fallthrough
+		default:
+			if !equal(pattern[p], s[i], nocase) {
+				return false
+			}
+			i++
+		}
+		p++
+		if i == len(s) {
+			// 字符串已经结束, 剩余的模式只能是 '*'
+			for at(p) == '*' {
+				p++
+			}
+			break
+		}
+	}
+	return p == len(pattern) && i == len(s)
+}
@@ -0,0 +1,102 @@
+package glob
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		matched bool
+	}{
+		// 与 stringmatchlen 相同, 空字符串不匹配任何非空的模式, KEYS * 和 SCAN 不经过匹配
+		{"*", "", false},
+		{"*", "anything", true},
+		{"", "", true},
+		{"", "a", false},
+		{"a", "", false},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"a*", "a", true},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"a**c", "abc", true},
+		{"*/*", "a/b", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"?", "", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-b]llo", "hbllo", true},
+		{"h[a-b]llo", "hcllo", false},
+		// 范围的两端可以颠倒
+		{"h[b-a]llo", "hallo", true},
+		{"[]]", "]", false},
+		{"\\*", "*", true},
+		{"\\*", "a", false},
+		{"a\\?c", "a?c", true},
+		{"a\\?c", "abc", false},
+		{"[\\]]", "]", true},
+		{"[\\-]", "-", true},
+		// 没有闭合的 '[' 匹配到模式的末尾
+		{"[abc", "a", true},
+		{"[abc", "d", false},
+		// 结尾的 '\' 匹配它本身
+		{"a\\", "a\\", true},
+		{"user:*:name", "user:1000:name", true},
+		{"user:*:name", "user:1000:age", false},
+	}
+	for _, tt := range tests {
+		if actual := Match(tt.pattern, tt.s); actual != tt.matched {
+			t.Errorf("Match(%q, %q): expected %v, actual %v", tt.pattern, tt.s, tt.matched, actual)
+		}
+	}
+}
+
+func TestMatchNoCase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		matched bool
+	}{
+		{"HELLO", "hello", true},
+		{"h*O", "HELLO", true},
+		{"h[A-C]llo", "hbllo", true},
+		{"h[a-c]llo", "HBLLO", true},
+		{"h[^B]llo", "hbllo", false},
+		{"abc", "abd", false},
+	}
+	for _, tt := range tests {
+		if actual := MatchNoCase(tt.pattern, tt.s); actual != tt.matched {
+			t.Errorf("MatchNoCase(%q, %q): expected %v, actual %v", tt.pattern, tt.s, tt.matched, actual)
+		}
+	}
+	if Match("HELLO", "hello") {
+		t.Error("Match should be case sensitive")
+	}
+}
+
+// 大量的 '*' 不能导致指数级的回溯, 过深的嵌套直接返回不匹配
+func TestMatchPathological(t *testing.T) {
+	pattern := strings.Repeat("a*", 30) + "b"
+	s := strings.Repeat("a", 60)
+	start := time.Now()
+	if Match(pattern, s) {
+		t.Fatal("pattern should not match")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("match takes %v", elapsed)
+	}
+
+	if Match(strings.Repeat("*", maxNesting*2)+"b", strings.Repeat("a", maxNesting*2)) {
+		t.Fatal("pattern should not match")
+	}
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+ * Locks 是按照 key 的哈希值分配的锁表, 用于在执行命令期间锁定命令涉及的所有 key
+ * 不同的 key 可能共用同一把锁, 因此锁定多个 key 时需要先对锁的下标去重,
+ * 并且总是按照下标从小到大的顺序加锁, 同时锁定多个 key 的协程之间不会因为加锁顺序不同而死锁
+ */
+
+// Locks 是 key 锁表
+type Locks struct {
+	table []*sync.Mutex
+}
+
+// Make 创建包含 tableSize 把锁的锁表
+func Make(tableSize int) *Locks {
+	if tableSize < 1 {
+		tableSize = 1
+	}
+	table := make([]*sync.Mutex, tableSize)
+	for i := range table {
+		table[i] = &sync.Mutex{}
+	}
+	return &Locks{table: table}
+}
+
+// fnv32 计算 key 的 FNV-1a 哈希值
+func fnv32(key string) uint32 {
+	const prime32 = uint32(16777619)
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= prime32
+	}
+	return hash
+}
+
+// toLockIndices 返回 keys 对应的锁的下标, 去重后从小到大排序
+func (locks *Locks) toLockIndices(keys []string) []int {
+	set := make(map[int]struct{}, len(keys))
+	for _, key := range keys {
+		set[int(fnv32(key)%uint32(len(locks.table)))] = struct{}{}
+	}
+	indices := make([]int, 0, len(set))
+	for index := range set {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// Locks 锁定所有的 key, key 可以重复
+func (locks *Locks) Locks(keys ...string) {
+	for _, index := range locks.toLockIndices(keys) {
+		locks.table[index].Lock()
+	}
+}
+
+// Unlocks 释放 Locks 锁定的所有 key
+func (locks *Locks) Unlocks(keys ...string) {
+	indices := locks.toLockIndices(keys)
+	for i := len(indices) - 1; i >= 0; i-- {
+		locks.table[indices[i]].Unlock()
+	}
+}
@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestToLockIndices(t *testing.T) {
+	locks := Make(16)
+	indices := locks.toLockIndices([]string{"a", "b", "a", "c", "b"})
+	for i := 1; i < len(indices); i++ {
+		if indices[i-1] >= indices[i] {
+			t.Fatalf("indices %v are not sorted or not distinct", indices)
+		}
+	}
+	if len(indices) > 3 {
+		t.Fatalf("expected at most 3 indices, actual %v", indices)
+	}
+}
+
+// 多个协程以不同的顺序锁定有重叠的多个 key, 不能死锁, 并且锁定期间对 key 的访问是互斥的
+func TestLocksNoDeadlock(t *testing.T) {
+	locks := Make(8)
+	// 每个 key 的计数器只在持有这个 key 的锁时修改
+	var counters [20]int
+	const workers, rounds = 8, 2000
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(seed))
+			for i := 0; i < rounds; i++ {
+				first := r.Intn(len(counters))
+				keys := []string{"key" + strconv.Itoa(first)}
+				for j := r.Intn(4); j > 0; j-- {
+					keys = append(keys, "key"+strconv.Itoa(r.Intn(len(counters))))
+				}
+				locks.Locks(keys...)
+				counters[first]++
+				locks.Unlocks(keys...)
+			}
+		}(int64(w))
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock")
+	}
+	total := 0
+	for _, n := range counters {
+		total += n
+	}
+	if total != workers*rounds {
+		t.Fatalf("expected %d increments, actual %d", workers*rounds, total)
+	}
+}
@@ -12,10 +12,12 @@ import (
 
 const configFile string = "redis.conf"
 
-// 默认配置
-var defaultProperties = &config.ServerProperties{
-	Bind: "0.0.0.0",
-	Port: 6399,
+// defaultProperties 返回没有配置文件时使用的默认配置
+func defaultProperties() *config.ServerProperties {
+	properties := config.DefaultProperties()
+	properties.Bind = "0.0.0.0"
+	properties.Port = 6399
+	return properties
 }
 
 func fileExists(filename string) bool {
@@ -38,7 +40,7 @@ func main() {
 		config.SetupConfig(configFile)
 		// 如果配置文件不存在，则使用默认配置
 	} else {
-		config.Properties = defaultProperties
+		config.Properties = defaultProperties()
 	}
 	logger.Info("config: %+v", config.Properties)
 	// 启动服务
@@ -5,15 +5,17 @@ import (
 	"sync"
 	"time"
 
+	"github.com/LynchQ/my-go-redis/lib/sync/atomic"
 	"github.com/LynchQ/my-go-redis/lib/sync/wait"
 )
 
 // Connection 表示使用redis-cli的连接
 type Connection struct {
-	conn         net.Conn   // 与客户端的连接
-	waitingReply wait.Wait  // 等待回复完成
-	mu           sync.Mutex // 处理发送响应时的锁
-	selectedDB   int        // 选择的数据库
+	conn         net.Conn       // 与客户端的连接
+	waitingReply wait.Wait      // 等待回复完成
+	mu           sync.Mutex     // 处理发送响应时的锁
+	selectedDB   int            // 选择的数据库
+	closed       atomic.Boolean // 客户端已经断开连接
 }
 
 // NewConn 创建一个新的连接 接收一个net.Conn 作为参数 返回一个指向Connection的指针
@@ -30,12 +32,30 @@ func (c *Connection) RemoteAddr() net.Addr {
 
 // Close 断开与客户端的连接
 func (c *Connection) Close() error {
+	c.closed.Set(true)
 	// 等待10秒
 	c.waitingReply.WaitWithTimeout(10 * time.Second)
 	_ = c.conn.Close()
 	return nil
 }
 
+// Disconnect 立即关闭与客户端的连接, 不等待正在发送的回复, 用于断开无法及时读取回复的客户端
+// 客户端的读取随后会失败, 由处理程序完成关闭
+func (c *Connection) Disconnect() {
+	c.closed.Set(true)
+	_ = c.conn.Close()
+}
+
+// MarkClosed 记录客户端已经断开连接, 用于读取连接失败但处理程序尚未关闭连接时
+func (c *Connection) MarkClosed() {
+	c.closed.Set(true)
+}
+
+// IsClosed 判断客户端是否已经断开连接
+func (c *Connection) IsClosed() bool {
+	return c.closed.Get()
+}
+
 // Write 通过tcp连接向客户端写入发送响应
 func (c *Connection) Write(b []byte) error {
 	if len(b) == 0 {
@@ -32,8 +32,8 @@ type RespHandler struct {
 
 // MakeHandler创建RespHandler实例
 func MakeHandler() *RespHandler {
-	// var db databaseface.Database
-	db := database.NewEchoDatabase()
+	var db databaseface.Database
+	db = database.NewStandaloneDatabase()
 	return &RespHandler{
 		db: db,
 	}
@@ -45,6 +45,21 @@ func (h *RespHandler) closeClient(client *connection.Connection) {
 	h.activeConn.Delete(client)   // 删除客户端
 }
 
+// closeNotifyReader 在读取失败(通常是客户端断开连接)时调用 onClose
+type closeNotifyReader struct {
+	io.Reader
+	onClose func()
+	once    sync.Once
+}
+
+func (r *closeNotifyReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil {
+		r.once.Do(r.onClose)
+	}
+	return n, err
+}
+
 // Handle接收并执行redis命令
 func (h *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 	if h.closing.Get() {
@@ -57,7 +72,15 @@ func (h *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 	h.activeConn.Store(client, 1) // 存储客户端
 
 	// 解析流
-	ch := parser.ParseStream(conn)
+	ch := parser.ParseStream(&closeNotifyReader{
+		Reader: conn,
+		onClose: func() {
+			// 客户端阻塞时不会读取 ch, 需要在读取失败时立即释放阻塞状态
+			// 先记录断开连接, 之后才执行的阻塞命令不会再阻塞客户端
+			client.MarkClosed()
+			h.db.AfterClientClose(client)
+		},
+	})
 	for payload := range ch {
 		// 解析错误
 		if payload.Err != nil {
@@ -99,6 +122,14 @@ func (h *RespHandler) Handle(ctx context.Context, conn net.Conn) {
 		} else {
 			_ = client.Write(unknownErrReplyBytes)
 		}
+		// QUIT 在发送回复之后关闭连接, 之后收到的命令不再执行
+		if strings.ToLower(string(reply.Args[0])) == "quit" {
+			h.closeClient(client)
+			for range ch {
+			}
+			logger.Info("connection closed: " + client.RemoteAddr().String())
+			return
+		}
 	}
 }
 
@@ -26,6 +26,7 @@ type readState struct {
 	msgType           byte     // 消息类型
 	args              [][]byte // 命令参数
 	bulkLen           int64    // 长度
+	readingBody       bool     // 是否正在读取 $ 头部之后的数据
 }
 
 // 计算解析有没有完成
@@ -33,16 +34,73 @@ func (s *readState) finished() bool {
 	return s.expectedArgsCount > 0 && len(s.args) == s.expectedArgsCount
 }
 
+// relay 队列的上限, 达到任意一个上限时暂停读取连接, 直到调用者取走解析结果
+// 客户端阻塞时仍然可以读取之后的少量命令并发现客户端断开连接, 同时不会无限制地占用内存
+const (
+	maxPendingPayloads = 1024    // 队列中解析结果的最大个数
+	maxPendingBytes    = 1 << 20 // 队列中命令参数的最大字节数
+)
+
 // 解析器的入口，异步解析
+// 解析结果经过 relay 的队列转发, 客户端阻塞(例如 BLPOP)而没有读取 channel 时解析器仍然继续读取连接,
+// 这样即使客户端在阻塞命令之后还发送了其它命令, 也能及时发现客户端断开连接
 func ParseStream(reader io.Reader) <-chan *Payload {
-	// 1. 创建一个 channel
+	// 1. 创建 channel, parsed 接收解析结果, ch 返回给调用者
+	parsed := make(chan *Payload)
 	ch := make(chan *Payload)
-	// 2. 启动一个 goroutine，调用 parse0 函数
-	go parse0(reader, ch)
+	// 2. 启动 goroutine, parse0 负责解析, relay 负责按顺序转发
+	go parse0(reader, parsed)
+	go relay(parsed, ch)
 	// 3. 返回 channel
 	return ch
 }
 
+// relay 将 in 中的解析结果按顺序转发到 out, out 没有被读取时暂存在队列中, in 关闭并且队列为空时关闭 out
+// 队列达到上限时不再从 in 接收, parse0 随之阻塞并停止读取连接
+func relay(in <-chan *Payload, out chan<- *Payload) {
+	var queue []*Payload
+	size := 0 // 队列中命令参数的字节数
+	for in != nil || len(queue) > 0 {
+		// 队列为空时 send 为 nil, select 不会选择发送
+		var send chan<- *Payload
+		var next *Payload
+		if len(queue) > 0 {
+			send = out
+			next = queue[0]
+		}
+		// 队列已满时 recv 为 nil, select 不会选择接收
+		recv := in
+		if len(queue) >= maxPendingPayloads || size >= maxPendingBytes {
+			recv = nil
+		}
+		select {
+		case payload, ok := <-recv:
+			if !ok {
+				in = nil
+				continue
+			}
+			queue = append(queue, payload)
+			size += payloadSize(payload)
+		case send <- next:
+			size -= payloadSize(next)
+			queue[0] = nil
+			queue = queue[1:]
+		}
+	}
+	close(out)
+}
+
+// payloadSize 返回解析结果中命令参数的字节数
+func payloadSize(payload *Payload) int {
+	size := 0
+	if r, ok := payload.Data.(*reply.MultiBulkReply); ok {
+		for _, arg := range r.Args {
+			size += len(arg)
+		}
+	}
+	return size
+}
+
 // 解析器核心
 func parse0(reader io.Reader, ch chan<- *Payload) {
 	defer func() {
@@ -236,6 +294,7 @@ func parseBulkHeader(msg []byte, state *readState) error {
 		state.redingMultiLine = true      // 是否正在读取多行
 		state.expectedArgsCount = 1       // 期望的参数个数
 		state.args = make([][]byte, 0, 1) // 命令参数
+		state.readingBody = true          // 下一行是数据
 		return nil
 	} else {
 		return errors.New("protocol error: " + string(msg)) // 协议错误
@@ -269,7 +328,8 @@ func readBody(msg []byte, state *readState) error {
 	line := msg[0 : len(msg)-2] // 去掉 \r\n
 	var err error
 
-	if line[0] == '$' { // 批量回复
+	// 数据本身可能以 $ 开头或者为空, 所以只有不在读取数据时才解析 $ 头部
+	if !state.readingBody && len(line) > 0 && line[0] == '$' { // 批量回复
 		// bulk reply 批量回复
 		state.bulkLen, err = strconv.ParseInt(string(line[1:]), 10, 64) // 10进制 64位
 		if err != nil {
@@ -278,9 +338,12 @@ func readBody(msg []byte, state *readState) error {
 		if state.bulkLen < 0 { // 如果长度是 -1，就是空值
 			state.args = append(state.args, []byte{})
 			state.bulkLen = 0
+		} else {
+			state.readingBody = true
 		}
 	} else {
 		state.args = append(state.args, line)
+		state.readingBody = false
 	}
 	return nil
 }
@@ -54,6 +54,19 @@ func MakeEmptyMultiBulkReply() *EmptyMultiBulkReply {
 	return &EmptyMultiBulkReply{}
 }
 
+// NullMultiBulkReply 空数组(nil), 例如阻塞命令超时
+type NullMultiBulkReply struct{}
+
+var nullMultiBulkBytes = []byte("*-1\r\n")
+
+func (r NullMultiBulkReply) ToBytes() []byte {
+	return nullMultiBulkBytes
+}
+
+func MakeNullMultiBulkReply() *NullMultiBulkReply {
+	return &NullMultiBulkReply{}
+}
+
 // NoReply 什么都不返回
 type NoReply struct{}
 
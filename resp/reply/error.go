@@ -66,6 +66,13 @@ func (r *WrongTypeErrReply) ToBytes() []byte {
 	return wrongTypeErrBytes
 }
 
+var theWrongTypeErrReply = &WrongTypeErrReply{}
+
+// MakeWrongTypeErrReply 创建类型错误回复
+func MakeWrongTypeErrReply() *WrongTypeErrReply {
+	return theWrongTypeErrReply
+}
+
 /* ---- ProtocolErr Reply ---- */
 // 表示在分析请求期间遇到意外字节
 type ProtocolErrReply struct {
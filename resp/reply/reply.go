@@ -8,7 +8,7 @@ import (
 )
 
 var (
-	nullBulkReplyBytes = []byte("$-1")
+	nullBulkReplyBytes = []byte("$-1\r\n")
 
 	// CRLF是redis序列化协议的行分隔符
 	CRLF = "\r\n"
@@ -29,7 +29,8 @@ func MakeBulkReply(arg []byte) *BulkReply {
 }
 
 func (r *BulkReply) ToBytes() []byte {
-	if len(r.Arg) == 0 {
+	// nil 表示空值, 空切片表示空字符串
+	if r.Arg == nil {
 		return nullBulkReplyBytes
 	}
 	// strconv.Itoa() 函数用于将整型转换为字符串
@@ -69,6 +70,29 @@ func MakeMultiBulkReply(args [][]byte) *MultiBulkReply {
 	}
 }
 
+/* ---- Multi Raw Reply ---- */
+// MultiRawReply存储任意类型回复的列表, 用于嵌套数组
+type MultiRawReply struct {
+	Replies []resp.Reply
+}
+
+func (r *MultiRawReply) ToBytes() []byte {
+	argLen := len(r.Replies)
+	var buf bytes.Buffer
+	buf.WriteString("*" + strconv.Itoa(argLen) + CRLF)
+	for _, arg := range r.Replies {
+		buf.Write(arg.ToBytes())
+	}
+	return buf.Bytes()
+}
+
+// MakeMultiRawReply创建MultiRawReply
+func MakeMultiRawReply(replies []resp.Reply) *MultiRawReply {
+	return &MultiRawReply{
+		Replies: replies,
+	}
+}
+
 /* ---- Status Reply ---- */
 // StatusReply存储简单状态字符串
 type StatusReply struct {